@@ -603,17 +603,37 @@ func testAcceptance(t *testing.T, when spec.G, it spec.S) {
 
 			h.AssertEq(t, output, `Remote
 ------
+Stack: (unknown)
+Lifecycle Version: (unknown)
+User ID: (unknown)
+Group ID: (unknown)
 Run Image: some/run1
 Run Image Mirrors:
 	some-registry.com/some/run1 (user-configured)
 	gcr.io/some/run1
+Buildpacks:
+Detection Order:
+Build Environment:
 
 Local
 -----
+Stack: (unknown)
+Lifecycle Version: (unknown)
+User ID: (unknown)
+Group ID: (unknown)
 Run Image: some/run1
 Run Image Mirrors:
 	some-registry.com/some/run1 (user-configured)
 	gcr.io/some/run2
+Buildpacks:
+Detection Order:
+Build Environment:
+
+Differences between remote and local
+------------------------------------
+Run Image Mirrors:
+	remote: gcr.io/some/run1
+	local:  gcr.io/some/run2
 
 `)
 		})
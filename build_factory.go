@@ -12,15 +12,16 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
-	"time"
 
 	"github.com/buildpack/pack/cache"
 	"github.com/buildpack/pack/config"
 	"github.com/buildpack/pack/containers"
 	"github.com/buildpack/pack/docker"
 	"github.com/buildpack/pack/fs"
+	"github.com/buildpack/pack/git"
 	"github.com/buildpack/pack/logging"
 	"github.com/buildpack/pack/style"
 
@@ -59,10 +60,14 @@ type BuildFlags struct {
 	NoPull     bool
 	ClearCache bool
 	Buildpacks []string
+	Order      string
+	SubPath    string
+	GitRef     string
 }
 
 type BuildConfig struct {
 	AppDir     string
+	AppArchive bool
 	Builder    string
 	RunImage   string
 	EnvFile    map[string]string
@@ -71,11 +76,15 @@ type BuildConfig struct {
 	NoPull     bool
 	ClearCache bool
 	Buildpacks []string
+	Groups     []lifecycle.BuildpackGroup
+	SubPath    string
+	GitCommit  string
 	// Above are copied from BuildFlags are set by init
-	Cli    Docker
-	Logger *logging.Logger
-	FS     FS
-	Config *config.Config
+	Cli          Docker
+	Logger       *logging.Logger
+	FS           FS
+	Config       *config.Config
+	ImageFactory ImageFactory
 	// Above are copied from BuildFactory
 	Cache Cache
 }
@@ -93,7 +102,7 @@ func DefaultBuildFactory(logger *logging.Logger, cache Cache, dockerClient Docke
 	f := &BuildFactory{
 		ImageFactory: imageFactory,
 		Logger:       logger,
-		FS:           &fs.FS{},
+		FS:           &fs.FS{Reproducible: true},
 		Cache:        cache,
 	}
 
@@ -118,7 +127,7 @@ func RepositoryName(logger *logging.Logger, buildFlags *BuildFlags) (string, err
 		logger.Verbose("Defaulting app directory to current working directory %s (use --path to override)", style.Symbol(buildFlags.AppDir))
 	}
 
-	appDir, err := filepath.Abs(buildFlags.AppDir)
+	appDir, err := filepath.Abs(filepath.Join(buildFlags.AppDir, buildFlags.SubPath))
 	if err != nil {
 		return "", err
 	}
@@ -132,6 +141,43 @@ func calculateRepositoryName(appDir string, buildFlags *BuildFlags) string {
 	return buildFlags.RepoName
 }
 
+// stdinAppArchive is the --path value used to read an app archive from stdin
+const stdinAppArchive = "-"
+
+var appArchiveExtensions = []string{".zip", ".jar", ".war", ".tar", ".tgz", ".tar.gz"}
+
+// isAppArchive reports whether the app source is an archive file (or stdin) rather than a directory
+func isAppArchive(appDir string) (bool, error) {
+	if appDir == stdinAppArchive {
+		return true, nil
+	}
+	fi, err := os.Stat(appDir)
+	if err != nil || fi.IsDir() {
+		return false, nil
+	}
+	for _, ext := range appArchiveExtensions {
+		if strings.HasSuffix(strings.ToLower(appDir), ext) {
+			return true, nil
+		}
+	}
+	return false, fmt.Errorf("unsupported app archive %s: must be a directory or one of %s", style.Symbol(appDir), strings.Join(appArchiveExtensions, ", "))
+}
+
+// cleanSubPath normalizes a path relative to the app directory, rejecting paths that point outside of it
+func cleanSubPath(subPath string) (string, error) {
+	if subPath == "" {
+		return "", nil
+	}
+	cleaned := filepath.Clean(subPath)
+	if filepath.IsAbs(cleaned) || cleaned == ".." || strings.HasPrefix(cleaned, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid sub-path %s: must be relative to the app directory", style.Symbol(subPath))
+	}
+	if cleaned == "." {
+		return "", nil
+	}
+	return cleaned, nil
+}
+
 func (bf *BuildFactory) BuildConfigFromFlags(f *BuildFlags) (*BuildConfig, error) {
 	if f.AppDir == "" {
 		var err error
@@ -141,24 +187,60 @@ func (bf *BuildFactory) BuildConfigFromFlags(f *BuildFlags) (*BuildConfig, error
 		}
 		bf.Logger.Verbose("Defaulting app directory to current working directory %s (use --path to override)", style.Symbol(f.AppDir))
 	}
-	appDir, err := filepath.Abs(f.AppDir)
+	appDir := f.AppDir
+	if appDir != stdinAppArchive {
+		var err error
+		appDir, err = filepath.Abs(f.AppDir)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	subPath, err := cleanSubPath(f.SubPath)
+	if err != nil {
+		return nil, err
+	}
+
+	appArchive, err := isAppArchive(appDir)
 	if err != nil {
 		return nil, err
 	}
+	if appArchive && (subPath != "" || f.GitRef != "") {
+		return nil, errors.New("--sub-path and --git-ref cannot be used with an app archive")
+	}
 
-	f.RepoName = calculateRepositoryName(appDir, f)
+	f.RepoName = calculateRepositoryName(filepath.Join(appDir, subPath), f)
 
 	b := &BuildConfig{
-		AppDir:     appDir,
-		RepoName:   f.RepoName,
-		Publish:    f.Publish,
-		NoPull:     f.NoPull,
-		ClearCache: f.ClearCache,
-		Buildpacks: f.Buildpacks,
-		Cli:        bf.Cli,
-		Logger:     bf.Logger,
-		FS:         bf.FS,
-		Config:     bf.Config,
+		AppDir:       appDir,
+		AppArchive:   appArchive,
+		RepoName:     f.RepoName,
+		Publish:      f.Publish,
+		NoPull:       f.NoPull,
+		ClearCache:   f.ClearCache,
+		Buildpacks:   f.Buildpacks,
+		Cli:          bf.Cli,
+		Logger:       bf.Logger,
+		FS:           bf.FS,
+		Config:       bf.Config,
+		ImageFactory: bf.ImageFactory,
+	}
+
+	if f.GitRef != "" {
+		repo := git.Repository{Dir: appDir}
+		b.GitCommit, err = repo.ResolveCommit(f.GitRef)
+		if err != nil {
+			return nil, err
+		}
+		b.SubPath = subPath
+		bf.Logger.Verbose("Using app source from commit %s of git repository %s", style.Symbol(b.GitCommit), style.Symbol(appDir))
+	} else if subPath != "" {
+		b.AppDir = filepath.Join(appDir, subPath)
+		if fi, err := os.Stat(b.AppDir); err != nil {
+			return nil, errors.Wrapf(err, "invalid sub-path %s", style.Symbol(f.SubPath))
+		} else if !fi.IsDir() {
+			return nil, fmt.Errorf("invalid sub-path %s: not a directory", style.Symbol(f.SubPath))
+		}
 	}
 
 	if f.EnvFile != "" {
@@ -168,6 +250,16 @@ func (bf *BuildFactory) BuildConfigFromFlags(f *BuildFlags) (*BuildConfig, error
 		}
 	}
 
+	if f.Order != "" {
+		if len(f.Buildpacks) > 0 {
+			return nil, errors.New("--buildpack and --order cannot be used together")
+		}
+		b.Groups, err = parseOrderFile(f.Order)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	if f.Builder == "" {
 		bf.Logger.Verbose("Using default builder image %s", style.Symbol(bf.Config.DefaultBuilder))
 		b.Builder = bf.Config.DefaultBuilder
@@ -192,10 +284,20 @@ func (bf *BuildFactory) BuildConfigFromFlags(f *BuildFlags) (*BuildConfig, error
 		return nil, fmt.Errorf("invalid builder image %s: missing required label %s", style.Symbol(b.Builder), style.Symbol(StackLabel))
 	}
 
-	if f.RunImage != "" {
-		bf.Logger.Verbose("Using user-provided run image %s", style.Symbol(f.RunImage))
-		b.RunImage = f.RunImage
-	} else {
+	// the phases are run with the arguments of the lifecycle versions pack supports, builders from before the
+	// lifecycle version was recorded are assumed to be compatible
+	lifecycleVersion, err := builderImage.Label(LifecycleVersionLabel)
+	if err != nil {
+		return nil, fmt.Errorf("invalid builder image %s: %s", style.Symbol(b.Builder), err)
+	}
+	if lifecycleVersion == "" {
+		bf.Logger.Verbose("Builder %s does not record its lifecycle version, assuming it is supported", style.Symbol(b.Builder))
+	} else if !supportedLifecycleVersion(lifecycleVersion) {
+		return nil, fmt.Errorf("builder %s uses lifecycle version %s, which is not supported by this version of pack (supported versions: %s)", style.Symbol(b.Builder), style.Symbol(lifecycleVersion), supportedLifecycleVersionList())
+	}
+
+	var builderMetadata BuilderImageMetadata
+	if f.RunImage == "" || len(f.Buildpacks) > 0 || len(b.Groups) > 0 {
 		label, err := builderImage.Label(BuilderMetadataLabel)
 		if err != nil {
 			return nil, fmt.Errorf("invalid builder image %s: %s", style.Symbol(b.Builder), err)
@@ -203,11 +305,19 @@ func (bf *BuildFactory) BuildConfigFromFlags(f *BuildFlags) (*BuildConfig, error
 		if label == "" {
 			return nil, fmt.Errorf("invalid builder image %s: missing required label %s -- try recreating builder", style.Symbol(b.Builder), style.Symbol(BuilderMetadataLabel))
 		}
-		var builderMetadata BuilderImageMetadata
 		if err := json.Unmarshal([]byte(label), &builderMetadata); err != nil {
 			return nil, fmt.Errorf("invalid builder image metadata: %s", err)
 		}
+	}
 
+	if err := bf.validateBuildpacks(b, builderMetadata); err != nil {
+		return nil, err
+	}
+
+	if f.RunImage != "" {
+		bf.Logger.Verbose("Using user-provided run image %s", style.Symbol(f.RunImage))
+		b.RunImage = f.RunImage
+	} else {
 		reg, err := config.Registry(f.RepoName)
 		if err != nil {
 			return nil, err
@@ -266,6 +376,84 @@ func (bf *BuildFactory) BuildConfigFromFlags(f *BuildFlags) (*BuildConfig, error
 	return b, nil
 }
 
+// validateBuildpacks checks the buildpacks passed with --buildpack before any container is created. Directory
+// buildpacks must provide a valid buildpack.toml, while buildpack references must be present in the builder
+// (when the builder metadata lists its buildpacks).
+func (bf *BuildFactory) validateBuildpacks(b *BuildConfig, metadata BuilderImageMetadata) error {
+	for _, bp := range b.Buildpacks {
+		if isDirectoryBuildpack(bp) {
+			if _, err := readBuildpackTOML(bp); err != nil {
+				return err
+			}
+			continue
+		}
+
+		id, version := b.parseBuildpack(bp)
+		if id == "" || version == "" {
+			return fmt.Errorf("invalid buildpack reference %s: expected <id> or <id>@<version>", style.Symbol(bp))
+		}
+		if len(metadata.Buildpacks) == 0 {
+			bf.Logger.Verbose("Builder %s does not list its buildpacks, skipping validation of %s", style.Symbol(b.Builder), style.Symbol(bp))
+			continue
+		}
+		resolved, ok := metadata.resolveBuildpack(id, version)
+		if !ok {
+			return buildpackNotFoundError(id, version, b.Builder, metadata)
+		}
+		if version == "latest" {
+			bf.Logger.Verbose("Resolved buildpack %s to %s", style.Symbol(id+"@latest"), style.Symbol(id+"@"+resolved))
+		}
+	}
+
+	for _, group := range b.Groups {
+		for _, bp := range group.Buildpacks {
+			if len(metadata.Buildpacks) == 0 {
+				bf.Logger.Verbose("Builder %s does not list its buildpacks, skipping validation of %s", style.Symbol(b.Builder), style.Symbol(bp.ID+"@"+bp.Version))
+				continue
+			}
+			resolved, ok := metadata.resolveBuildpack(bp.ID, bp.Version)
+			if !ok {
+				return buildpackNotFoundError(bp.ID, bp.Version, b.Builder, metadata)
+			}
+			if bp.Version == "latest" {
+				bf.Logger.Verbose("Resolved buildpack %s to %s", style.Symbol(bp.ID+"@latest"), style.Symbol(bp.ID+"@"+resolved))
+			}
+		}
+	}
+	return nil
+}
+
+// parseOrderFile reads an order.toml containing one or more groups of buildpacks to use in place of the builder's
+// own detection order. Buildpacks without a version default to "latest".
+func parseOrderFile(path string) ([]lifecycle.BuildpackGroup, error) {
+	var o order
+	if _, err := toml.DecodeFile(path, &o); err != nil {
+		return nil, fmt.Errorf(`failed to decode order from file %s: %s`, path, err)
+	}
+	if len(o.Groups) == 0 {
+		return nil, fmt.Errorf("invalid order file %s: at least one group is required", path)
+	}
+	for i, group := range o.Groups {
+		if len(group.Buildpacks) == 0 {
+			return nil, fmt.Errorf("invalid order file %s: group %d has no buildpacks", path, i+1)
+		}
+		required := false
+		for _, bp := range group.Buildpacks {
+			if bp.ID == "" {
+				return nil, fmt.Errorf("invalid order file %s: group %d has a buildpack without an id", path, i+1)
+			}
+			if bp.Version == "" {
+				bp.Version = "latest"
+			}
+			required = required || !bp.Optional
+		}
+		if !required {
+			return nil, fmt.Errorf("invalid order file %s: group %d must contain at least one buildpack that is not optional", path, i+1)
+		}
+	}
+	return o.Groups, nil
+}
+
 func Build(ctx context.Context, outWriter, errWriter io.Writer, appDir, buildImage, runImage, repoName string, publish, clearCache bool) error {
 	// TODO: Receive Cache as an argument of this function
 	dockerClient, err := docker.New()
@@ -321,9 +509,123 @@ func (b *BuildConfig) Run(ctx context.Context) error {
 		return err
 	}
 
+	if b.GitCommit != "" {
+		if err := b.labelSourceRevision(); err != nil {
+			return errors.Wrap(err, "label image with source revision")
+		}
+	}
+
+	return nil
+}
+
+func (b *BuildConfig) labelSourceRevision() error {
+	var img image.Image
+	var err error
+	if b.Publish {
+		img, err = b.ImageFactory.NewRemote(b.RepoName)
+	} else {
+		img, err = b.ImageFactory.NewLocal(b.RepoName, false)
+	}
+	if err != nil {
+		return err
+	}
+	if err := img.SetLabel(SourceRevisionLabel, b.GitCommit); err != nil {
+		return err
+	}
+	_, err = img.Save()
+	return err
+}
+
+// DryRun logs the resolved build plan without creating any containers. The plan has already been validated by
+// BuildConfigFromFlags; when publishing, DryRun also checks that credentials are available for the target registry.
+func (b *BuildConfig) DryRun() error {
+	if b.Publish {
+		_, authenticator, err := auth.ReferenceForRepoName(authn.DefaultKeychain, b.RepoName)
+		if err != nil {
+			return errors.Wrapf(err, "resolving registry credentials for %s", style.Symbol(b.RepoName))
+		}
+		if authenticator == authn.Anonymous {
+			return fmt.Errorf("no registry credentials found for %s: log in to its registry with 'docker login' before publishing", style.Symbol(b.RepoName))
+		}
+	}
+
+	b.Logger.Info("Build plan for %s:", style.Symbol(b.RepoName))
+	if b.AppDir == stdinAppArchive {
+		b.Logger.Info("  App:        archive from stdin")
+	} else if b.AppArchive {
+		b.Logger.Info("  App:        %s (archive)", b.AppDir)
+	} else {
+		b.Logger.Info("  App:        %s", b.AppDir)
+	}
+	if b.GitCommit != "" {
+		b.Logger.Info("  Revision:   %s (sub-path: %s)", b.GitCommit, filepath.Join(".", b.SubPath))
+	}
+	b.Logger.Info("  Builder:    %s", b.Builder)
+	b.Logger.Info("  Run image:  %s", b.RunImage)
+	if b.Publish {
+		b.Logger.Info("  Export to:  registry")
+	} else {
+		b.Logger.Info("  Export to:  daemon")
+	}
+	b.Logger.Info("  Cache:      %s (clear: %t)", b.Cache.Volume(), b.ClearCache)
+	if len(b.Groups) > 0 {
+		b.Logger.Info("  Order:")
+		for i, group := range b.Groups {
+			var refs []string
+			for _, bp := range group.Buildpacks {
+				ref := bp.ID + "@" + bp.Version
+				if bp.Optional {
+					ref += " (optional)"
+				}
+				refs = append(refs, ref)
+			}
+			b.Logger.Info("    Group %d: %s", i+1, strings.Join(refs, ", "))
+		}
+	} else if len(b.Buildpacks) == 0 {
+		b.Logger.Info("  Buildpacks: detection order from builder")
+	} else {
+		b.Logger.Info("  Buildpacks:")
+		for _, bp := range b.Buildpacks {
+			b.Logger.Info("    %s", bp)
+		}
+	}
+	if len(b.EnvFile) > 0 {
+		var names []string
+		for k := range b.EnvFile {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		b.Logger.Info("  Env:        %s", strings.Join(names, ", "))
+	}
 	return nil
 }
 
+func buildpackNotFoundError(id, version, builder string, metadata BuilderImageMetadata) error {
+	msg := fmt.Sprintf("buildpack %s not found in builder %s", style.Symbol(id+"@"+version), style.Symbol(builder))
+	if suggestion := metadata.suggestBuildpack(id, version); suggestion != "" {
+		msg += fmt.Sprintf(", did you mean %s?", style.Symbol(suggestion))
+	}
+	if versions := metadata.buildpackVersions(id); len(versions) > 0 {
+		msg += fmt.Sprintf(" (available versions: %s)", strings.Join(versions, ", "))
+	}
+	return errors.New(msg)
+}
+
+func isDirectoryBuildpack(bp string) bool {
+	_, err := os.Stat(filepath.Join(bp, "buildpack.toml"))
+	return !os.IsNotExist(err)
+}
+
+func readBuildpackTOML(dir string) (Buildpack, error) {
+	var buildpackTOML struct {
+		Buildpack Buildpack
+	}
+	if _, err := toml.DecodeFile(filepath.Join(dir, "buildpack.toml"), &buildpackTOML); err != nil {
+		return Buildpack{}, fmt.Errorf(`failed to decode buildpack.toml from "%s": %s`, dir, err)
+	}
+	return buildpackTOML.Buildpack, nil
+}
+
 func (b *BuildConfig) parseBuildpack(ref string) (string, string) {
 	parts := strings.Split(ref, "@")
 	if len(parts) == 2 {
@@ -337,21 +639,17 @@ func (b *BuildConfig) copyBuildpacksToContainer(ctx context.Context, ctrID strin
 	var buildpacks []*lifecycle.Buildpack
 	for _, bp := range b.Buildpacks {
 		var id, version string
-		if _, err := os.Stat(filepath.Join(bp, "buildpack.toml")); !os.IsNotExist(err) {
+		if isDirectoryBuildpack(bp) {
 			if runtime.GOOS == "windows" {
 				return nil, fmt.Errorf("directory buildpacks are not implemented on windows")
 			}
-			var buildpackTOML struct {
-				Buildpack Buildpack
-			}
-
-			_, err = toml.DecodeFile(filepath.Join(bp, "buildpack.toml"), &buildpackTOML)
+			buildpackTOML, err := readBuildpackTOML(bp)
 			if err != nil {
-				return nil, fmt.Errorf(`failed to decode buildpack.toml from "%s": %s`, bp, err)
+				return nil, err
 			}
-			id = buildpackTOML.Buildpack.ID
-			version = buildpackTOML.Buildpack.Version
-			bpDir := filepath.Join(buildpacksDir, buildpackTOML.Buildpack.escapedID(), version)
+			id = buildpackTOML.ID
+			version = buildpackTOML.Version
+			bpDir := filepath.Join(buildpacksDir, buildpackTOML.escapedID(), version)
 			ftr, errChan := b.FS.CreateTarReader(bp, bpDir, 0, 0)
 			if err := b.Cli.CopyToContainer(ctx, ctrID, "/", ftr, dockertypes.CopyToContainerOptions{}); err != nil {
 				return nil, errors.Wrapf(err, "copying buildpack '%s' to container", bp)
@@ -400,20 +698,26 @@ func (b *BuildConfig) Detect(ctx context.Context) error {
 
 	var orderToml string
 	b.Logger.Verbose(style.Step("DETECTING"))
-	if len(b.Buildpacks) == 0 {
+	if len(b.Buildpacks) == 0 && len(b.Groups) == 0 {
 		orderToml = "" // use order.toml already in image
 	} else {
-		b.Logger.Verbose("Using manually-provided group")
+		var groups lifecycle.BuildpackOrder
+		if len(b.Groups) > 0 {
+			b.Logger.Verbose("Using manually-provided order")
+			groups = b.Groups
+		} else {
+			b.Logger.Verbose("Using manually-provided group")
 
-		buildpacks, err := b.copyBuildpacksToContainer(ctx, ctr.ID)
-		if err != nil {
-			return errors.Wrap(err, "copy buildpacks to container")
-		}
+			buildpacks, err := b.copyBuildpacksToContainer(ctx, ctr.ID)
+			if err != nil {
+				return errors.Wrap(err, "copy buildpacks to container")
+			}
 
-		groups := lifecycle.BuildpackOrder{
-			lifecycle.BuildpackGroup{
-				Buildpacks: buildpacks,
-			},
+			groups = lifecycle.BuildpackOrder{
+				lifecycle.BuildpackGroup{
+					Buildpacks: buildpacks,
+				},
+			}
 		}
 
 		var tomlBuilder strings.Builder
@@ -424,7 +728,7 @@ func (b *BuildConfig) Detect(ctx context.Context) error {
 		orderToml = tomlBuilder.String()
 	}
 
-	tr, errChan := b.FS.CreateTarReader(b.AppDir, launchDir+"/app", 0, 0)
+	tr, errChan := b.appTarReader()
 	if err := b.Cli.CopyToContainer(ctx, ctr.ID, "/", tr, dockertypes.CopyToContainerOptions{}); err != nil {
 		return errors.Wrap(err, "copy app to workspace volume")
 	}
@@ -466,6 +770,41 @@ func (b *BuildConfig) Detect(ctx context.Context) error {
 	return nil
 }
 
+// appTarReader streams the app source into the workspace, either from the app directory or, when building from a
+// git revision, from the repository's object store
+func (b *BuildConfig) appTarReader() (io.Reader, chan error) {
+	if b.AppArchive {
+		return b.appArchiveTarReader()
+	}
+	if b.GitCommit != "" {
+		repo := git.Repository{Dir: b.AppDir}
+		return repo.CreateTarReader(b.GitCommit, b.SubPath, launchDir+"/app", 0, 0)
+	}
+	return b.FS.CreateTarReader(b.AppDir, launchDir+"/app", 0, 0)
+}
+
+// appArchiveTarReader streams an app archive file, or the archive piped to stdin, into the workspace
+func (b *BuildConfig) appArchiveTarReader() (io.Reader, chan error) {
+	if b.AppDir == stdinAppArchive {
+		return b.FS.CreateTarReaderFromArchive(os.Stdin, launchDir+"/app", 0, 0)
+	}
+
+	f, err := os.Open(b.AppDir)
+	if err != nil {
+		errChan := make(chan error, 1)
+		errChan <- errors.Wrapf(err, "opening app archive %s", style.Symbol(b.AppDir))
+		return bytes.NewReader(nil), errChan
+	}
+	r, archiveErrChan := b.FS.CreateTarReaderFromArchive(f, launchDir+"/app", 0, 0)
+	errChan := make(chan error, 1)
+	go func() {
+		err := <-archiveErrChan
+		f.Close()
+		errChan <- err
+	}()
+	return r, errChan
+}
+
 func (b *BuildConfig) Analyze(ctx context.Context) error {
 	ctrConf := &container.Config{
 		Image:  b.Builder,
@@ -595,18 +934,25 @@ func parseEnvFile(envFile string) (map[string]string, error) {
 }
 
 func (b *BuildConfig) tarEnvFile() (io.Reader, error) {
-	now := time.Now()
+	modTime := fs.SourceDateEpoch()
+	var names []string
+	for k := range b.EnvFile {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
 	var buf bytes.Buffer
 	tw := tar.NewWriter(&buf)
-	for k, v := range b.EnvFile {
-		if err := tw.WriteHeader(&tar.Header{Name: "/platform/env/" + k, Size: int64(len(v)), Mode: 0444, ModTime: now}); err != nil {
+	for _, k := range names {
+		v := b.EnvFile[k]
+		if err := tw.WriteHeader(&tar.Header{Name: "/platform/env/" + k, Size: int64(len(v)), Mode: 0444, ModTime: modTime}); err != nil {
 			return nil, err
 		}
 		if _, err := tw.Write([]byte(v)); err != nil {
 			return nil, err
 		}
 	}
-	if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: "/platform/env/", Mode: 0555, ModTime: now}); err != nil {
+	if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: "/platform/env/", Mode: 0555, ModTime: modTime}); err != nil {
 		return nil, err
 	}
 	if err := tw.Close(); err != nil {
@@ -6,9 +6,11 @@ import (
 	"io/ioutil"
 	"math/rand"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/buildpack/lifecycle"
 	"github.com/fatih/color"
 
 	"github.com/buildpack/pack/cache"
@@ -116,6 +118,7 @@ func testBuildFactory(t *testing.T, when spec.G, it spec.S) {
 		it("defaults to daemon, default-builder, pulls builder and run images, selects run-image from builder", func() {
 			mockBuilderImage := mocks.NewMockImage(mockController)
 			mockBuilderImage.EXPECT().Label("io.buildpacks.stack.id").Return("some.stack.id", nil)
+			mockBuilderImage.EXPECT().Label("io.buildpacks.lifecycle.version").Return("0.1.0", nil)
 			mockBuilderImage.EXPECT().Label("io.buildpacks.builder.metadata").Return(`{"runImage": {"image": "some/run"}}`, nil)
 			mockImageFactory.EXPECT().NewLocal("some/builder", true).Return(mockBuilderImage, nil)
 
@@ -136,6 +139,7 @@ func testBuildFactory(t *testing.T, when spec.G, it spec.S) {
 		it("respects builder from flags", func() {
 			mockBuilderImage := mocks.NewMockImage(mockController)
 			mockBuilderImage.EXPECT().Label("io.buildpacks.stack.id").Return("some.stack.id", nil)
+			mockBuilderImage.EXPECT().Label("io.buildpacks.lifecycle.version").Return("0.1.0", nil)
 			mockBuilderImage.EXPECT().Label("io.buildpacks.builder.metadata").Return(`{"runImage": {"image": "some/run"}}`, nil)
 			mockImageFactory.EXPECT().NewLocal("custom/builder", true).Return(mockBuilderImage, nil)
 
@@ -156,6 +160,7 @@ func testBuildFactory(t *testing.T, when spec.G, it spec.S) {
 		it("doesn't pull builder or run images when --no-pull is passed", func() {
 			mockBuilderImage := mocks.NewMockImage(mockController)
 			mockBuilderImage.EXPECT().Label("io.buildpacks.stack.id").Return("some.stack.id", nil)
+			mockBuilderImage.EXPECT().Label("io.buildpacks.lifecycle.version").Return("0.1.0", nil)
 			mockBuilderImage.EXPECT().Label("io.buildpacks.builder.metadata").Return(`{"runImage": {"image": "some/run"}}`, nil)
 			mockImageFactory.EXPECT().NewLocal("custom/builder", false).Return(mockBuilderImage, nil)
 
@@ -177,6 +182,7 @@ func testBuildFactory(t *testing.T, when spec.G, it spec.S) {
 		it("selects run images with matching registry", func() {
 			mockBuilderImage := mocks.NewMockImage(mockController)
 			mockBuilderImage.EXPECT().Label("io.buildpacks.stack.id").Return("some.stack.id", nil)
+			mockBuilderImage.EXPECT().Label("io.buildpacks.lifecycle.version").Return("0.1.0", nil)
 			mockBuilderImage.EXPECT().Label("io.buildpacks.builder.metadata").
 				Return(`{"runImage": {"image": "some/run", "mirrors": ["registry.com/some/run"]}}`, nil)
 			mockImageFactory.EXPECT().NewLocal("some/builder", true).Return(mockBuilderImage, nil)
@@ -206,6 +212,7 @@ func testBuildFactory(t *testing.T, when spec.G, it spec.S) {
 
 				mockBuilderImage := mocks.NewMockImage(mockController)
 				mockBuilderImage.EXPECT().Label("io.buildpacks.stack.id").Return("some.stack.id", nil)
+				mockBuilderImage.EXPECT().Label("io.buildpacks.lifecycle.version").Return("0.1.0", nil)
 				mockBuilderImage.EXPECT().Label("io.buildpacks.builder.metadata").
 					Return(`{"runImage": {"image": "default/run", "mirrors": ["registry.com/default/run"]}}`, nil)
 				mockImageFactory.EXPECT().NewLocal("some/builder", true).Return(mockBuilderImage, nil)
@@ -230,6 +237,7 @@ func testBuildFactory(t *testing.T, when spec.G, it spec.S) {
 		it("uses a remote run image when --publish is passed", func() {
 			mockBuilderImage := mocks.NewMockImage(mockController)
 			mockBuilderImage.EXPECT().Label("io.buildpacks.stack.id").Return("some.stack.id", nil)
+			mockBuilderImage.EXPECT().Label("io.buildpacks.lifecycle.version").Return("0.1.0", nil)
 			mockBuilderImage.EXPECT().Label("io.buildpacks.builder.metadata").Return(`{"runImage": {"image": "some/run"}}`, nil)
 			mockImageFactory.EXPECT().NewLocal("some/builder", true).Return(mockBuilderImage, nil)
 
@@ -251,6 +259,7 @@ func testBuildFactory(t *testing.T, when spec.G, it spec.S) {
 		it("allows run-image from flags if the stacks match", func() {
 			mockBuilderImage := mocks.NewMockImage(mockController)
 			mockBuilderImage.EXPECT().Label("io.buildpacks.stack.id").Return("some.stack.id", nil)
+			mockBuilderImage.EXPECT().Label("io.buildpacks.lifecycle.version").Return("0.1.0", nil)
 			mockImageFactory.EXPECT().NewLocal("some/builder", true).Return(mockBuilderImage, nil)
 
 			mockRunImage := mocks.NewMockImage(mockController)
@@ -272,6 +281,7 @@ func testBuildFactory(t *testing.T, when spec.G, it spec.S) {
 		it("doesn't allow run-image from flags if the stacks are different", func() {
 			mockBuilderImage := mocks.NewMockImage(mockController)
 			mockBuilderImage.EXPECT().Label("io.buildpacks.stack.id").Return("some.stack.id", nil)
+			mockBuilderImage.EXPECT().Label("io.buildpacks.lifecycle.version").Return("0.1.0", nil)
 			mockImageFactory.EXPECT().NewLocal("some/builder", true).Return(mockBuilderImage, nil)
 
 			mockRunImage := mocks.NewMockImage(mockController)
@@ -291,6 +301,7 @@ func testBuildFactory(t *testing.T, when spec.G, it spec.S) {
 		it("uses working dir if appDir is set to placeholder value", func() {
 			mockBuilderImage := mocks.NewMockImage(mockController)
 			mockBuilderImage.EXPECT().Label("io.buildpacks.stack.id").Return("some.stack.id", nil)
+			mockBuilderImage.EXPECT().Label("io.buildpacks.lifecycle.version").Return("0.1.0", nil)
 			mockBuilderImage.EXPECT().Label("io.buildpacks.builder.metadata").Return(`{"runImage": {"image": "some/run"}}`, nil)
 			mockImageFactory.EXPECT().NewLocal("some/builder", true).Return(mockBuilderImage, nil)
 
@@ -335,9 +346,43 @@ func testBuildFactory(t *testing.T, when spec.G, it spec.S) {
 			h.AssertError(t, err, "invalid builder image 'some/builder': missing required label 'io.buildpacks.stack.id'")
 		})
 
+		it("returns an error when the builder lifecycle version is not supported", func() {
+			mockBuilderImage := mocks.NewMockImage(mockController)
+			mockBuilderImage.EXPECT().Label("io.buildpacks.stack.id").Return("some.stack.id", nil)
+			mockBuilderImage.EXPECT().Label("io.buildpacks.lifecycle.version").Return("0.2.0", nil)
+			mockImageFactory.EXPECT().NewLocal("some/builder", true).Return(mockBuilderImage, nil)
+
+			_, err := factory.BuildConfigFromFlags(&pack.BuildFlags{
+				RepoName: "some/app",
+				Builder:  "some/builder",
+			})
+			h.AssertError(t, err, "builder 'some/builder' uses lifecycle version '0.2.0', which is not supported by this version of pack (supported versions: 0.1.x)")
+		})
+
+		it("assumes builders without a lifecycle version are supported", func() {
+			mockBuilderImage := mocks.NewMockImage(mockController)
+			mockBuilderImage.EXPECT().Label("io.buildpacks.stack.id").Return("some.stack.id", nil)
+			mockBuilderImage.EXPECT().Label("io.buildpacks.lifecycle.version").Return("", nil)
+			mockBuilderImage.EXPECT().Label("io.buildpacks.builder.metadata").Return(`{"runImage": {"image": "some/run"}}`, nil)
+			mockImageFactory.EXPECT().NewLocal("some/builder", true).Return(mockBuilderImage, nil)
+
+			mockRunImage := mocks.NewMockImage(mockController)
+			mockRunImage.EXPECT().Label("io.buildpacks.stack.id").Return("some.stack.id", nil)
+			mockRunImage.EXPECT().Found().Return(true, nil)
+			mockImageFactory.EXPECT().NewLocal("some/run", true).Return(mockRunImage, nil)
+
+			_, err := factory.BuildConfigFromFlags(&pack.BuildFlags{
+				RepoName: "some/app",
+				Builder:  "some/builder",
+			})
+			h.AssertNil(t, err)
+			h.AssertContains(t, outBuf.String(), "Builder 'some/builder' does not record its lifecycle version, assuming it is supported")
+		})
+
 		it("returns an error when the builder metadata label is missing", func() {
 			mockBuilderImage := mocks.NewMockImage(mockController)
 			mockBuilderImage.EXPECT().Label("io.buildpacks.stack.id").Return("some.stack.id", nil)
+			mockBuilderImage.EXPECT().Label("io.buildpacks.lifecycle.version").Return("0.1.0", nil)
 			mockBuilderImage.EXPECT().Label("io.buildpacks.builder.metadata").Return("", nil)
 			mockImageFactory.EXPECT().NewLocal("some/builder", true).Return(mockBuilderImage, nil)
 
@@ -351,6 +396,7 @@ func testBuildFactory(t *testing.T, when spec.G, it spec.S) {
 		it("returns an error when the builder metadata label is unparsable", func() {
 			mockBuilderImage := mocks.NewMockImage(mockController)
 			mockBuilderImage.EXPECT().Label("io.buildpacks.stack.id").Return("some.stack.id", nil)
+			mockBuilderImage.EXPECT().Label("io.buildpacks.lifecycle.version").Return("0.1.0", nil)
 			mockBuilderImage.EXPECT().Label("io.buildpacks.builder.metadata").Return("junk", nil)
 			mockImageFactory.EXPECT().NewLocal("some/builder", true).Return(mockBuilderImage, nil)
 
@@ -364,6 +410,7 @@ func testBuildFactory(t *testing.T, when spec.G, it spec.S) {
 		it("returns an error if remote run image doesn't exist in remote on published builds", func() {
 			mockBuilderImage := mocks.NewMockImage(mockController)
 			mockBuilderImage.EXPECT().Label("io.buildpacks.stack.id").Return("some.stack.id", nil)
+			mockBuilderImage.EXPECT().Label("io.buildpacks.lifecycle.version").Return("0.1.0", nil)
 			mockImageFactory.EXPECT().NewLocal("some/builder", true).Return(mockBuilderImage, nil)
 
 			mockRunImage := mocks.NewMockImage(mockController)
@@ -382,6 +429,7 @@ func testBuildFactory(t *testing.T, when spec.G, it spec.S) {
 		it("returns an error if local run image doesn't exist locally on local builds", func() {
 			mockBuilderImage := mocks.NewMockImage(mockController)
 			mockBuilderImage.EXPECT().Label("io.buildpacks.stack.id").Return("some.stack.id", nil)
+			mockBuilderImage.EXPECT().Label("io.buildpacks.lifecycle.version").Return("0.1.0", nil)
 			mockImageFactory.EXPECT().NewLocal("some/builder", true).Return(mockBuilderImage, nil)
 
 			mockRunImage := mocks.NewMockImage(mockController)
@@ -400,6 +448,7 @@ func testBuildFactory(t *testing.T, when spec.G, it spec.S) {
 		it("sets EnvFile", func() {
 			mockBuilderImage := mocks.NewMockImage(mockController)
 			mockBuilderImage.EXPECT().Label("io.buildpacks.stack.id").Return("some.stack.id", nil)
+			mockBuilderImage.EXPECT().Label("io.buildpacks.lifecycle.version").Return("0.1.0", nil)
 			mockBuilderImage.EXPECT().Label("io.buildpacks.builder.metadata").Return(`{"runImage": {"image": "some/run"}}`, nil)
 			mockImageFactory.EXPECT().NewLocal("some/builder", true).Return(mockBuilderImage, nil)
 
@@ -433,8 +482,373 @@ PATH
 			})
 			h.AssertNotEq(t, os.Getenv("PATH"), "")
 		})
+
+		when("buildpacks are provided", func() {
+			var mockBuilderImage *mocks.MockImage
+
+			it.Before(func() {
+				mockBuilderImage = mocks.NewMockImage(mockController)
+				mockBuilderImage.EXPECT().Label("io.buildpacks.stack.id").Return("some.stack.id", nil)
+				mockBuilderImage.EXPECT().Label("io.buildpacks.lifecycle.version").Return("0.1.0", nil)
+				mockImageFactory.EXPECT().NewLocal("some/builder", true).Return(mockBuilderImage, nil)
+			})
+
+			it("returns an error when a buildpack is missing from the builder", func() {
+				mockBuilderImage.EXPECT().Label("io.buildpacks.builder.metadata").
+					Return(`{"runImage": {"image": "some/run"}, "buildpacks": [{"id": "some.bp", "version": "1.2.3"}]}`, nil)
+
+				_, err := factory.BuildConfigFromFlags(&pack.BuildFlags{
+					RepoName:   "some/app",
+					Builder:    "some/builder",
+					Buildpacks: []string{"some.bp@1.2.3", "other.bp@1.0.0"},
+				})
+				h.AssertError(t, err, "buildpack 'other.bp@1.0.0' not found in builder 'some/builder'")
+				h.AssertNotContains(t, err.Error(), "did you mean")
+			})
+
+			it("suggests the closest buildpack when the ID is misspelled", func() {
+				mockBuilderImage.EXPECT().Label("io.buildpacks.builder.metadata").
+					Return(`{"runImage": {"image": "some/run"}, "buildpacks": [{"id": "some.bp", "version": "1.2.3"}, {"id": "other.bp", "version": "1.0.0"}]}`, nil)
+
+				_, err := factory.BuildConfigFromFlags(&pack.BuildFlags{
+					RepoName:   "some/app",
+					Builder:    "some/builder",
+					Buildpacks: []string{"som.bp@1.2.3"},
+				})
+				h.AssertError(t, err, "buildpack 'som.bp@1.2.3' not found in builder 'some/builder', did you mean 'some.bp@1.2.3'?")
+			})
+
+			it("suggests available versions when a buildpack version is missing from the builder", func() {
+				mockBuilderImage.EXPECT().Label("io.buildpacks.builder.metadata").
+					Return(`{"runImage": {"image": "some/run"}, "buildpacks": [{"id": "some.bp", "version": "1.2.3"}, {"id": "some.bp", "version": "2.0.0"}]}`, nil)
+
+				_, err := factory.BuildConfigFromFlags(&pack.BuildFlags{
+					RepoName:   "some/app",
+					Builder:    "some/builder",
+					Buildpacks: []string{"some.bp@1.2.4"},
+				})
+				h.AssertError(t, err, "buildpack 'some.bp@1.2.4' not found in builder 'some/builder', did you mean 'some.bp@1.2.3'? (available versions: 1.2.3, 2.0.0)")
+			})
+
+			it("resolves latest buildpacks to a concrete version", func() {
+				mockBuilderImage.EXPECT().Label("io.buildpacks.builder.metadata").
+					Return(`{"runImage": {"image": "some/run"}, "buildpacks": [{"id": "some.bp", "version": "1.2.3", "latest": true}]}`, nil)
+				mockRunImage := mocks.NewMockImage(mockController)
+				mockRunImage.EXPECT().Label("io.buildpacks.stack.id").Return("some.stack.id", nil)
+				mockRunImage.EXPECT().Found().Return(true, nil)
+				mockImageFactory.EXPECT().NewLocal("some/run", true).Return(mockRunImage, nil)
+
+				_, err := factory.BuildConfigFromFlags(&pack.BuildFlags{
+					RepoName:   "some/app",
+					Builder:    "some/builder",
+					Buildpacks: []string{"some.bp"},
+				})
+				h.AssertNil(t, err)
+				h.AssertContains(t, outBuf.String(), "Resolved buildpack 'some.bp@latest' to 'some.bp@1.2.3'")
+			})
+
+			it("returns an error when a directory buildpack has an invalid buildpack.toml", func() {
+				mockBuilderImage.EXPECT().Label("io.buildpacks.builder.metadata").Return(`{"runImage": {"image": "some/run"}}`, nil)
+
+				bpDir, err := ioutil.TempDir("", "pack.build.buildpack")
+				h.AssertNil(t, err)
+				defer os.RemoveAll(bpDir)
+				h.AssertNil(t, ioutil.WriteFile(filepath.Join(bpDir, "buildpack.toml"), []byte("[buildpack"), 0644))
+
+				_, err = factory.BuildConfigFromFlags(&pack.BuildFlags{
+					RepoName:   "some/app",
+					Builder:    "some/builder",
+					Buildpacks: []string{bpDir},
+				})
+				h.AssertContains(t, err.Error(), "failed to decode buildpack.toml")
+			})
+		})
+
+		when("a sub-path is provided", func() {
+			it("builds the sub directory of the app dir", func() {
+				mockBuilderImage := mocks.NewMockImage(mockController)
+				mockBuilderImage.EXPECT().Label("io.buildpacks.stack.id").Return("some.stack.id", nil)
+				mockBuilderImage.EXPECT().Label("io.buildpacks.lifecycle.version").Return("0.1.0", nil)
+				mockBuilderImage.EXPECT().Label("io.buildpacks.builder.metadata").Return(`{"runImage": {"image": "some/run"}}`, nil)
+				mockImageFactory.EXPECT().NewLocal("some/builder", true).Return(mockBuilderImage, nil)
+
+				mockRunImage := mocks.NewMockImage(mockController)
+				mockRunImage.EXPECT().Label("io.buildpacks.stack.id").Return("some.stack.id", nil)
+				mockRunImage.EXPECT().Found().Return(true, nil)
+				mockImageFactory.EXPECT().NewLocal("some/run", true).Return(mockRunImage, nil)
+
+				config, err := factory.BuildConfigFromFlags(&pack.BuildFlags{
+					RepoName: "some/app",
+					Builder:  "some/builder",
+					AppDir:   "acceptance",
+					SubPath:  "testdata/node_app",
+				})
+				h.AssertNil(t, err)
+				absAppDir, err := filepath.Abs("acceptance/testdata/node_app")
+				h.AssertNil(t, err)
+				h.AssertEq(t, config.AppDir, absAppDir)
+			})
+
+			it("returns an error when the sub-path is outside of the app dir", func() {
+				_, err := factory.BuildConfigFromFlags(&pack.BuildFlags{
+					RepoName: "some/app",
+					Builder:  "some/builder",
+					AppDir:   "acceptance",
+					SubPath:  "../testdata",
+				})
+				h.AssertError(t, err, "invalid sub-path '../testdata': must be relative to the app directory")
+			})
+		})
+
+		when("an app archive is provided", func() {
+			var tmpDir string
+
+			it.Before(func() {
+				var err error
+				tmpDir, err = ioutil.TempDir("", "pack.build.archive")
+				h.AssertNil(t, err)
+			})
+
+			it.After(func() {
+				os.RemoveAll(tmpDir)
+			})
+
+			it("uses the archive as the app source", func() {
+				mockBuilderImage := mocks.NewMockImage(mockController)
+				mockBuilderImage.EXPECT().Label("io.buildpacks.stack.id").Return("some.stack.id", nil)
+				mockBuilderImage.EXPECT().Label("io.buildpacks.lifecycle.version").Return("0.1.0", nil)
+				mockBuilderImage.EXPECT().Label("io.buildpacks.builder.metadata").Return(`{"runImage": {"image": "some/run"}}`, nil)
+				mockImageFactory.EXPECT().NewLocal("some/builder", true).Return(mockBuilderImage, nil)
+
+				mockRunImage := mocks.NewMockImage(mockController)
+				mockRunImage.EXPECT().Label("io.buildpacks.stack.id").Return("some.stack.id", nil)
+				mockRunImage.EXPECT().Found().Return(true, nil)
+				mockImageFactory.EXPECT().NewLocal("some/run", true).Return(mockRunImage, nil)
+
+				jar := filepath.Join(tmpDir, "app.jar")
+				h.AssertNil(t, ioutil.WriteFile(jar, []byte("PK"), 0644))
+
+				config, err := factory.BuildConfigFromFlags(&pack.BuildFlags{
+					RepoName: "some/app",
+					Builder:  "some/builder",
+					AppDir:   jar,
+				})
+				h.AssertNil(t, err)
+				h.AssertEq(t, config.AppDir, jar)
+				h.AssertEq(t, config.AppArchive, true)
+			})
+
+			it("returns an error for unsupported archive types", func() {
+				rar := filepath.Join(tmpDir, "app.rar")
+				h.AssertNil(t, ioutil.WriteFile(rar, []byte("rar"), 0644))
+
+				_, err := factory.BuildConfigFromFlags(&pack.BuildFlags{
+					RepoName: "some/app",
+					Builder:  "some/builder",
+					AppDir:   rar,
+				})
+				h.AssertContains(t, err.Error(), "unsupported app archive")
+			})
+
+			it("returns an error when a sub-path is also provided", func() {
+				_, err := factory.BuildConfigFromFlags(&pack.BuildFlags{
+					RepoName: "some/app",
+					Builder:  "some/builder",
+					AppDir:   "-",
+					SubPath:  "services/api",
+				})
+				h.AssertError(t, err, "--sub-path and --git-ref cannot be used with an app archive")
+			})
+		})
+
+		when("a git ref is provided", func() {
+			it("returns an error when the ref cannot be resolved", func() {
+				appDir, err := ioutil.TempDir("", "pack.build.not-a-repo")
+				h.AssertNil(t, err)
+				defer os.RemoveAll(appDir)
+
+				_, err = factory.BuildConfigFromFlags(&pack.BuildFlags{
+					RepoName: "some/app",
+					Builder:  "some/builder",
+					AppDir:   appDir,
+					GitRef:   "v1.0.0",
+				})
+				h.AssertContains(t, err.Error(), "failed to resolve git ref 'v1.0.0'")
+			})
+		})
+
+		when("an order file is provided", func() {
+			var orderFile string
+
+			it.Before(func() {
+				f, err := ioutil.TempFile("", "pack.build.order")
+				h.AssertNil(t, err)
+				orderFile = f.Name()
+				h.AssertNil(t, f.Close())
+			})
+
+			it.After(func() {
+				os.Remove(orderFile)
+			})
+
+			it("uses the groups from the order file", func() {
+				h.AssertNil(t, ioutil.WriteFile(orderFile, []byte(`
+[[groups]]
+buildpacks = [
+  { id = "some.bp", version = "1.2.3" },
+  { id = "some.apm.bp", optional = true },
+]
+
+[[groups]]
+buildpacks = [{ id = "other.bp", version = "2.0.0" }]
+`), 0644))
+
+				mockBuilderImage := mocks.NewMockImage(mockController)
+				mockBuilderImage.EXPECT().Label("io.buildpacks.stack.id").Return("some.stack.id", nil)
+				mockBuilderImage.EXPECT().Label("io.buildpacks.lifecycle.version").Return("0.1.0", nil)
+				mockBuilderImage.EXPECT().Label("io.buildpacks.builder.metadata").Return(`{"runImage": {"image": "some/run"}}`, nil)
+				mockImageFactory.EXPECT().NewLocal("some/builder", true).Return(mockBuilderImage, nil)
+
+				mockRunImage := mocks.NewMockImage(mockController)
+				mockRunImage.EXPECT().Label("io.buildpacks.stack.id").Return("some.stack.id", nil)
+				mockRunImage.EXPECT().Found().Return(true, nil)
+				mockImageFactory.EXPECT().NewLocal("some/run", true).Return(mockRunImage, nil)
+
+				config, err := factory.BuildConfigFromFlags(&pack.BuildFlags{
+					RepoName: "some/app",
+					Builder:  "some/builder",
+					Order:    orderFile,
+				})
+				h.AssertNil(t, err)
+				h.AssertEq(t, config.Groups, []lifecycle.BuildpackGroup{
+					{Buildpacks: []*lifecycle.Buildpack{
+						{ID: "some.bp", Version: "1.2.3"},
+						{ID: "some.apm.bp", Version: "latest", Optional: true},
+					}},
+					{Buildpacks: []*lifecycle.Buildpack{
+						{ID: "other.bp", Version: "2.0.0"},
+					}},
+				})
+			})
+
+			it("returns an error when a group only contains optional buildpacks", func() {
+				h.AssertNil(t, ioutil.WriteFile(orderFile, []byte(`
+[[groups]]
+buildpacks = [{ id = "some.apm.bp", version = "1.0.0", optional = true }]
+`), 0644))
+
+				_, err := factory.BuildConfigFromFlags(&pack.BuildFlags{
+					RepoName: "some/app",
+					Builder:  "some/builder",
+					Order:    orderFile,
+				})
+				h.AssertContains(t, err.Error(), "group 1 must contain at least one buildpack that is not optional")
+			})
+
+			it("returns an error when buildpacks are also provided", func() {
+				_, err := factory.BuildConfigFromFlags(&pack.BuildFlags{
+					RepoName:   "some/app",
+					Builder:    "some/builder",
+					Order:      orderFile,
+					Buildpacks: []string{"some.bp"},
+				})
+				h.AssertError(t, err, "--buildpack and --order cannot be used together")
+			})
+		})
 	}, spec.Parallel())
 
+	when("#DefaultBuildFactory", func() {
+		var appDir string
+
+		it.Before(func() {
+			var err error
+			appDir, err = ioutil.TempDir("", "pack.build-factory.app")
+			h.AssertNil(t, err)
+			h.AssertNil(t, ioutil.WriteFile(filepath.Join(appDir, "app.js"), []byte("console.log('hello')"), 0664))
+		})
+
+		it.After(func() {
+			os.RemoveAll(appDir)
+		})
+
+		it("tars the app reproducibly", func() {
+			factory, err := pack.DefaultBuildFactory(logger, mocks.NewMockCache(mockController), mocks.NewMockDocker(mockController), mocks.NewMockImageFactory(mockController))
+			h.AssertNil(t, err)
+
+			appTar := func() []byte {
+				t.Helper()
+				r, errChan := factory.FS.CreateTarReader(appDir, "/workspace/app", 1000, 1000)
+				b, err := ioutil.ReadAll(r)
+				h.AssertNil(t, err)
+				h.AssertNil(t, <-errChan)
+				return b
+			}
+
+			first := appTar()
+			later := time.Now().Add(time.Hour)
+			h.AssertNil(t, os.Chtimes(filepath.Join(appDir, "app.js"), later, later))
+			h.AssertNil(t, os.Chtimes(appDir, later, later))
+
+			h.AssertEq(t, appTar(), first)
+		})
+	})
+
+	when("#DryRun", func() {
+		var mockCache *mocks.MockCache
+
+		it.Before(func() {
+			mockCache = mocks.NewMockCache(mockController)
+			mockCache.EXPECT().Volume().Return("some-volume-name").AnyTimes()
+			subject.Cache = mockCache
+			subject.Cli = mocks.NewMockDocker(mockController)
+			subject.Buildpacks = []string{"some.bp@1.2.3"}
+			subject.EnvFile = map[string]string{"VAR2": "value2", "VAR1": "value1"}
+		})
+
+		it("prints the build plan", func() {
+			h.AssertNil(t, subject.DryRun())
+
+			h.AssertContains(t, outBuf.String(), "Build plan for '"+subject.RepoName+"':")
+			h.AssertContains(t, outBuf.String(), "  Builder:    "+defaultBuilderName)
+			h.AssertContains(t, outBuf.String(), "  Export to:  daemon")
+			h.AssertContains(t, outBuf.String(), "  Cache:      some-volume-name (clear: false)")
+			h.AssertContains(t, outBuf.String(), "  Buildpacks:\n    some.bp@1.2.3")
+			h.AssertContains(t, outBuf.String(), "  Env:        VAR1, VAR2")
+		})
+
+		when("publishing", func() {
+			var dockerConfigDir, oldDockerConfig string
+
+			it.Before(func() {
+				var err error
+				dockerConfigDir, err = ioutil.TempDir("", "pack.dry-run.docker-config")
+				h.AssertNil(t, err)
+				oldDockerConfig = os.Getenv("DOCKER_CONFIG")
+				h.AssertNil(t, os.Setenv("DOCKER_CONFIG", dockerConfigDir))
+				subject.Publish = true
+			})
+
+			it.After(func() {
+				os.Setenv("DOCKER_CONFIG", oldDockerConfig)
+				os.RemoveAll(dockerConfigDir)
+			})
+
+			it("prints the build plan when there are credentials for the registry", func() {
+				h.AssertNil(t, ioutil.WriteFile(filepath.Join(dockerConfigDir, "config.json"), []byte(`{"auths": {"index.docker.io": {"auth": "dXNlcjpwYXNz"}}}`), 0644))
+
+				h.AssertNil(t, subject.DryRun())
+
+				h.AssertContains(t, outBuf.String(), "  Export to:  registry")
+			})
+
+			it("fails when the registry would be reached anonymously", func() {
+				err := subject.DryRun()
+
+				h.AssertError(t, err, "no registry credentials found for '"+subject.RepoName+"': log in to its registry with 'docker login' before publishing")
+			})
+		})
+	})
+
 	when("#Detect", func() {
 		var (
 			mockDockerCli *mocks.MockDocker
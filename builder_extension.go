@@ -0,0 +1,210 @@
+package pack
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/buildpack/lifecycle"
+	"github.com/buildpack/lifecycle/image"
+	"github.com/pkg/errors"
+
+	"github.com/buildpack/pack/style"
+)
+
+// BuildpackRemoval names buildpacks to remove from a builder extended with --from. All versions of the buildpack are
+// removed when Version is empty.
+type BuildpackRemoval struct {
+	ID      string `toml:"id"`
+	Version string `toml:"version"`
+}
+
+func (r BuildpackRemoval) matches(bp BuilderBuildpackMetadata) bool {
+	return r.ID == bp.ID && (r.Version == "" || r.Version == bp.Version)
+}
+
+func removedBuildpack(bp BuilderBuildpackMetadata, removals []BuildpackRemoval) bool {
+	for _, r := range removals {
+		if r.matches(bp) {
+			return true
+		}
+	}
+	return false
+}
+
+// extendedBuilderConfigFromFlags starts from the builder flags.From instead of a build image. Its layers are kept,
+// the buildpacks of builder.toml are added to it, replacing the versions it already contains, and the buildpacks of
+// [[remove]] are removed from it. The groups of builder.toml replace its detection order, which is kept otherwise.
+func (f *BuilderFactory) extendedBuilderConfigFromFlags(flags CreateBuilderFlags) (BuilderConfig, error) {
+	var base image.Image
+	var err error
+	if flags.Publish {
+		base, err = f.ImageFactory.NewRemote(flags.From)
+	} else {
+		base, err = f.ImageFactory.NewLocal(flags.From, !flags.NoPull)
+	}
+	if err != nil {
+		return BuilderConfig{}, errors.Wrapf(err, "opening builder: %s", flags.From)
+	}
+	if found, err := base.Found(); err != nil {
+		return BuilderConfig{}, err
+	} else if !found {
+		return BuilderConfig{}, fmt.Errorf("builder %s not found", style.Symbol(flags.From))
+	}
+	metadata, err := builderImageMetadata(base)
+	if err != nil {
+		return BuilderConfig{}, err
+	}
+	stackID, err := base.Label(StackLabel)
+	if err != nil {
+		return BuilderConfig{}, errors.Wrapf(err, "reading stack of builder: %s", flags.From)
+	}
+
+	// nothing is downloaded before the whole config is known to be valid
+	builderTOML, err := f.validateBuilderConfig(flags.BuilderTomlPath, metadata)
+	if err != nil {
+		return BuilderConfig{}, err
+	}
+	if builderTOML.Stack.ID != "" && builderTOML.Stack.ID != stackID {
+		return BuilderConfig{}, fmt.Errorf("builder %s has stack %s, but the builder config requires stack %s", style.Symbol(flags.From), style.Symbol(stackID), style.Symbol(builderTOML.Stack.ID))
+	}
+	if builderTOML.Stack.BuildImage != "" {
+		f.Logger.Info("Ignoring build-image %s, the builder %s is extended instead", style.Symbol(builderTOML.Stack.BuildImage), style.Symbol(flags.From))
+	}
+
+	builderConfig := BuilderConfig{
+		Repo:            base,
+		BuilderDir:      filepath.Dir(flags.BuilderTomlPath),
+		StackID:         stackID,
+		RunImage:        metadata.RunImage.Image,
+		RunImageMirrors: metadata.RunImage.Mirrors,
+		Groups:          builderTOML.Groups,
+	}
+	if builderConfig.Env, err = mergeEnv(metadata.Env, builderTOML.Env); err != nil {
+		return BuilderConfig{}, errors.Wrapf(err, "reading build environment of builder: %s", flags.From)
+	}
+	if builderTOML.Stack.RunImage != "" {
+		builderConfig.RunImage = builderTOML.Stack.RunImage
+		builderConfig.RunImageMirrors = builderTOML.Stack.RunImageMirrors
+	}
+	for _, bp := range metadata.Buildpacks {
+		if removedBuildpack(bp, builderTOML.Remove) {
+			builderConfig.RemovedBuildpacks = append(builderConfig.RemovedBuildpacks, bp)
+		} else {
+			builderConfig.BaseBuildpacks = append(builderConfig.BaseBuildpacks, bp)
+		}
+	}
+	if len(builderConfig.Groups) == 0 {
+		for _, group := range metadata.Groups {
+			builderGroup := BuilderGroup{Description: group.Description}
+			for _, bp := range group.Buildpacks {
+				builderGroup.Buildpacks = append(builderGroup.Buildpacks, &lifecycle.Buildpack{ID: bp.ID, Version: bp.Version, Optional: bp.Optional})
+			}
+			builderConfig.Groups = append(builderConfig.Groups, builderGroup)
+		}
+	}
+	if len(builderConfig.Groups) == 0 {
+		return BuilderConfig{}, fmt.Errorf("builder %s does not record its groups, the builder config must define [[groups]]", style.Symbol(flags.From))
+	}
+	base.Rename(flags.RepoName)
+	if flags.Publish {
+		builderConfig.PreviousBuildpacks = f.previousBuildpacks(flags.RepoName)
+	}
+
+	if err := f.resolveBuildpacks(&builderConfig, builderTOML.Buildpacks, flags); err != nil {
+		return BuilderConfig{}, err
+	}
+	if err := f.configureLifecycle(&builderConfig, builderTOML.Lifecycle, flags.Offline); err != nil {
+		return BuilderConfig{}, err
+	}
+	return builderConfig, nil
+}
+
+// mergeBuildpacks returns the metadata of the buildpacks of an extended builder, and the buildpacks of the base
+// builder replaced by new buildpacks with the same version. A new latest version takes over from the base builder.
+func mergeBuildpacks(base, added []BuilderBuildpackMetadata) (merged, replaced []BuilderBuildpackMetadata) {
+	for _, bp := range base {
+		replacedBy := -1
+		newLatest := false
+		for i, a := range added {
+			if a.ID == bp.ID && a.Version == bp.Version {
+				replacedBy = i
+			}
+			newLatest = newLatest || (a.ID == bp.ID && a.Latest)
+		}
+		if replacedBy >= 0 {
+			replaced = append(replaced, bp)
+			// the latest link of the replaced version still resolves to the new buildpack
+			added[replacedBy].Latest = added[replacedBy].Latest || (bp.Latest && !newLatest)
+			continue
+		}
+		bp.Latest = bp.Latest && !newLatest
+		merged = append(merged, bp)
+	}
+	return append(merged, added...), replaced
+}
+
+// mergeEnv returns the build environment of an extended builder, the variables of the config override those of the
+// base builder. The names of the base builder come from its metadata label and name files in /platform/env, they are
+// checked like those of the config.
+func mergeEnv(base, added map[string]string) (map[string]string, error) {
+	if len(base) == 0 && len(added) == 0 {
+		return nil, nil
+	}
+	merged := map[string]string{}
+	for name, value := range base {
+		merged[name] = value
+	}
+	for name, value := range added {
+		merged[name] = value
+	}
+	var names []string
+	for name := range merged {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !envNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("invalid environment variable name %s", style.Symbol(name))
+		}
+	}
+	return merged, nil
+}
+
+// whiteoutLayer creates a layer removing the directories of buildpacks from the layers below it, using OCI whiteout
+// files. The latest links of buildpacks without a latest version in remaining are removed as well.
+func (f *BuilderFactory) whiteoutLayer(dest string, removed, remaining []BuilderBuildpackMetadata) (string, error) {
+	tmpDir, err := ioutil.TempDir(dest, "create-builder-whiteouts")
+	if err != nil {
+		return "", err
+	}
+	if err := os.Chmod(tmpDir, 0755); err != nil {
+		return "", err
+	}
+	hasLatest := map[string]bool{}
+	for _, bp := range remaining {
+		hasLatest[bp.ID] = hasLatest[bp.ID] || bp.Latest
+	}
+	for _, bp := range removed {
+		bpDir := filepath.Join(tmpDir, (&Buildpack{ID: bp.ID}).escapedID())
+		if err := os.MkdirAll(bpDir, 0755); err != nil {
+			return "", err
+		}
+		whiteouts := []string{".wh." + bp.Version}
+		if bp.Latest && !hasLatest[bp.ID] {
+			whiteouts = append(whiteouts, ".wh.latest")
+		}
+		for _, name := range whiteouts {
+			if err := ioutil.WriteFile(filepath.Join(bpDir, name), nil, 0644); err != nil {
+				return "", err
+			}
+		}
+	}
+	tarFile := filepath.Join(dest, "whiteouts.tar")
+	if err := f.FS.CreateTarFile(tarFile, tmpDir, buildpacksDir, 0, 0); err != nil {
+		return "", err
+	}
+	return tarFile, nil
+}
@@ -11,12 +11,16 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/BurntSushi/toml"
 	"github.com/buildpack/lifecycle"
 	"github.com/buildpack/lifecycle/image"
 	"github.com/pkg/errors"
 
+	"github.com/buildpack/pack/dlcache"
+	"github.com/buildpack/pack/download"
 	"github.com/buildpack/pack/logging"
 	"github.com/buildpack/pack/style"
 
@@ -24,9 +28,19 @@ import (
 )
 
 type BuilderTOML struct {
-	Buildpacks []Buildpack                `toml:"buildpacks"`
-	Groups     []lifecycle.BuildpackGroup `toml:"groups"`
+	Buildpacks []Buildpack    `toml:"buildpacks"`
+	Groups     []BuilderGroup `toml:"groups"`
 	Stack      Stack
+	Remove     []BuildpackRemoval `toml:"remove"` // only allowed when extending a builder
+	Lifecycle  LifecycleConfig    `toml:"lifecycle"`
+	Env        map[string]string  `toml:"env"`
+}
+
+// BuilderGroup is a detection group of a builder. Its optional buildpacks may fail detection without failing the
+// group, its description is only shown to users of the builder.
+type BuilderGroup struct {
+	Description string                 `toml:"description"`
+	Buildpacks  []*lifecycle.Buildpack `toml:"buildpacks"`
 }
 
 type Stack struct {
@@ -38,11 +52,27 @@ type Stack struct {
 
 type BuilderConfig struct {
 	Buildpacks      []Buildpack
-	Groups          []lifecycle.BuildpackGroup
+	Groups          []BuilderGroup
 	Repo            image.Image
 	BuilderDir      string // original location of builder.toml, used for interpreting relative paths in buildpack URIs
+	StackID         string
 	RunImage        string
 	RunImageMirrors []string
+	// BaseBuildpacks are the buildpacks kept from the builder being extended, RemovedBuildpacks are removed from it
+	BaseBuildpacks    []BuilderBuildpackMetadata
+	RemovedBuildpacks []BuilderBuildpackMetadata
+	// PreviousBuildpacks are the buildpacks of the builder previously published with the same name, whose
+	// unchanged layers are reused
+	PreviousBuildpacks []BuilderBuildpackMetadata
+	// LifecycleDir holds the lifecycle binaries replacing those of the base image, if [lifecycle] is configured
+	LifecycleDir     string
+	LifecycleVersion string
+	// lifecycleTmpDir is the temporary directory a local lifecycle archive is extracted to, removed by Create
+	lifecycleTmpDir string
+	// buildpackTmpDirs are the temporary directories buildpack images are extracted to, removed by Create
+	buildpackTmpDirs []string
+	// Env is the default build environment, values of --env-file passed to build take precedence
+	Env map[string]string
 }
 
 type BuilderFactory struct {
@@ -50,6 +80,7 @@ type BuilderFactory struct {
 	FS           FS
 	Config       *config.Config
 	ImageFactory ImageFactory
+	ImageReader  ImageReader
 }
 
 type CreateBuilderFlags struct {
@@ -57,19 +88,26 @@ type CreateBuilderFlags struct {
 	BuilderTomlPath string
 	Publish         bool
 	NoPull          bool
+	Offline         bool
+	From            string // existing builder to extend instead of the build image of the stack
 }
 
 func (f *BuilderFactory) BuilderConfigFromFlags(flags CreateBuilderFlags) (BuilderConfig, error) {
+	if flags.From != "" {
+		return f.extendedBuilderConfigFromFlags(flags)
+	}
+
 	builderConfig := BuilderConfig{}
 	builderConfig.BuilderDir = filepath.Dir(flags.BuilderTomlPath)
 
-	builderTOML := &BuilderTOML{}
-	_, err := toml.DecodeFile(flags.BuilderTomlPath, &builderTOML)
+	// nothing is pulled or downloaded before the whole config is known to be valid
+	builderTOML, err := f.ValidateBuilderConfig(flags.BuilderTomlPath)
 	if err != nil {
-		return BuilderConfig{}, fmt.Errorf(`failed to decode builder config from file %s: %s`, flags.BuilderTomlPath, err)
+		return BuilderConfig{}, err
 	}
 
 	baseImage := builderTOML.Stack.BuildImage
+	builderConfig.StackID = builderTOML.Stack.ID
 	builderConfig.RunImage = builderTOML.Stack.RunImage
 	builderConfig.RunImageMirrors = builderTOML.Stack.RunImageMirrors
 	if flags.Publish {
@@ -80,24 +118,57 @@ func (f *BuilderFactory) BuilderConfigFromFlags(flags CreateBuilderFlags) (Build
 	if err != nil {
 		return BuilderConfig{}, errors.Wrapf(err, "opening base image: %s", baseImage)
 	}
+	stackID, err := builderConfig.Repo.Label(StackLabel)
+	if err != nil {
+		return BuilderConfig{}, errors.Wrapf(err, "reading stack of base image: %s", baseImage)
+	}
+	if stackID == "" {
+		return BuilderConfig{}, fmt.Errorf("invalid build image %s: missing required label %s", style.Symbol(baseImage), style.Symbol(StackLabel))
+	}
+	if stackID != builderConfig.StackID {
+		return BuilderConfig{}, fmt.Errorf("build image %s has stack %s, but the builder config requires stack %s", style.Symbol(baseImage), style.Symbol(stackID), style.Symbol(builderConfig.StackID))
+	}
 	builderConfig.Repo.Rename(flags.RepoName)
+	if flags.Publish {
+		builderConfig.PreviousBuildpacks = f.previousBuildpacks(flags.RepoName)
+	}
 
 	builderConfig.Groups = builderTOML.Groups
+	builderConfig.Env = builderTOML.Env
+
+	if err := f.resolveBuildpacks(&builderConfig, builderTOML.Buildpacks, flags); err != nil {
+		return BuilderConfig{}, err
+	}
+	if err := f.configureLifecycle(&builderConfig, builderTOML.Lifecycle, flags.Offline); err != nil {
+		return BuilderConfig{}, err
+	}
+	return builderConfig, nil
+}
 
-	for _, b := range builderTOML.Buildpacks {
-		bp, err := f.resolveBuildpackURI(builderConfig.BuilderDir, b)
+func (f *BuilderFactory) resolveBuildpacks(builderConfig *BuilderConfig, buildpacks []Buildpack, flags CreateBuilderFlags) error {
+	builderConfig.Buildpacks = nil
+	for _, b := range buildpacks {
+		bp, err := f.resolveBuildpackURI(builderConfig, b, flags)
 		if err != nil {
-			return BuilderConfig{}, err
+			return err
 		}
 		builderConfig.Buildpacks = append(builderConfig.Buildpacks, bp)
 	}
-	return builderConfig, nil
+	return nil
 }
 
-func (f *BuilderFactory) resolveBuildpackURI(builderDir string, b Buildpack) (Buildpack, error) {
+func (f *BuilderFactory) resolveBuildpackURI(builderConfig *BuilderConfig, b Buildpack, flags CreateBuilderFlags) (Buildpack, error) {
 
 	var dir string
 
+	if strings.HasPrefix(b.URI, registryURIPrefix) {
+		resolved, err := f.resolveRegistryURI(b)
+		if err != nil {
+			return Buildpack{}, err
+		}
+		return f.resolveBuildpackURI(builderConfig, resolved, flags)
+	}
+
 	asurl, err := url.Parse(b.URI)
 	if err != nil {
 		return Buildpack{}, err
@@ -109,7 +180,7 @@ func (f *BuilderFactory) resolveBuildpackURI(builderDir string, b Buildpack) (Bu
 		path := asurl.Path
 
 		if !asurl.IsAbs() && !filepath.IsAbs(path) {
-			path = filepath.Join(builderDir, path)
+			path = filepath.Join(builderConfig.BuilderDir, path)
 		}
 
 		if filepath.Ext(path) == ".tgz" {
@@ -122,52 +193,32 @@ func (f *BuilderFactory) resolveBuildpackURI(builderDir string, b Buildpack) (Bu
 			if err != nil {
 				return Buildpack{}, fmt.Errorf(`failed to create temporary directory: %s`, err)
 			}
-			if err = f.untarZ(file, tmpDir); err != nil {
+			// the directory becomes the buildpack directory in the builder, which must be readable by the build user
+			if err := os.Chmod(tmpDir, 0755); err != nil {
+				return Buildpack{}, err
+			}
+			if err = f.untarVerified(file, tmpDir, b); err != nil {
 				return Buildpack{}, err
 			}
 			dir = tmpDir
 		} else {
+			if b.SHA256 != "" {
+				return Buildpack{}, fmt.Errorf("sha256 of buildpack %s can only be verified for .tgz archives, not directory %q", style.Symbol(b.ID), path)
+			}
 			dir = path
 		}
 	case "http", "https":
-		uriDigest := fmt.Sprintf("%x", sha256.Sum256([]byte(b.URI)))
-		cachedDir := filepath.Join(f.Config.Path(), "dl-cache", uriDigest)
-		_, err := os.Stat(cachedDir)
-		if os.IsNotExist(err) {
-			if err = os.MkdirAll(cachedDir, 0744); err != nil {
-				return Buildpack{}, err
-			}
-		}
-		etagFile := cachedDir + ".etag"
-		bytes, err := ioutil.ReadFile(etagFile)
-		etag := ""
-		if err == nil {
-			etag = string(bytes)
+		dir, err = f.downloadBuildpack(b, flags.Offline)
+		if err != nil {
+			return Buildpack{}, err
 		}
-
-		reader, etag, err := f.downloadAsStream(b.URI, etag)
+	case "docker":
+		var tmpDir string
+		dir, tmpDir, err = f.extractBuildpackage(b, flags)
 		if err != nil {
-			return Buildpack{}, errors.Wrapf(err, "failed to download from %q", b.URI)
-		} else if reader == nil {
-			// can use cached content
-			dir = cachedDir
-			break
-		} else {
-			defer func() {
-				err := reader.Close()
-				if err != nil {
-					fmt.Printf("warning: could not close %v: %s", reader, err)
-				}
-			}()
-			if err = f.untarZ(reader, cachedDir); err != nil {
-				return Buildpack{}, err
-			}
-			if err = ioutil.WriteFile(etagFile, []byte(etag), 0744); err != nil {
-				return Buildpack{}, err
-			}
+			return Buildpack{}, err
 		}
-
-		dir = cachedDir
+		builderConfig.buildpackTmpDirs = append(builderConfig.buildpackTmpDirs, tmpDir)
 	default:
 		return Buildpack{}, fmt.Errorf("unsupported protocol in URI %q", b.URI)
 	}
@@ -185,6 +236,33 @@ func (f *BuilderFactory) Create(config BuilderConfig) error {
 		return fmt.Errorf(`failed to create temporary directory: %s`, err)
 	}
 	defer os.RemoveAll(tmpDir)
+	if config.lifecycleTmpDir != "" {
+		defer os.RemoveAll(config.lifecycleTmpDir)
+	}
+	for _, dir := range config.buildpackTmpDirs {
+		defer os.RemoveAll(dir)
+	}
+
+	if config.LifecycleDir != "" {
+		tarFile, err := f.lifecycleLayer(tmpDir, config.LifecycleDir)
+		if err != nil {
+			return fmt.Errorf(`failed generate lifecycle layer: %s`, err)
+		}
+		if err := config.Repo.AddLayer(tarFile); err != nil {
+			return fmt.Errorf(`failed append lifecycle layer to image: %s`, err)
+		}
+		config.Repo.SetLabel(LifecycleVersionLabel, config.LifecycleVersion)
+	}
+
+	if len(config.Env) > 0 {
+		tarFile, err := f.envLayer(tmpDir, config.Env)
+		if err != nil {
+			return fmt.Errorf(`failed generate build environment layer: %s`, err)
+		}
+		if err := config.Repo.AddLayer(tarFile); err != nil {
+			return fmt.Errorf(`failed append build environment layer to image: %s`, err)
+		}
+	}
 
 	orderTar, err := f.orderLayer(tmpDir, config.Groups)
 	if err != nil {
@@ -193,12 +271,40 @@ func (f *BuilderFactory) Create(config BuilderConfig) error {
 	if err := config.Repo.AddLayer(orderTar); err != nil {
 		return fmt.Errorf(`failed append order.toml layer to image: %s`, err)
 	}
+	// the versions of the buildpacks are only known once their layers are generated, and the layer removing the
+	// versions they replace in an extended builder must come before them
+	var buildpackTars []string
+	var newBuildpacks []BuilderBuildpackMetadata
 	for _, buildpack := range config.Buildpacks {
-		tarFile, err := f.buildpackLayer(tmpDir, buildpack, config.BuilderDir)
+		tarFile, err := f.buildpackLayer(tmpDir, &buildpack, config.BuilderDir)
 		if err != nil {
 			return fmt.Errorf(`failed to generate layer for buildpack %s: %s`, style.Symbol(buildpack.ID), err)
 		}
+		diffID, err := layerDiffID(tarFile)
+		if err != nil {
+			return fmt.Errorf(`failed to compute digest of layer for buildpack %s: %s`, style.Symbol(buildpack.ID), err)
+		}
+		buildpackTars = append(buildpackTars, tarFile)
+		newBuildpacks = append(newBuildpacks, BuilderBuildpackMetadata{
+			ID:          buildpack.ID,
+			Version:     buildpack.Version,
+			Latest:      buildpack.Latest,
+			LayerDiffID: diffID,
+		})
+	}
+	buildpacksMetadata, removed := mergeBuildpacks(config.BaseBuildpacks, newBuildpacks)
+	removed = append(removed, config.RemovedBuildpacks...)
+	if len(removed) > 0 {
+		tarFile, err := f.whiteoutLayer(tmpDir, removed, buildpacksMetadata)
+		if err != nil {
+			return fmt.Errorf(`failed generate layer removing buildpacks: %s`, err)
+		}
 		if err := config.Repo.AddLayer(tarFile); err != nil {
+			return fmt.Errorf(`failed append layer removing buildpacks to image: %s`, err)
+		}
+	}
+	for i, tarFile := range buildpackTars {
+		if err := f.addBuildpackLayer(config.Repo, tarFile, newBuildpacks[i], config.PreviousBuildpacks); err != nil {
 			return fmt.Errorf(`failed append buildpack layer to image: %s`, err)
 		}
 	}
@@ -210,14 +316,30 @@ func (f *BuilderFactory) Create(config BuilderConfig) error {
 		return fmt.Errorf(`failed append latest link layer to image: %s`, err)
 	}
 
+	var groupsMetadata []BuilderGroupMetadata
+	for _, group := range config.Groups {
+		groupMetadata := BuilderGroupMetadata{Description: group.Description, Buildpacks: []BuilderGroupBuildpackMetadata{}}
+		for _, bp := range group.Buildpacks {
+			groupMetadata.Buildpacks = append(groupMetadata.Buildpacks, BuilderGroupBuildpackMetadata{ID: bp.ID, Version: bp.Version, Optional: bp.Optional})
+		}
+		groupsMetadata = append(groupsMetadata, groupMetadata)
+	}
+
 	jsonBytes, err := json.Marshal(&BuilderImageMetadata{
-		RunImage: BuilderRunImageMetadata{Image: config.RunImage, Mirrors: config.RunImageMirrors},
+		RunImage:         BuilderRunImageMetadata{Image: config.RunImage, Mirrors: config.RunImageMirrors},
+		Buildpacks:       buildpacksMetadata,
+		Groups:           groupsMetadata,
+		Env:              config.Env,
+		LifecycleVersion: config.LifecycleVersion,
 	})
 	if err != nil {
 		return fmt.Errorf(`failed marshal builder image metadata: %s`, err)
 	}
 
 	config.Repo.SetLabel(BuilderMetadataLabel, string(jsonBytes))
+	if config.StackID != "" {
+		config.Repo.SetLabel(StackLabel, config.StackID)
+	}
 
 	if _, err := config.Repo.Save(); err != nil {
 		return err
@@ -229,7 +351,7 @@ type order struct {
 	Groups []lifecycle.BuildpackGroup `toml:"groups"`
 }
 
-func (f *BuilderFactory) orderLayer(dest string, groups []lifecycle.BuildpackGroup) (layerTar string, err error) {
+func (f *BuilderFactory) orderLayer(dest string, groups []BuilderGroup) (layerTar string, err error) {
 	buildpackDir := filepath.Join(dest, "buildpack")
 	err = os.Mkdir(buildpackDir, 0755)
 	if err != nil {
@@ -241,7 +363,11 @@ func (f *BuilderFactory) orderLayer(dest string, groups []lifecycle.BuildpackGro
 		return "", err
 	}
 	defer orderFile.Close()
-	err = toml.NewEncoder(orderFile).Encode(order{Groups: groups})
+	var lifecycleGroups []lifecycle.BuildpackGroup
+	for _, group := range groups {
+		lifecycleGroups = append(lifecycleGroups, lifecycle.BuildpackGroup{Buildpacks: group.Buildpacks})
+	}
+	err = toml.NewEncoder(orderFile).Encode(order{Groups: lifecycleGroups})
 	if err != nil {
 		return "", err
 	}
@@ -252,6 +378,25 @@ func (f *BuilderFactory) orderLayer(dest string, groups []lifecycle.BuildpackGro
 	return layerTar, nil
 }
 
+// envLayer creates a layer holding a file in /platform/env for each variable of the build environment, which the
+// lifecycle provides to buildpacks
+func (f *BuilderFactory) envLayer(dest string, env map[string]string) (layerTar string, err error) {
+	envDir := filepath.Join(dest, "env")
+	if err := os.Mkdir(envDir, 0755); err != nil {
+		return "", err
+	}
+	for name, value := range env {
+		if err := ioutil.WriteFile(filepath.Join(envDir, name), []byte(value), 0644); err != nil {
+			return "", err
+		}
+	}
+	layerTar = filepath.Join(dest, "env.tar")
+	if err := f.FS.CreateTarFile(layerTar, envDir, "/platform/env", 0, 0); err != nil {
+		return "", err
+	}
+	return layerTar, nil
+}
+
 type BuildpackData struct {
 	BP struct {
 		ID      string `toml:"id"`
@@ -261,11 +406,11 @@ type BuildpackData struct {
 
 // buildpackLayer creates and returns the location of a tgz file for a buildpack layer. That file will reside in the `dest` directory.
 // The tgz file is either created from an initially local directory, or it is downloaded (and validated) from
-// a remote location if the buildpack uri uses the http(s) protocol.
-func (f *BuilderFactory) buildpackLayer(dest string, buildpack Buildpack, builderDir string) (layerTar string, err error) {
+// a remote location if the buildpack uri uses the http(s) protocol. The version read from buildpack.toml is recorded on `buildpack`.
+func (f *BuilderFactory) buildpackLayer(dest string, buildpack *Buildpack, builderDir string) (layerTar string, err error) {
 	dir := buildpack.Dir
 
-	data, err := f.buildpackData(buildpack, dir)
+	data, err := f.buildpackData(*buildpack, dir)
 	if err != nil {
 		return "", err
 	}
@@ -276,9 +421,10 @@ func (f *BuilderFactory) buildpackLayer(dest string, buildpack Buildpack, builde
 	if bp.Version == "" {
 		return "", fmt.Errorf("buildpack.toml must provide version: %s", filepath.Join(buildpack.Dir, "buildpack.toml"))
 	}
+	buildpack.Version = bp.Version
 
 	tarFile := filepath.Join(dest, fmt.Sprintf("%s.%s.tar", buildpack.escapedID(), bp.Version))
-	if err := f.FS.CreateTarFile(tarFile, dir, filepath.Join("/buildpacks", buildpack.escapedID(), bp.Version), 0, 0); err != nil {
+	if err := f.FS.CreateTarFile(tarFile, dir, buildpack.layerDir(), 0, 0); err != nil {
 		return "", err
 	}
 	return tarFile, err
@@ -307,6 +453,9 @@ func (f *BuilderFactory) latestLayer(buildpacks []Buildpack, dest, builderDir st
 	if err != nil {
 		return "", err
 	}
+	if err := os.Chmod(tmpDir, 0755); err != nil {
+		return "", err
+	}
 	for _, bp := range buildpacks {
 		if bp.Latest {
 			data, err := f.buildpackData(bp, bp.Dir)
@@ -330,26 +479,126 @@ func (f *BuilderFactory) latestLayer(buildpacks []Buildpack, dest, builderDir st
 	return tarFile, err
 }
 
-func (f *BuilderFactory) downloadAsStream(uri string, etag string) (io.ReadCloser, string, error) {
-	c := http.Client{}
-	req, err := http.NewRequest("GET", uri, nil)
+// downloadBuildpack downloads and extracts a buildpack archive into the download cache, returning its directory.
+// Archives are extracted to a temporary directory that replaces the cache entry once complete, so an interrupted
+// download never leaves a partially populated entry behind. In offline mode only cached entries are used.
+func (f *BuilderFactory) downloadBuildpack(b Buildpack, offline bool) (string, error) {
+	cache := dlcache.New(f.Config.Path())
+	cached, found, err := cache.Lookup(b.URI)
 	if err != nil {
-		return nil, "", err
+		return "", err
 	}
-	if etag != "" {
-		req.Header.Set("If-None-Match", etag)
+	if found && b.SHA256 != "" && !strings.EqualFold(cached.SHA256, b.SHA256) {
+		f.Logger.Verbose("Cached archive of %q does not match sha256 %s", b.URI, b.SHA256)
+		found = false
 	}
-	if resp, err := c.Do(req); err != nil {
-		return nil, "", err
-	} else {
-		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-			f.Logger.Verbose("Downloading from %q\n", uri)
-			return resp.Body, resp.Header.Get("Etag"), nil
-		} else if resp.StatusCode == 304 {
-			f.Logger.Verbose("Using cached version of %q\n", uri)
-			return nil, etag, nil
-		} else {
-			return nil, "", fmt.Errorf("could not download from %q, code http status %d", uri, resp.StatusCode)
+
+	if offline {
+		if !found {
+			return "", fmt.Errorf("buildpack %s from %q is not in the download cache, run without --offline to download it", style.Symbol(b.ID), b.URI)
 		}
+		f.Logger.Verbose("Using cached version of %q\n", b.URI)
+		return cache.Path(b.URI), nil
+	}
+
+	etag := ""
+	if found {
+		etag = cached.ETag
+	}
+	c, err := download.NewClient(f.Config.Download, f.Logger)
+	if err != nil {
+		return "", errors.Wrap(err, "configuring download client")
+	}
+	// a download interrupted while it is extracted is retried from the start
+	if err := c.Download(b.URI, etag, func(resp *http.Response) error {
+		return f.storeDownload(cache, b, resp)
+	}); err != nil {
+		return "", errors.Wrapf(err, "failed to download from %q", b.URI)
+	}
+	return cache.Path(b.URI), nil
+}
+
+// storeDownload extracts the downloaded archive of b into the download cache. The cache entry is kept when the
+// server reports that it is not modified.
+func (f *BuilderFactory) storeDownload(cache *dlcache.Cache, b Buildpack, resp *http.Response) error {
+	if resp.StatusCode == http.StatusNotModified {
+		f.Logger.Verbose("Using cached version of %q\n", b.URI)
+		return nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("http status %d", resp.StatusCode)
+	}
+	f.Logger.Verbose("Downloading from %q\n", b.URI)
+
+	tmpDir, err := cache.TempDir(b.URI)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	counter := &countingReader{r: resp.Body}
+	digest, err := f.untarZDigest(counter, tmpDir)
+	if err != nil {
+		return errors.Wrap(err, "failed to extract archive")
+	}
+	if err := verifySHA256(b, digest); err != nil {
+		return err
+	}
+
+	entry := dlcache.Entry{
+		URI:          b.URI,
+		ETag:         resp.Header.Get("Etag"),
+		SHA256:       digest,
+		Size:         counter.n,
+		DownloadedAt: time.Now().UTC(),
+	}
+	if data, err := f.buildpackData(b, tmpDir); err == nil {
+		entry.BuildpackID = data.BP.ID
+		entry.BuildpackVersion = data.BP.Version
+	}
+	if err := cache.Store(tmpDir, entry); err != nil {
+		return errors.Wrap(err, "storing download in the download cache")
+	}
+	return nil
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// untarVerified extracts a gzipped buildpack archive, verifying its sha256 when the builder config provides one
+func (f *BuilderFactory) untarVerified(r io.Reader, dir string, b Buildpack) error {
+	digest, err := f.untarZDigest(r, dir)
+	if err != nil {
+		return err
+	}
+	return verifySHA256(b, digest)
+}
+
+// untarZDigest extracts a gzipped archive and returns the hex encoded sha256 of the compressed archive
+func (f *BuilderFactory) untarZDigest(r io.Reader, dir string) (string, error) {
+	hash := sha256.New()
+	tee := io.TeeReader(r, hash)
+	if err := f.untarZ(tee, dir); err != nil {
+		return "", err
+	}
+	// the digest covers the whole archive, including any trailing bytes the gzip and tar readers did not consume
+	if _, err := io.Copy(ioutil.Discard, tee); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", hash.Sum(nil)), nil
+}
+
+func verifySHA256(b Buildpack, digest string) error {
+	if b.SHA256 == "" || strings.EqualFold(b.SHA256, digest) {
+		return nil
 	}
+	return fmt.Errorf("checksum mismatch for buildpack %s from %q: expected sha256 %s, got %s", style.Symbol(b.ID), b.URI, b.SHA256, digest)
 }
@@ -1,22 +1,33 @@
 package pack_test
 
 import (
+	"archive/tar"
 	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/sha256"
+	"encoding/json"
 	"fmt"
 	"github.com/buildpack/pack/logging"
 	"github.com/fatih/color"
+	"io"
 	"io/ioutil"
 	"math/rand"
 	"net/http"
+	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/buildpack/lifecycle"
 	"github.com/golang/mock/gomock"
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
 	"github.com/sclevine/spec"
 	"github.com/sclevine/spec/report"
 
@@ -76,6 +87,7 @@ func testBuilderFactory(t *testing.T, when spec.G, it spec.S) {
 				mockBaseImage := mocks.NewMockImage(mockController)
 				mockImageFactory.EXPECT().NewLocal("some/build", true).Return(mockBaseImage, nil)
 				mockBaseImage.EXPECT().Rename("some/image")
+				mockBaseImage.EXPECT().Label("io.buildpacks.stack.id").Return("com.example.stack", nil)
 
 				cfg, err := factory.BuilderConfigFromFlags(pack.CreateBuilderFlags{
 					RepoName:        "some/image",
@@ -90,12 +102,14 @@ func testBuilderFactory(t *testing.T, when spec.G, it spec.S) {
 				h.AssertEq(t, cfg.BuilderDir, "testdata")
 				h.AssertEq(t, cfg.RunImage, "some/run")
 				h.AssertEq(t, cfg.RunImageMirrors, []string{"gcr.io/some/run2"})
+				h.AssertEq(t, cfg.StackID, "com.example.stack")
 			})
 
 			it("doesn't pull a new base image when --no-pull flag is provided", func() {
 				mockBaseImage := mocks.NewMockImage(mockController)
 				mockImageFactory.EXPECT().NewLocal("some/build", false).Return(mockBaseImage, nil)
 				mockBaseImage.EXPECT().Rename("some/image")
+				mockBaseImage.EXPECT().Label("io.buildpacks.stack.id").Return("com.example.stack", nil)
 
 				config, err := factory.BuilderConfigFromFlags(pack.CreateBuilderFlags{
 					RepoName:        "some/image",
@@ -123,11 +137,39 @@ func testBuilderFactory(t *testing.T, when spec.G, it spec.S) {
 				}
 			})
 
+			it("fails if the build image has a different stack", func() {
+				mockBaseImage := mocks.NewMockImage(mockController)
+				mockImageFactory.EXPECT().NewLocal("some/build", true).Return(mockBaseImage, nil)
+				mockBaseImage.EXPECT().Label("io.buildpacks.stack.id").Return("other.stack", nil)
+
+				_, err := factory.BuilderConfigFromFlags(pack.CreateBuilderFlags{
+					RepoName:        "some/image",
+					BuilderTomlPath: filepath.Join("testdata", "builder.toml"),
+				})
+				h.AssertError(t, err, "build image 'some/build' has stack 'other.stack', but the builder config requires stack 'com.example.stack'")
+			})
+
+			it("fails if the build image has no stack label", func() {
+				mockBaseImage := mocks.NewMockImage(mockController)
+				mockImageFactory.EXPECT().NewLocal("some/build", true).Return(mockBaseImage, nil)
+				mockBaseImage.EXPECT().Label("io.buildpacks.stack.id").Return("", nil)
+
+				_, err := factory.BuilderConfigFromFlags(pack.CreateBuilderFlags{
+					RepoName:        "some/image",
+					BuilderTomlPath: filepath.Join("testdata", "builder.toml"),
+				})
+				h.AssertError(t, err, "invalid build image 'some/build': missing required label 'io.buildpacks.stack.id'")
+			})
+
 			when("--publish is passed", func() {
 				it("uses a registry store and doesn't pull base image", func() {
 					mockBaseImage := mocks.NewMockImage(mockController)
 					mockImageFactory.EXPECT().NewRemote("some/build").Return(mockBaseImage, nil)
 					mockBaseImage.EXPECT().Rename("some/image")
+					mockBaseImage.EXPECT().Label("io.buildpacks.stack.id").Return("com.example.stack", nil)
+					mockPreviousImage := mocks.NewMockImage(mockController)
+					mockImageFactory.EXPECT().NewRemote("some/image").Return(mockPreviousImage, nil)
+					mockPreviousImage.EXPECT().Found().Return(false, nil)
 
 					config, err := factory.BuilderConfigFromFlags(pack.CreateBuilderFlags{
 						RepoName:        "some/image",
@@ -141,16 +183,47 @@ func testBuilderFactory(t *testing.T, when spec.G, it spec.S) {
 					checkBuildpacks(t, config.Buildpacks)
 					checkGroups(t, config.Groups)
 					h.AssertEq(t, config.BuilderDir, "testdata")
+					h.AssertEq(t, len(config.PreviousBuildpacks), 0)
+				})
+
+				it("records the buildpacks of the builder previously published with the same name", func() {
+					mockBaseImage := mocks.NewMockImage(mockController)
+					mockImageFactory.EXPECT().NewRemote("some/build").Return(mockBaseImage, nil)
+					mockBaseImage.EXPECT().Rename("some/image")
+					mockBaseImage.EXPECT().Label("io.buildpacks.stack.id").Return("com.example.stack", nil)
+					mockPreviousImage := mocks.NewMockImage(mockController)
+					mockImageFactory.EXPECT().NewRemote("some/image").Return(mockPreviousImage, nil)
+					mockPreviousImage.EXPECT().Found().Return(true, nil)
+					mockPreviousImage.EXPECT().Label("io.buildpacks.builder.metadata").Return(`{"runImage":{"image":"some/run","mirrors":null},"buildpacks":[{"id":"some.bp1","version":"1.2.3","latest":false,"layerDiffId":"sha256:abc"}]}`, nil)
+
+					config, err := factory.BuilderConfigFromFlags(pack.CreateBuilderFlags{
+						RepoName:        "some/image",
+						BuilderTomlPath: filepath.Join("testdata", "builder.toml"),
+						Publish:         true,
+					})
+					h.AssertNil(t, err)
+					h.AssertEq(t, config.PreviousBuildpacks, []pack.BuilderBuildpackMetadata{{ID: "some.bp1", Version: "1.2.3", LayerDiffID: "sha256:abc"}})
 				})
 			})
 		})
 
 		when("#Create", func() {
-			var mockImage *mocks.MockImage
+			var (
+				mockImage *mocks.MockImage
+				diffIDs   []string
+				files     map[string]string
+			)
 
 			it.Before(func() {
+				diffIDs = nil
+				files = map[string]string{}
 				mockImage = mocks.NewMockImage(mockController)
-				mockImage.EXPECT().AddLayer(gomock.Any()).AnyTimes()
+				mockImage.EXPECT().AddLayer(gomock.Any()).Do(func(layerTar string) {
+					diffIDs = append(diffIDs, layerDiffID(t, layerTar))
+					for name, contents := range tarFiles(t, layerTar) {
+						files[name] = contents
+					}
+				}).AnyTimes()
 				mockImage.EXPECT().Save()
 			})
 
@@ -160,13 +233,148 @@ func testBuilderFactory(t *testing.T, when spec.G, it spec.S) {
 				err := factory.Create(pack.BuilderConfig{
 					Repo:            mockImage,
 					Buildpacks:      []pack.Buildpack{},
-					Groups:          []lifecycle.BuildpackGroup{},
+					Groups:          []pack.BuilderGroup{},
 					BuilderDir:      "",
 					RunImage:        "myorg/run",
 					RunImageMirrors: []string{"gcr.io/myorg/run"},
 				})
 				h.AssertNil(t, err)
 			})
+
+			it("stores metadata about the buildpacks in the builder", func() {
+				var label string
+				mockImage.EXPECT().SetLabel("io.buildpacks.builder.metadata", gomock.Any()).Do(func(_, value string) {
+					label = value
+				})
+
+				bpDir, err := ioutil.TempDir("", "create-builder-buildpack")
+				h.AssertNil(t, err)
+				defer os.RemoveAll(bpDir)
+				h.AssertNil(t, ioutil.WriteFile(filepath.Join(bpDir, "buildpack.toml"), []byte(`[buildpack]
+id = "some.bp"
+version = "1.2.3"
+`), 0644))
+
+				err = factory.Create(pack.BuilderConfig{
+					Repo:       mockImage,
+					Buildpacks: []pack.Buildpack{{ID: "some.bp", Dir: bpDir, Latest: true}},
+					Groups:     []pack.BuilderGroup{},
+					RunImage:   "myorg/run",
+				})
+				h.AssertNil(t, err)
+				h.AssertEq(t, len(diffIDs), 3)
+				h.AssertEq(t, label, fmt.Sprintf(`{"runImage":{"image":"myorg/run","mirrors":null},"buildpacks":[{"id":"some.bp","version":"1.2.3","latest":true,"layerDiffId":"%s"}]}`, diffIDs[1]))
+			})
+
+			it("writes the build environment to /platform/env and stores it", func() {
+				mockImage.EXPECT().SetLabel("io.buildpacks.builder.metadata", `{"runImage":{"image":"myorg/run","mirrors":null},"env":{"BP_NO_TELEMETRY":"1","MIRROR_URL":"https://mirror.example.com"}}`)
+
+				err := factory.Create(pack.BuilderConfig{
+					Repo:     mockImage,
+					Env:      map[string]string{"BP_NO_TELEMETRY": "1", "MIRROR_URL": "https://mirror.example.com"},
+					RunImage: "myorg/run",
+				})
+				h.AssertNil(t, err)
+				h.AssertEq(t, files["/platform/env/BP_NO_TELEMETRY"], "1")
+				h.AssertEq(t, files["/platform/env/MIRROR_URL"], "https://mirror.example.com")
+			})
+
+			when("the builder was published before", func() {
+				var (
+					bpDir  string
+					bpTar  string
+					config pack.BuilderConfig
+				)
+
+				it.Before(func() {
+					var err error
+					bpDir, err = ioutil.TempDir("", "create-builder-buildpack")
+					h.AssertNil(t, err)
+					h.AssertNil(t, os.MkdirAll(filepath.Join(bpDir, "bp"), 0755))
+					h.AssertNil(t, ioutil.WriteFile(filepath.Join(bpDir, "bp", "buildpack.toml"), []byte("[buildpack]\nid = \"some.bp\"\nversion = \"1.2.3\"\n"), 0644))
+					bpTar = filepath.Join(bpDir, "bp.tar")
+					h.AssertNil(t, (&fs.FS{}).CreateTarFile(bpTar, filepath.Join(bpDir, "bp"), "/buildpacks/some.bp/1.2.3", 0, 0))
+
+					mockImage.EXPECT().SetLabel("io.buildpacks.builder.metadata", gomock.Any())
+					config = pack.BuilderConfig{
+						Repo:       mockImage,
+						Buildpacks: []pack.Buildpack{{ID: "some.bp", Dir: filepath.Join(bpDir, "bp")}},
+						Groups:     []pack.BuilderGroup{},
+						RunImage:   "myorg/run",
+					}
+				})
+
+				it.After(func() {
+					os.RemoveAll(bpDir)
+				})
+
+				it("reuses the layers of unchanged buildpacks", func() {
+					diffID := layerDiffID(t, bpTar)
+					config.PreviousBuildpacks = []pack.BuilderBuildpackMetadata{{ID: "some.bp", Version: "1.2.3", LayerDiffID: diffID}}
+					mockImage.EXPECT().ReuseLayer(diffID)
+
+					h.AssertNil(t, factory.Create(config))
+					h.AssertEq(t, len(diffIDs), 2)
+					h.AssertNotContains(t, strings.Join(diffIDs, " "), diffID)
+				})
+
+				it("adds the layers of changed buildpacks", func() {
+					config.PreviousBuildpacks = []pack.BuilderBuildpackMetadata{{ID: "some.bp", Version: "1.2.3", LayerDiffID: "sha256:changed"}}
+
+					h.AssertNil(t, factory.Create(config))
+					h.AssertEq(t, len(diffIDs), 3)
+					h.AssertEq(t, diffIDs[1], layerDiffID(t, bpTar))
+				})
+
+				it("adds the layer when the previous layer cannot be reused", func() {
+					diffID := layerDiffID(t, bpTar)
+					config.PreviousBuildpacks = []pack.BuilderBuildpackMetadata{{ID: "some.bp", Version: "1.2.3", LayerDiffID: diffID}}
+					mockImage.EXPECT().ReuseLayer(diffID).Return(fmt.Errorf("previous image did not have layer with sha '%s'", diffID))
+
+					h.AssertNil(t, factory.Create(config))
+					h.AssertEq(t, len(diffIDs), 3)
+					h.AssertEq(t, diffIDs[1], diffID)
+				})
+			})
+
+			it("stores the groups and the stack of the builder", func() {
+				mockImage.EXPECT().SetLabel("io.buildpacks.builder.metadata", `{"runImage":{"image":"myorg/run","mirrors":null},"groups":[{"description":"Java apps with opt-in APM","buildpacks":[{"id":"some.bp","version":"latest"},{"id":"other.bp","version":"1.0.0","optional":true}]}]}`)
+				mockImage.EXPECT().SetLabel("io.buildpacks.stack.id", "com.example.stack")
+
+				err := factory.Create(pack.BuilderConfig{
+					Repo: mockImage,
+					Groups: []pack.BuilderGroup{{
+						Description: "Java apps with opt-in APM",
+						Buildpacks: []*lifecycle.Buildpack{
+							{ID: "some.bp", Version: "latest"},
+							{ID: "other.bp", Version: "1.0.0", Optional: true},
+						},
+					}},
+					StackID:  "com.example.stack",
+					RunImage: "myorg/run",
+				})
+				h.AssertNil(t, err)
+			})
+
+			it("writes optional buildpacks but not descriptions to order.toml", func() {
+				mockImage.EXPECT().SetLabel("io.buildpacks.builder.metadata", gomock.Any())
+
+				err := factory.Create(pack.BuilderConfig{
+					Repo: mockImage,
+					Groups: []pack.BuilderGroup{{
+						Description: "Java apps with opt-in APM",
+						Buildpacks: []*lifecycle.Buildpack{
+							{ID: "some.bp", Version: "latest"},
+							{ID: "other.bp", Version: "1.0.0", Optional: true},
+						},
+					}},
+					RunImage: "myorg/run",
+				})
+				h.AssertNil(t, err)
+				h.AssertContains(t, files["/buildpacks/order.toml"], `version = "1.0.0"
+    optional = true`)
+				h.AssertNotContains(t, files["/buildpacks/order.toml"], "description")
+			})
 		})
 
 		when("a buildpack location uses no scheme uris", func() {
@@ -174,6 +382,7 @@ func testBuilderFactory(t *testing.T, when spec.G, it spec.S) {
 				mockImage := mocks.NewMockImage(mockController)
 				mockImageFactory.EXPECT().NewLocal("some/build", false).Return(mockImage, nil)
 				mockImage.EXPECT().Rename("myorg/mybuilder")
+				mockImage.EXPECT().Label("io.buildpacks.stack.id").Return("com.example.stack", nil)
 
 				flags := pack.CreateBuilderFlags{
 					RepoName:        "myorg/mybuilder",
@@ -192,6 +401,7 @@ func testBuilderFactory(t *testing.T, when spec.G, it spec.S) {
 				mockImage := mocks.NewMockImage(mockController)
 				mockImageFactory.EXPECT().NewLocal("some/build", false).Return(mockImage, nil)
 				mockImage.EXPECT().Rename("myorg/mybuilder")
+				mockImage.EXPECT().Label("io.buildpacks.stack.id").Return("com.example.stack", nil)
 
 				absPath, err := filepath.Abs("testdata/used-to-test-various-uri-schemes/buildpack")
 				h.AssertNil(t, err)
@@ -199,22 +409,17 @@ func testBuilderFactory(t *testing.T, when spec.G, it spec.S) {
 				f, err := ioutil.TempFile("", "*.toml")
 				h.AssertNil(t, err)
 				ioutil.WriteFile(f.Name(), []byte(fmt.Sprintf(`[[buildpacks]]
-id = "some.bp.with.no.uri.scheme"
+id = "some.bp"
 uri = "%s"
 
 [[buildpacks]]
-id = "some.bp.with.no.uri.scheme.and.tgz"
+id = "some.other.bp"
 uri = "%s.tgz"
 
 [[groups]]
 buildpacks = [
-  { id = "some.bp.with.no.uri.scheme", version = "1.2.3" },
-  { id = "some.bp.with.no.uri.scheme.and.tgz", version = "1.2.4" },
-]
-
-[[groups]]
-buildpacks = [
-  { id = "some.bp1", version = "1.2.3" },
+  { id = "some.bp", version = "1.2.3" },
+  { id = "some.other.bp", version = "1.2.4" },
 ]
 
 [stack]
@@ -243,6 +448,7 @@ run-image = "some/run"
 				mockImage := mocks.NewMockImage(mockController)
 				mockImageFactory.EXPECT().NewLocal("some/build", false).Return(mockImage, nil)
 				mockImage.EXPECT().Rename("myorg/mybuilder")
+				mockImage.EXPECT().Label("io.buildpacks.stack.id").Return("com.example.stack", nil)
 
 				absPath, err := filepath.Abs("testdata/used-to-test-various-uri-schemes/buildpack")
 				h.AssertNil(t, err)
@@ -250,22 +456,17 @@ run-image = "some/run"
 				f, err := ioutil.TempFile("", "*.toml")
 				h.AssertNil(t, err)
 				ioutil.WriteFile(f.Name(), []byte(fmt.Sprintf(`[[buildpacks]]
-id = "some.bp.with.no.uri.scheme"
+id = "some.bp"
 uri = "file://%s"
 
 [[buildpacks]]
-id = "some.bp.with.no.uri.scheme.and.tgz"
+id = "some.other.bp"
 uri = "file://%s.tgz"
 
 [[groups]]
 buildpacks = [
-  { id = "some.bp.with.no.uri.scheme", version = "1.2.3" },
-  { id = "some.bp.with.no.uri.scheme.and.tgz", version = "1.2.4" },
-]
-
-[[groups]]
-buildpacks = [
-  { id = "some.bp1", version = "1.2.3" },
+  { id = "some.bp", version = "1.2.3" },
+  { id = "some.other.bp", version = "1.2.4" },
 ]
 
 [stack]
@@ -289,6 +490,273 @@ run-image = "some/run"
 				h.AssertDirContainsFileWithContents(t, builderConfig.Buildpacks[1].Dir, "bin/build", "I come from an archive")
 			})
 		})
+		when("a buildpack location uses urn:cnb:registry uris", func() {
+			var builderToml string
+
+			it.Before(func() {
+				bpDir, err := filepath.Abs(filepath.Join("testdata", "used-to-test-various-uri-schemes", "buildpack"))
+				h.AssertNil(t, err)
+				index, err := ioutil.TempFile("", "*.toml")
+				h.AssertNil(t, err)
+				h.AssertNil(t, ioutil.WriteFile(index.Name(), []byte(fmt.Sprintf(`[[buildpacks]]
+id = "some.bp"
+version = "1.0.0"
+uri = "file://%s"
+
+[[buildpacks]]
+id = "some.bp"
+version = "1.2.3"
+uri = "file://%s"
+`, bpDir, bpDir)), 0644))
+				factory.Config.RegistryIndex = index.Name()
+
+				f, err := ioutil.TempFile("", "*.toml")
+				h.AssertNil(t, err)
+				builderToml = f.Name()
+			})
+
+			writeBuilderToml := func(uri string) {
+				h.AssertNil(t, ioutil.WriteFile(builderToml, []byte(fmt.Sprintf(`[[buildpacks]]
+id = "some.bp"
+uri = "%s"
+
+[stack]
+id = "com.example.stack"
+build-image = "some/build"
+run-image = "some/run"
+`, uri)), 0644))
+			}
+
+			it("resolves the buildpack from the registry index", func() {
+				mockImage := mocks.NewMockImage(mockController)
+				mockImageFactory.EXPECT().NewLocal("some/build", false).Return(mockImage, nil)
+				mockImage.EXPECT().Rename("myorg/mybuilder")
+				mockImage.EXPECT().Label("io.buildpacks.stack.id").Return("com.example.stack", nil)
+
+				writeBuilderToml("urn:cnb:registry:some.bp@1.2.3")
+				builderConfig, err := factory.BuilderConfigFromFlags(pack.CreateBuilderFlags{
+					RepoName:        "myorg/mybuilder",
+					BuilderTomlPath: builderToml,
+					NoPull:          true,
+				})
+				h.AssertNil(t, err)
+				h.AssertDirContainsFileWithContents(t, builderConfig.Buildpacks[0].Dir, "bin/detect", "I come from a directory")
+			})
+
+			it("lists the available versions when the version is not in the index", func() {
+				writeBuilderToml("urn:cnb:registry:some.bp@2.0.0")
+				_, err := factory.BuilderConfigFromFlags(pack.CreateBuilderFlags{
+					RepoName:        "myorg/mybuilder",
+					BuilderTomlPath: builderToml,
+					NoPull:          true,
+				})
+				h.AssertNotNil(t, err)
+				h.AssertContains(t, err.Error(), "version '2.0.0' of buildpack 'some.bp' is not in registry index")
+				h.AssertContains(t, err.Error(), "(available versions: 1.0.0, 1.2.3)")
+			})
+
+			it("returns an error when the uri does not match the buildpack id", func() {
+				writeBuilderToml("urn:cnb:registry:other.bp@1.2.3")
+				_, err := factory.BuilderConfigFromFlags(pack.CreateBuilderFlags{
+					RepoName:        "myorg/mybuilder",
+					BuilderTomlPath: builderToml,
+					NoPull:          true,
+				})
+				h.AssertNotNil(t, err)
+				h.AssertContains(t, err.Error(), `buildpack URI "urn:cnb:registry:other.bp@1.2.3" does not match buildpack ID 'some.bp'`)
+			})
+		})
+
+		when("a buildpack location uses docker:// uris", func() {
+			var (
+				mockImageReader *mocks.MockImageReader
+				builderImage    *mocks.MockImage
+				builderToml     string
+				layerFiles      []string
+			)
+
+			// buildpackImage returns an image with the test buildpack at tarDir
+			buildpackImage := func(tarDir string) v1.Image {
+				t.Helper()
+				f, err := ioutil.TempFile("", "buildpackage-layer")
+				h.AssertNil(t, err)
+				h.AssertNil(t, f.Close())
+				layerFiles = append(layerFiles, f.Name())
+				h.AssertNil(t, (&fs.FS{}).CreateTarFile(f.Name(), filepath.Join("testdata", "used-to-test-various-uri-schemes", "buildpack"), tarDir, 0, 0))
+				layer, err := tarball.LayerFromFile(f.Name())
+				h.AssertNil(t, err)
+				img, err := mutate.AppendLayers(empty.Image, layer)
+				h.AssertNil(t, err)
+				return img
+			}
+
+			it.Before(func() {
+				mockImageReader = mocks.NewMockImageReader(mockController)
+				factory.ImageReader = mockImageReader
+
+				builderImage = mocks.NewMockImage(mockController)
+				builderImage.EXPECT().Rename("myorg/mybuilder")
+				builderImage.EXPECT().Label("io.buildpacks.stack.id").Return("com.example.stack", nil)
+
+				f, err := ioutil.TempFile("", "*.toml")
+				h.AssertNil(t, err)
+				builderToml = f.Name()
+				h.AssertNil(t, ioutil.WriteFile(builderToml, []byte(`[[buildpacks]]
+id = "some/bp"
+uri = "docker://some/buildpackage:1.2.3"
+
+[stack]
+id = "com.example.stack"
+build-image = "some/build"
+run-image = "some/run"
+`), 0644))
+			})
+
+			it.After(func() {
+				os.Remove(builderToml)
+				for _, f := range layerFiles {
+					os.Remove(f)
+				}
+			})
+
+			it("extracts the buildpack from the layers of the buildpack image", func() {
+				mockImageFactory.EXPECT().NewLocal("some/build", false).Return(builderImage, nil)
+				bpImage := mocks.NewMockImage(mockController)
+				mockImageFactory.EXPECT().NewLocal("some/buildpackage:1.2.3", true).Return(bpImage, nil)
+				bpImage.EXPECT().Found().Return(true, nil)
+				bpImage.EXPECT().Label("io.buildpacks.buildpackage.metadata").Return(`{"id":"some/bp","version":"1.2.3"}`, nil)
+				mockImageReader.EXPECT().Read("some/buildpackage:1.2.3", false).Return(buildpackImage("/buildpacks/some_bp/1.2.3"), nil)
+
+				builderConfig, err := factory.BuilderConfigFromFlags(pack.CreateBuilderFlags{
+					RepoName:        "myorg/mybuilder",
+					BuilderTomlPath: builderToml,
+					NoPull:          true,
+				})
+				h.AssertNil(t, err)
+				h.AssertDirContainsFileWithContents(t, builderConfig.Buildpacks[0].Dir, "bin/detect", "I come from a directory")
+				os.RemoveAll(filepath.Dir(builderConfig.Buildpacks[0].Dir))
+			})
+
+			it("reads the buildpack image from the registry when publishing", func() {
+				mockImageFactory.EXPECT().NewRemote("some/build").Return(builderImage, nil)
+				previousBuilder := mocks.NewMockImage(mockController)
+				mockImageFactory.EXPECT().NewRemote("myorg/mybuilder").Return(previousBuilder, nil)
+				previousBuilder.EXPECT().Found().Return(false, nil)
+				bpImage := mocks.NewMockImage(mockController)
+				mockImageFactory.EXPECT().NewRemote("some/buildpackage:1.2.3").Return(bpImage, nil)
+				bpImage.EXPECT().Found().Return(true, nil)
+				bpImage.EXPECT().Label("io.buildpacks.buildpackage.metadata").Return(`{"id":"some/bp","version":"1.2.3"}`, nil)
+				mockImageReader.EXPECT().Read("some/buildpackage:1.2.3", true).Return(buildpackImage("/buildpacks/some_bp/1.2.3"), nil)
+
+				builderConfig, err := factory.BuilderConfigFromFlags(pack.CreateBuilderFlags{
+					RepoName:        "myorg/mybuilder",
+					BuilderTomlPath: builderToml,
+					Publish:         true,
+				})
+				h.AssertNil(t, err)
+				h.AssertDirContainsFileWithContents(t, builderConfig.Buildpacks[0].Dir, "bin/detect", "I come from a directory")
+				os.RemoveAll(filepath.Dir(builderConfig.Buildpacks[0].Dir))
+			})
+
+			it("uses the only version in a buildpack image without the buildpackage label", func() {
+				mockImageFactory.EXPECT().NewLocal("some/build", false).Return(builderImage, nil)
+				bpImage := mocks.NewMockImage(mockController)
+				mockImageFactory.EXPECT().NewLocal("some/buildpackage:1.2.3", true).Return(bpImage, nil)
+				bpImage.EXPECT().Found().Return(true, nil)
+				bpImage.EXPECT().Label("io.buildpacks.buildpackage.metadata").Return("", nil)
+				mockImageReader.EXPECT().Read("some/buildpackage:1.2.3", false).Return(buildpackImage("/buildpacks/some_bp/4.5.6"), nil)
+
+				builderConfig, err := factory.BuilderConfigFromFlags(pack.CreateBuilderFlags{
+					RepoName:        "myorg/mybuilder",
+					BuilderTomlPath: builderToml,
+					NoPull:          true,
+				})
+				h.AssertNil(t, err)
+				h.AssertEq(t, filepath.Base(builderConfig.Buildpacks[0].Dir), "4.5.6")
+				os.RemoveAll(filepath.Dir(builderConfig.Buildpacks[0].Dir))
+			})
+
+			it("removes the extracted buildpack once the builder is created", func() {
+				// the ID must match the buildpack.toml of the test buildpack for its layer to be created
+				h.AssertNil(t, ioutil.WriteFile(builderToml, []byte(`[[buildpacks]]
+id = "some.bp"
+uri = "docker://some/buildpackage:1.2.3"
+
+[stack]
+id = "com.example.stack"
+build-image = "some/build"
+run-image = "some/run"
+`), 0644))
+				mockImageFactory.EXPECT().NewLocal("some/build", false).Return(builderImage, nil)
+				bpImage := mocks.NewMockImage(mockController)
+				mockImageFactory.EXPECT().NewLocal("some/buildpackage:1.2.3", true).Return(bpImage, nil)
+				bpImage.EXPECT().Found().Return(true, nil)
+				bpImage.EXPECT().Label("io.buildpacks.buildpackage.metadata").Return(`{"id":"some.bp","version":"1.2.3"}`, nil)
+				mockImageReader.EXPECT().Read("some/buildpackage:1.2.3", false).Return(buildpackImage("/buildpacks/some.bp/1.2.3"), nil)
+
+				builderConfig, err := factory.BuilderConfigFromFlags(pack.CreateBuilderFlags{
+					RepoName:        "myorg/mybuilder",
+					BuilderTomlPath: builderToml,
+					NoPull:          true,
+				})
+				h.AssertNil(t, err)
+				dir := builderConfig.Buildpacks[0].Dir
+
+				builderImage.EXPECT().AddLayer(gomock.Any()).AnyTimes()
+				builderImage.EXPECT().SetLabel(gomock.Any(), gomock.Any()).AnyTimes()
+				builderImage.EXPECT().Save()
+				h.AssertNil(t, factory.Create(builderConfig))
+
+				_, err = os.Stat(filepath.Dir(dir))
+				h.AssertEq(t, os.IsNotExist(err), true)
+			})
+
+			it("returns an error when the image contains another buildpack", func() {
+				mockImageFactory.EXPECT().NewLocal("some/build", false).Return(builderImage, nil)
+				bpImage := mocks.NewMockImage(mockController)
+				mockImageFactory.EXPECT().NewLocal("some/buildpackage:1.2.3", true).Return(bpImage, nil)
+				bpImage.EXPECT().Found().Return(true, nil)
+				bpImage.EXPECT().Label("io.buildpacks.buildpackage.metadata").Return(`{"id":"other/bp","version":"1.2.3"}`, nil)
+
+				_, err := factory.BuilderConfigFromFlags(pack.CreateBuilderFlags{
+					RepoName:        "myorg/mybuilder",
+					BuilderTomlPath: builderToml,
+					NoPull:          true,
+				})
+				h.AssertError(t, err, "buildpack image 'some/buildpackage:1.2.3' contains buildpack 'other/bp', not 'some/bp'")
+			})
+
+			it("returns an error when the image labels the buildpack with a version climbing out of its directory", func() {
+				mockImageFactory.EXPECT().NewLocal("some/build", false).Return(builderImage, nil)
+				bpImage := mocks.NewMockImage(mockController)
+				mockImageFactory.EXPECT().NewLocal("some/buildpackage:1.2.3", true).Return(bpImage, nil)
+				bpImage.EXPECT().Found().Return(true, nil)
+				bpImage.EXPECT().Label("io.buildpacks.buildpackage.metadata").Return(`{"id":"some/bp","version":"../../x"}`, nil)
+
+				_, err := factory.BuilderConfigFromFlags(pack.CreateBuilderFlags{
+					RepoName:        "myorg/mybuilder",
+					BuilderTomlPath: builderToml,
+					NoPull:          true,
+				})
+				h.AssertError(t, err, `buildpack image 'some/buildpackage:1.2.3' contains buildpack 'some/bp' with invalid version "../../x"`)
+			})
+
+			it("does not pull the buildpack image when --offline is passed", func() {
+				mockImageFactory.EXPECT().NewLocal("some/build", false).Return(builderImage, nil)
+				bpImage := mocks.NewMockImage(mockController)
+				mockImageFactory.EXPECT().NewLocal("some/buildpackage:1.2.3", false).Return(bpImage, nil)
+				bpImage.EXPECT().Found().Return(false, nil)
+
+				_, err := factory.BuilderConfigFromFlags(pack.CreateBuilderFlags{
+					RepoName:        "myorg/mybuilder",
+					BuilderTomlPath: builderToml,
+					NoPull:          true,
+					Offline:         true,
+				})
+				h.AssertError(t, err, "buildpack image 'some/buildpackage:1.2.3' does not exist")
+			})
+		})
+
 		when("a buildpack location uses http(s):// uris", func() {
 			var (
 				server *http.Server
@@ -321,21 +789,17 @@ run-image = "some/run"
 				mockImage := mocks.NewMockImage(mockController)
 				mockImageFactory.EXPECT().NewLocal("some/build", false).Return(mockImage, nil)
 				mockImage.EXPECT().Rename("myorg/mybuilder")
+				mockImage.EXPECT().Label("io.buildpacks.stack.id").Return("com.example.stack", nil)
 
 				f, err := ioutil.TempFile("", "*.toml")
 				h.AssertNil(t, err)
 				ioutil.WriteFile(f.Name(), []byte(fmt.Sprintf(`[[buildpacks]]
-id = "some.bp.with.no.uri.scheme"
+id = "some.other.bp"
 uri = "http://%s/used-to-test-various-uri-schemes/buildpack.tgz"
 
 [[groups]]
 buildpacks = [
-  { id = "some.bp.with.no.uri.scheme", version = "1.2.3" },
-]
-
-[[groups]]
-buildpacks = [
-  { id = "some.bp1", version = "1.2.3" },
+  { id = "some.other.bp", version = "1.2.4" },
 ]
 
 [stack]
@@ -357,6 +821,96 @@ run-image = "some/run"
 
 				h.AssertDirContainsFileWithContents(t, builderConfig.Buildpacks[0].Dir, "bin/build", "I come from an archive")
 			})
+			when("--offline is passed", func() {
+				it("only uses buildpacks from the download cache", func() {
+					mockImage := mocks.NewMockImage(mockController)
+					mockImageFactory.EXPECT().NewLocal("some/build", false).Return(mockImage, nil).Times(3)
+					mockImage.EXPECT().Rename("myorg/mybuilder").Times(3)
+					mockImage.EXPECT().Label("io.buildpacks.stack.id").Return("com.example.stack", nil).Times(3)
+
+					f, err := ioutil.TempFile("", "*.toml")
+					h.AssertNil(t, err)
+					h.AssertNil(t, ioutil.WriteFile(f.Name(), []byte(fmt.Sprintf(`[[buildpacks]]
+id = "some.other.bp"
+uri = "http://%s/used-to-test-various-uri-schemes/buildpack.tgz"
+
+[stack]
+id = "com.example.stack"
+build-image = "some/build"
+run-image = "some/run"
+`, server.Addr)), 0644))
+					flags := pack.CreateBuilderFlags{
+						RepoName:        "myorg/mybuilder",
+						BuilderTomlPath: f.Name(),
+						NoPull:          true,
+						Offline:         true,
+					}
+
+					_, err = factory.BuilderConfigFromFlags(flags)
+					h.AssertNotNil(t, err)
+					h.AssertContains(t, err.Error(), "is not in the download cache")
+
+					flags.Offline = false
+					_, err = factory.BuilderConfigFromFlags(flags)
+					h.AssertNil(t, err)
+
+					server.Close()
+					flags.Offline = true
+					builderConfig, err := factory.BuilderConfigFromFlags(flags)
+					h.AssertNil(t, err)
+					h.AssertDirContainsFileWithContents(t, builderConfig.Buildpacks[0].Dir, "bin/build", "I come from an archive")
+				})
+			})
+
+			when("a sha256 is provided", func() {
+				var builderToml = func(sha string) string {
+					f, err := ioutil.TempFile("", "*.toml")
+					h.AssertNil(t, err)
+					h.AssertNil(t, ioutil.WriteFile(f.Name(), []byte(fmt.Sprintf(`[[buildpacks]]
+id = "some.other.bp"
+uri = "http://%s/used-to-test-various-uri-schemes/buildpack.tgz"
+sha256 = "%s"
+
+[stack]
+id = "com.example.stack"
+build-image = "some/build"
+run-image = "some/run"
+`, server.Addr, sha)), 0644))
+					return f.Name()
+				}
+
+				it.Before(func() {
+					mockImage := mocks.NewMockImage(mockController)
+					mockImageFactory.EXPECT().NewLocal("some/build", false).Return(mockImage, nil)
+					mockImage.EXPECT().Rename("myorg/mybuilder")
+					mockImage.EXPECT().Label("io.buildpacks.stack.id").Return("com.example.stack", nil)
+				})
+
+				it("verifies the archive", func() {
+					archive, err := ioutil.ReadFile(filepath.Join("testdata", "used-to-test-various-uri-schemes", "buildpack.tgz"))
+					h.AssertNil(t, err)
+
+					builderConfig, err := factory.BuilderConfigFromFlags(pack.CreateBuilderFlags{
+						RepoName:        "myorg/mybuilder",
+						BuilderTomlPath: builderToml(fmt.Sprintf("%x", sha256.Sum256(archive))),
+						NoPull:          true,
+					})
+					h.AssertNil(t, err)
+					h.AssertDirContainsFileWithContents(t, builderConfig.Buildpacks[0].Dir, "bin/build", "I come from an archive")
+				})
+
+				it("returns an error when the checksum does not match", func() {
+					_, err := factory.BuilderConfigFromFlags(pack.CreateBuilderFlags{
+						RepoName:        "myorg/mybuilder",
+						BuilderTomlPath: builderToml("0000000000000000000000000000000000000000000000000000000000000000"),
+						NoPull:          true,
+					})
+					h.AssertNotNil(t, err)
+					h.AssertContains(t, err.Error(), "checksum mismatch for buildpack 'some.other.bp'")
+					h.AssertContains(t, err.Error(), "expected sha256 0000000000000000000000000000000000000000000000000000000000000000")
+				})
+			})
+
 			it.After(func() {
 				if server != nil {
 					ctx, _ := context.WithTimeout(context.Background(), 2*time.Second)
@@ -364,12 +918,352 @@ run-image = "some/run"
 				}
 			})
 		})
+
+		when("the builder config has a [lifecycle]", func() {
+			var tmpDir string
+
+			it.Before(func() {
+				var err error
+				tmpDir, err = ioutil.TempDir("", "create-builder-lifecycle")
+				h.AssertNil(t, err)
+				writeLifecycleArchive(t, filepath.Join(tmpDir, "lifecycle.tgz"), "detector", "analyzer", "builder", "exporter", "launcher")
+			})
+
+			it.After(func() {
+				os.RemoveAll(tmpDir)
+			})
+
+			writeBuilderToml := func(lifecycle string) string {
+				t.Helper()
+				testdataDir, err := filepath.Abs("testdata")
+				h.AssertNil(t, err)
+				builderToml := filepath.Join(tmpDir, "builder.toml")
+				h.AssertNil(t, ioutil.WriteFile(builderToml, []byte(fmt.Sprintf(`[[buildpacks]]
+id = "some.bp1"
+uri = "%s/some-path-1"
+
+[[groups]]
+buildpacks = [
+  { id = "some.bp1", version = "1.2.3" },
+]
+
+[stack]
+id = "com.example.stack"
+build-image = "some/build"
+run-image = "some/run"
+
+[lifecycle]
+%s
+`, testdataDir, lifecycle)), 0644))
+				return builderToml
+			}
+
+			it("replaces the lifecycle of the build image and records its version", func() {
+				mockImage := mocks.NewMockImage(mockController)
+				mockImageFactory.EXPECT().NewLocal("some/build", false).Return(mockImage, nil)
+				mockImage.EXPECT().Rename("myorg/mybuilder")
+				mockImage.EXPECT().Label("io.buildpacks.stack.id").Return("com.example.stack", nil)
+
+				builderConfig, err := factory.BuilderConfigFromFlags(pack.CreateBuilderFlags{
+					RepoName: "myorg/mybuilder",
+					BuilderTomlPath: writeBuilderToml(`version = "0.1.0"
+uri = "lifecycle.tgz"`),
+					NoPull: true,
+				})
+				h.AssertNil(t, err)
+				h.AssertEq(t, builderConfig.LifecycleVersion, "0.1.0")
+
+				var layers [][]string
+				mockImage.EXPECT().AddLayer(gomock.Any()).Do(func(layerTar string) {
+					layers = append(layers, tarEntries(t, layerTar))
+				}).AnyTimes()
+				var metadata pack.BuilderImageMetadata
+				mockImage.EXPECT().SetLabel("io.buildpacks.lifecycle.version", "0.1.0")
+				mockImage.EXPECT().SetLabel("io.buildpacks.builder.metadata", gomock.Any()).Do(func(_, label string) {
+					h.AssertNil(t, json.Unmarshal([]byte(label), &metadata))
+				})
+				mockImage.EXPECT().SetLabel("io.buildpacks.stack.id", "com.example.stack")
+				mockImage.EXPECT().Save()
+
+				h.AssertNil(t, factory.Create(builderConfig))
+				h.AssertEq(t, layers[0], []string{"/lifecycle/", "/lifecycle/analyzer", "/lifecycle/builder", "/lifecycle/detector", "/lifecycle/exporter", "/lifecycle/launcher"})
+				h.AssertEq(t, metadata.LifecycleVersion, "0.1.0")
+
+				// the archive was extracted to a temporary directory that is no longer needed
+				_, err = os.Stat(builderConfig.LifecycleDir)
+				h.AssertEq(t, os.IsNotExist(err), true)
+			})
+
+			it("returns an error when the archive does not contain the lifecycle", func() {
+				writeLifecycleArchive(t, filepath.Join(tmpDir, "incomplete.tgz"), "detector", "analyzer")
+				mockImage := mocks.NewMockImage(mockController)
+				mockImageFactory.EXPECT().NewLocal("some/build", false).Return(mockImage, nil)
+				mockImage.EXPECT().Rename("myorg/mybuilder")
+				mockImage.EXPECT().Label("io.buildpacks.stack.id").Return("com.example.stack", nil)
+
+				_, err := factory.BuilderConfigFromFlags(pack.CreateBuilderFlags{
+					RepoName: "myorg/mybuilder",
+					BuilderTomlPath: writeBuilderToml(`version = "0.1.0"
+uri = "incomplete.tgz"`),
+					NoPull: true,
+				})
+				h.AssertError(t, err, `lifecycle archive "incomplete.tgz" does not contain 'builder'`)
+			})
+
+			it("rejects lifecycle versions that pack does not support", func() {
+				_, err := factory.BuilderConfigFromFlags(pack.CreateBuilderFlags{
+					RepoName:        "myorg/mybuilder",
+					BuilderTomlPath: writeBuilderToml(`version = "0.2.0"`),
+					NoPull:          true,
+				})
+				h.AssertNotNil(t, err)
+				h.AssertContains(t, err.Error(), "builder.toml:15: lifecycle version '0.2.0' is not supported by this version of pack (supported versions: 0.1.x)")
+			})
+		})
+
+		when("--from is passed", func() {
+			var (
+				mockBaseImage *mocks.MockImage
+				builderToml   string
+				bpDir         string
+				baseEnv       string
+			)
+
+			writeBuilderToml := func(contents string) {
+				t.Helper()
+				h.AssertNil(t, ioutil.WriteFile(builderToml, []byte(contents), 0644))
+			}
+
+			it.Before(func() {
+				mockBaseImage = mocks.NewMockImage(mockController)
+				mockImageFactory.EXPECT().NewLocal("some/builder", false).Return(mockBaseImage, nil)
+				mockBaseImage.EXPECT().Found().Return(true, nil)
+				mockBaseImage.EXPECT().Name().Return("some/builder").AnyTimes()
+				baseEnv = `{"BP_NO_TELEMETRY": "1", "MIRROR_URL": "https://old.example.com"}`
+				mockBaseImage.EXPECT().Label("io.buildpacks.builder.metadata").DoAndReturn(func(string) (string, error) {
+					return `{
+  "runImage": {"image": "some/run", "mirrors": ["gcr.io/some/run"]},
+  "buildpacks": [
+    {"id": "some.bp", "version": "1.0.0", "latest": true},
+    {"id": "old.bp", "version": "1.0.0", "latest": true},
+    {"id": "kept.bp", "version": "1.0.0", "latest": true}
+  ],
+  "groups": [{"buildpacks": [{"id": "some.bp", "version": "latest"}, {"id": "kept.bp", "version": "1.0.0"}]}],
+  "env": ` + baseEnv + `
+}`, nil
+				})
+				mockBaseImage.EXPECT().Label("io.buildpacks.stack.id").Return("com.example.stack", nil)
+
+				var err error
+				bpDir, err = ioutil.TempDir("", "create-builder-from")
+				h.AssertNil(t, err)
+				h.AssertNil(t, os.MkdirAll(filepath.Join(bpDir, "bp"), 0755))
+				h.AssertNil(t, ioutil.WriteFile(filepath.Join(bpDir, "bp", "buildpack.toml"), []byte("[buildpack]\nid = \"some.bp\"\nversion = \"1.2.3\"\n"), 0644))
+				builderToml = filepath.Join(bpDir, "builder.toml")
+			})
+
+			it.After(func() {
+				os.RemoveAll(bpDir)
+			})
+
+			it("adds and removes buildpacks on top of the layers of the builder", func() {
+				mockBaseImage.EXPECT().Rename("myorg/mybuilder")
+				writeBuilderToml(`[[buildpacks]]
+id = "some.bp"
+uri = "bp"
+latest = true
+
+[[remove]]
+id = "old.bp"
+
+[env]
+MIRROR_URL = "https://mirror.example.com"
+`)
+
+				builderConfig, err := factory.BuilderConfigFromFlags(pack.CreateBuilderFlags{
+					RepoName:        "myorg/mybuilder",
+					BuilderTomlPath: builderToml,
+					NoPull:          true,
+					From:            "some/builder",
+				})
+				h.AssertNil(t, err)
+				h.AssertSameInstance(t, builderConfig.Repo, mockBaseImage)
+				h.AssertEq(t, builderConfig.StackID, "com.example.stack")
+				h.AssertEq(t, builderConfig.RunImage, "some/run")
+				h.AssertEq(t, builderConfig.RemovedBuildpacks, []pack.BuilderBuildpackMetadata{{ID: "old.bp", Version: "1.0.0", Latest: true}})
+				h.AssertEq(t, len(builderConfig.Groups), 1)
+				h.AssertEq(t, builderConfig.Env, map[string]string{"BP_NO_TELEMETRY": "1", "MIRROR_URL": "https://mirror.example.com"})
+
+				var layers [][]string
+				var diffIDs []string
+				mockBaseImage.EXPECT().AddLayer(gomock.Any()).Do(func(layerTar string) {
+					layers = append(layers, tarEntries(t, layerTar))
+					diffIDs = append(diffIDs, layerDiffID(t, layerTar))
+				}).AnyTimes()
+				var label string
+				mockBaseImage.EXPECT().SetLabel("io.buildpacks.builder.metadata", gomock.Any()).Do(func(_, value string) {
+					label = value
+				})
+				mockBaseImage.EXPECT().SetLabel("io.buildpacks.stack.id", "com.example.stack")
+				mockBaseImage.EXPECT().Save()
+
+				h.AssertNil(t, factory.Create(builderConfig))
+				h.AssertEq(t, len(layers), 5)
+				h.AssertEq(t, label, fmt.Sprintf(`{"runImage":{"image":"some/run","mirrors":["gcr.io/some/run"]},"buildpacks":[{"id":"some.bp","version":"1.0.0","latest":false},{"id":"kept.bp","version":"1.0.0","latest":true},{"id":"some.bp","version":"1.2.3","latest":true,"layerDiffId":"%s"}],"groups":[{"buildpacks":[{"id":"some.bp","version":"latest"},{"id":"kept.bp","version":"1.0.0"}]}],"env":{"BP_NO_TELEMETRY":"1","MIRROR_URL":"https://mirror.example.com"}}`, diffIDs[3]))
+				h.AssertEq(t, layers[0], []string{"/platform/env/", "/platform/env/BP_NO_TELEMETRY", "/platform/env/MIRROR_URL"})
+				h.AssertContains(t, strings.Join(layers[1], " "), "/buildpacks/order.toml")
+				h.AssertEq(t, layers[2], []string{"/buildpacks/", "/buildpacks/old.bp/", "/buildpacks/old.bp/.wh.1.0.0", "/buildpacks/old.bp/.wh.latest"})
+				h.AssertContains(t, strings.Join(layers[3], " "), "/buildpacks/some.bp/1.2.3/buildpack.toml")
+			})
+
+			it("returns an error when the build environment of the builder has invalid names", func() {
+				baseEnv = `{"../../etc/some-file": "1"}`
+				writeBuilderToml(`[[buildpacks]]
+id = "some.bp"
+uri = "bp"
+`)
+
+				_, err := factory.BuilderConfigFromFlags(pack.CreateBuilderFlags{
+					RepoName:        "myorg/mybuilder",
+					BuilderTomlPath: builderToml,
+					NoPull:          true,
+					From:            "some/builder",
+				})
+				h.AssertError(t, err, "reading build environment of builder: some/builder: invalid environment variable name '../../etc/some-file'")
+			})
+
+			it("replaces buildpacks with the same version", func() {
+				mockBaseImage.EXPECT().Rename("myorg/mybuilder")
+				h.AssertNil(t, ioutil.WriteFile(filepath.Join(bpDir, "bp", "buildpack.toml"), []byte("[buildpack]\nid = \"some.bp\"\nversion = \"1.0.0\"\n"), 0644))
+				writeBuilderToml(`[[buildpacks]]
+id = "some.bp"
+uri = "bp"
+`)
+
+				builderConfig, err := factory.BuilderConfigFromFlags(pack.CreateBuilderFlags{
+					RepoName:        "myorg/mybuilder",
+					BuilderTomlPath: builderToml,
+					NoPull:          true,
+					From:            "some/builder",
+				})
+				h.AssertNil(t, err)
+
+				var layers [][]string
+				mockBaseImage.EXPECT().AddLayer(gomock.Any()).Do(func(layerTar string) {
+					layers = append(layers, tarEntries(t, layerTar))
+				}).AnyTimes()
+				mockBaseImage.EXPECT().SetLabel("io.buildpacks.builder.metadata", gomock.Any()).Do(func(_, label string) {
+					h.AssertContains(t, label, `"buildpacks":[{"id":"old.bp","version":"1.0.0","latest":true},{"id":"kept.bp","version":"1.0.0","latest":true},{"id":"some.bp","version":"1.0.0","latest":true,"layerDiffId":"sha256:`)
+				})
+				mockBaseImage.EXPECT().SetLabel("io.buildpacks.stack.id", "com.example.stack")
+				mockBaseImage.EXPECT().Save()
+
+				h.AssertNil(t, factory.Create(builderConfig))
+				h.AssertEq(t, layers[2], []string{"/buildpacks/", "/buildpacks/some.bp/", "/buildpacks/some.bp/.wh.1.0.0"})
+			})
+
+			it("reports removed buildpacks that the groups of the builder still use", func() {
+				writeBuilderToml(`[[remove]]
+id = "kept.bp"
+version = "1.0.0"
+
+[[remove]]
+id = "missing.bp"
+`)
+
+				_, err := factory.BuilderConfigFromFlags(pack.CreateBuilderFlags{
+					RepoName:        "myorg/mybuilder",
+					BuilderTomlPath: builderToml,
+					NoPull:          true,
+					From:            "some/builder",
+				})
+				h.AssertError(t, err, "invalid builder config "+builderToml+":\n  "+
+					builderToml+":5: cannot remove buildpack 'missing.bp', which is not in the builder\n  "+
+					"group #1 of the builder references 'kept.bp@1.0.0', which is removed: define new [[groups]]")
+			})
+
+			it("fails when the stack does not match the builder", func() {
+				writeBuilderToml(`[stack]
+id = "other.stack"
+`)
+
+				_, err := factory.BuilderConfigFromFlags(pack.CreateBuilderFlags{
+					RepoName:        "myorg/mybuilder",
+					BuilderTomlPath: builderToml,
+					NoPull:          true,
+					From:            "some/builder",
+				})
+				h.AssertError(t, err, "builder 'some/builder' has stack 'com.example.stack', but the builder config requires stack 'other.stack'")
+			})
+		})
 	})
 }
 
-func checkGroups(t *testing.T, groups []lifecycle.BuildpackGroup) {
+func layerDiffID(t *testing.T, tarFile string) string {
+	t.Helper()
+	contents, err := ioutil.ReadFile(tarFile)
+	h.AssertNil(t, err)
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(contents))
+}
+
+// writeLifecycleArchive writes a lifecycle release archive with empty phase binaries
+func writeLifecycleArchive(t *testing.T, path string, phases ...string) {
+	t.Helper()
+	f, err := os.Create(path)
+	h.AssertNil(t, err)
+	defer f.Close()
+	gzw := gzip.NewWriter(f)
+	defer gzw.Close()
+	tw := tar.NewWriter(gzw)
+	defer tw.Close()
+	h.AssertNil(t, tw.WriteHeader(&tar.Header{Name: "lifecycle/", Typeflag: tar.TypeDir, Mode: 0755}))
+	for _, phase := range phases {
+		h.AssertNil(t, tw.WriteHeader(&tar.Header{Name: "lifecycle/" + phase, Typeflag: tar.TypeReg, Mode: 0755}))
+	}
+}
+
+// tarFiles returns the contents of the regular files of a layer tar by path
+func tarFiles(t *testing.T, tarFile string) map[string]string {
+	t.Helper()
+	f, err := os.Open(tarFile)
+	h.AssertNil(t, err)
+	defer f.Close()
+	files := map[string]string{}
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return files
+		}
+		h.AssertNil(t, err)
+		if hdr.Typeflag == tar.TypeReg {
+			contents, err := ioutil.ReadAll(tr)
+			h.AssertNil(t, err)
+			files[hdr.Name] = string(contents)
+		}
+	}
+}
+
+func tarEntries(t *testing.T, tarFile string) []string {
+	t.Helper()
+	f, err := os.Open(tarFile)
+	h.AssertNil(t, err)
+	defer f.Close()
+	var names []string
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return names
+		}
+		h.AssertNil(t, err)
+		names = append(names, hdr.Name)
+	}
+}
+
+func checkGroups(t *testing.T, groups []pack.BuilderGroup) {
 	t.Helper()
-	if diff := cmp.Diff(groups, []lifecycle.BuildpackGroup{
+	if diff := cmp.Diff(groups, []pack.BuilderGroup{
 		{Buildpacks: []*lifecycle.Buildpack{
 			{
 				ID:      "some.bp1",
@@ -17,10 +17,17 @@ type BuilderInspect struct {
 }
 
 type Builder struct {
-	Image                string
-	RunImage             string
-	LocalRunImageMirrors []string
-	RunImageMirrors      []string
+	Image                string                     `json:"image"`
+	StackID              string                     `json:"stackId"`
+	LifecycleVersion     string                     `json:"lifecycleVersion"` // empty when the builder does not record it
+	UserID               string                     `json:"userId"`           // PACK_USER_ID of the builder
+	GroupID              string                     `json:"groupId"`          // PACK_GROUP_ID of the builder
+	RunImage             string                     `json:"runImage"`
+	LocalRunImageMirrors []string                   `json:"localRunImageMirrors"`
+	RunImageMirrors      []string                   `json:"runImageMirrors"`
+	Buildpacks           []BuilderBuildpackMetadata `json:"buildpacks"`
+	Groups               []BuilderGroupMetadata     `json:"groups"`
+	Env                  map[string]string          `json:"env"` // default build environment of the builder
 }
 
 func DefaultBuilderInspect() (*BuilderInspect, error) {
@@ -35,18 +42,34 @@ func DefaultBuilderInspect() (*BuilderInspect, error) {
 }
 
 func (b *BuilderInspect) Inspect(builderImage image.Image) (Builder, error) {
-	defaultRunImage, err := b.getRunImageMirrors(builderImage)
+	metadata, err := builderImageMetadata(builderImage)
 	if err != nil {
 		return Builder{}, err
 	}
 
 	builderName := builderImage.Name()
-	return Builder{
+	builder := Builder{
 		Image:                builderName,
-		RunImage:             defaultRunImage.Image,
-		LocalRunImageMirrors: b.getLocalRunImageMirrors(defaultRunImage.Image),
-		RunImageMirrors:      defaultRunImage.Mirrors,
-	}, nil
+		RunImage:             metadata.RunImage.Image,
+		LocalRunImageMirrors: b.getLocalRunImageMirrors(metadata.RunImage.Image),
+		RunImageMirrors:      metadata.RunImage.Mirrors,
+		Buildpacks:           metadata.Buildpacks,
+		Groups:               metadata.Groups,
+		Env:                  metadata.Env,
+	}
+	if builder.StackID, err = builderImage.Label(StackLabel); err != nil {
+		return Builder{}, errors.Wrapf(err, "failed to find stack of builder %s", style.Symbol(builderName))
+	}
+	if builder.LifecycleVersion, err = builderImage.Label(LifecycleVersionLabel); err != nil {
+		return Builder{}, errors.Wrapf(err, "failed to find lifecycle version of builder %s", style.Symbol(builderName))
+	}
+	if builder.UserID, err = builderImage.Env("PACK_USER_ID"); err != nil {
+		return Builder{}, errors.Wrapf(err, "failed to find PACK_USER_ID of builder %s", style.Symbol(builderName))
+	}
+	if builder.GroupID, err = builderImage.Env("PACK_GROUP_ID"); err != nil {
+		return Builder{}, errors.Wrapf(err, "failed to find PACK_GROUP_ID of builder %s", style.Symbol(builderName))
+	}
+	return builder, nil
 }
 
 func (b *BuilderInspect) getLocalRunImageMirrors(imageName string) []string {
@@ -56,7 +79,7 @@ func (b *BuilderInspect) getLocalRunImageMirrors(imageName string) []string {
 	return nil
 }
 
-func (b *BuilderInspect) getRunImageMirrors(builderImage image.Image) (*BuilderRunImageMetadata, error) {
+func builderImageMetadata(builderImage image.Image) (*BuilderImageMetadata, error) {
 	var metadata BuilderImageMetadata
 
 	label, err := builderImage.Label(BuilderMetadataLabel)
@@ -70,5 +93,5 @@ func (b *BuilderInspect) getRunImageMirrors(builderImage image.Image) (*BuilderR
 		return nil, errors.Wrapf(err, "failed to parse run images for builder %s", style.Symbol(builderImage.Name()))
 	}
 
-	return &metadata.RunImage, nil
+	return &metadata, nil
 }
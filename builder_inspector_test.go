@@ -43,7 +43,33 @@ func testInspectBuilder(t *testing.T, when spec.G, it spec.S) {
 	when("#Inspect", func() {
 		when("builder has valid metadata label", func() {
 			it.Before(func() {
-				mockBuilderImage.EXPECT().Label("io.buildpacks.builder.metadata").Return(`{"runImage": {"image": "some/default", "mirrors": ["gcr.io/some/default"]}}`, nil)
+				mockBuilderImage.EXPECT().Label("io.buildpacks.builder.metadata").Return(`{"runImage": {"image": "some/default", "mirrors": ["gcr.io/some/default"]}, "buildpacks": [{"id": "some.bp", "version": "1.2.3", "latest": true}], "groups": [{"buildpacks": [{"id": "some.bp", "version": "latest"}]}], "env": {"BP_NO_TELEMETRY": "1"}}`, nil)
+				mockBuilderImage.EXPECT().Label("io.buildpacks.stack.id").Return("some.stack", nil)
+				mockBuilderImage.EXPECT().Label("io.buildpacks.lifecycle.version").Return("0.1.0", nil)
+				mockBuilderImage.EXPECT().Env("PACK_USER_ID").Return("1000", nil)
+				mockBuilderImage.EXPECT().Env("PACK_GROUP_ID").Return("1001", nil)
+			})
+
+			it("returns the stack, lifecycle and user of the builder", func() {
+				builder, err := inspector.Inspect(mockBuilderImage)
+				h.AssertNil(t, err)
+				h.AssertEq(t, builder.StackID, "some.stack")
+				h.AssertEq(t, builder.LifecycleVersion, "0.1.0")
+				h.AssertEq(t, builder.UserID, "1000")
+				h.AssertEq(t, builder.GroupID, "1001")
+			})
+
+			it("returns the buildpacks and groups of the builder", func() {
+				builder, err := inspector.Inspect(mockBuilderImage)
+				h.AssertNil(t, err)
+				h.AssertEq(t, builder.Buildpacks, []pack.BuilderBuildpackMetadata{{ID: "some.bp", Version: "1.2.3", Latest: true}})
+				h.AssertEq(t, builder.Groups, []pack.BuilderGroupMetadata{{Buildpacks: []pack.BuilderGroupBuildpackMetadata{{ID: "some.bp", Version: "latest"}}}})
+			})
+
+			it("returns the build environment of the builder", func() {
+				builder, err := inspector.Inspect(mockBuilderImage)
+				h.AssertNil(t, err)
+				h.AssertEq(t, builder.Env, map[string]string{"BP_NO_TELEMETRY": "1"})
 			})
 
 			when("builder exists in config", func() {
@@ -0,0 +1,132 @@
+package pack
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/buildpack/pack/style"
+)
+
+// LifecycleConfig is the [lifecycle] table of builder.toml. The lifecycle of the given version is downloaded from
+// its GitHub release, unless URI points to another archive of it.
+type LifecycleConfig struct {
+	Version string `toml:"version"`
+	URI     string `toml:"uri"`
+}
+
+// supportedLifecycleVersions are the major and minor versions of the lifecycle whose phases accept the arguments
+// pack runs them with
+var supportedLifecycleVersions = []string{"0.1"}
+
+const lifecycleDownloadURL = "https://github.com/buildpack/lifecycle/releases/download/v%[1]s/lifecycle-v%[1]s+linux.x86-64.tgz"
+
+var lifecycleVersionPattern = regexp.MustCompile(`^v?(\d+)\.(\d+)\.\d+$`)
+
+// lifecyclePhases are the binaries of the lifecycle that pack runs
+var lifecyclePhases = []string{"detector", "analyzer", "builder", "exporter"}
+
+// supportedLifecycleVersion returns whether pack can run the phases of the given lifecycle version
+func supportedLifecycleVersion(version string) bool {
+	match := lifecycleVersionPattern.FindStringSubmatch(version)
+	if match == nil {
+		return false
+	}
+	return contains(supportedLifecycleVersions, match[1]+"."+match[2])
+}
+
+// supportedLifecycleVersionList lists the lifecycle versions pack can run, for use in error messages
+func supportedLifecycleVersionList() string {
+	var versions []string
+	for _, v := range supportedLifecycleVersions {
+		versions = append(versions, v+".x")
+	}
+	return strings.Join(versions, ", ")
+}
+
+// configureLifecycle records the lifecycle of [lifecycle] in builderConfig, if the table is set
+func (f *BuilderFactory) configureLifecycle(builderConfig *BuilderConfig, config LifecycleConfig, offline bool) error {
+	if config.Version == "" {
+		return nil
+	}
+	dir, tmpDir, err := f.resolveLifecycle(builderConfig.BuilderDir, config, offline)
+	if err != nil {
+		return err
+	}
+	builderConfig.LifecycleDir = dir
+	builderConfig.lifecycleTmpDir = tmpDir
+	builderConfig.LifecycleVersion = strings.TrimPrefix(config.Version, "v")
+	return nil
+}
+
+// resolveLifecycle returns the directory holding the lifecycle binaries described by config, extracting or
+// downloading its archive. Local archives are extracted to a temporary directory, which is returned as tmpDir for
+// the caller to remove once the lifecycle layer is created.
+func (f *BuilderFactory) resolveLifecycle(builderDir string, config LifecycleConfig, offline bool) (dir, tmpDir string, err error) {
+	uri := config.URI
+	if uri == "" {
+		uri = fmt.Sprintf(lifecycleDownloadURL, strings.TrimPrefix(config.Version, "v"))
+	}
+	asurl, err := url.Parse(uri)
+	if err != nil {
+		return "", "", err
+	}
+
+	switch asurl.Scheme {
+	case "", "file":
+		path := asurl.Path
+		if !asurl.IsAbs() && !filepath.IsAbs(path) {
+			path = filepath.Join(builderDir, path)
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return "", "", errors.Wrapf(err, "could not open lifecycle archive: %q", path)
+		}
+		defer file.Close()
+		tmpDir, err = ioutil.TempDir("", "create-builder-lifecycle-")
+		if err != nil {
+			return "", "", fmt.Errorf(`failed to create temporary directory: %s`, err)
+		}
+		if err := f.untarZ(file, tmpDir); err != nil {
+			os.RemoveAll(tmpDir)
+			return "", "", errors.Wrapf(err, "failed to extract lifecycle archive %q", path)
+		}
+		dir = tmpDir
+	case "http", "https":
+		dir, err = f.downloadBuildpack(Buildpack{ID: "lifecycle", URI: uri}, offline)
+		if err != nil {
+			return "", "", err
+		}
+	default:
+		return "", "", fmt.Errorf("unsupported protocol in URI %q", uri)
+	}
+
+	// release archives hold the binaries in a lifecycle directory
+	if info, err := os.Stat(filepath.Join(dir, "lifecycle")); err == nil && info.IsDir() {
+		dir = filepath.Join(dir, "lifecycle")
+	}
+	for _, phase := range lifecyclePhases {
+		if _, err := os.Stat(filepath.Join(dir, phase)); err != nil {
+			if tmpDir != "" {
+				os.RemoveAll(tmpDir)
+			}
+			return "", "", fmt.Errorf("lifecycle archive %q does not contain %s", uri, style.Symbol(phase))
+		}
+	}
+	return dir, tmpDir, nil
+}
+
+// lifecycleLayer creates a layer replacing the lifecycle of the build image with the binaries in dir
+func (f *BuilderFactory) lifecycleLayer(dest, dir string) (string, error) {
+	tarFile := filepath.Join(dest, "lifecycle.tar")
+	if err := f.FS.CreateTarFile(tarFile, dir, "/lifecycle", 0, 0); err != nil {
+		return "", err
+	}
+	return tarFile, nil
+}
@@ -0,0 +1,62 @@
+package pack
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/buildpack/lifecycle/image"
+
+	"github.com/buildpack/pack/style"
+)
+
+// previousBuildpacks returns the buildpacks of the builder previously published as repoName, or nil when there is
+// none. It never fails the creation of the builder: without a previous builder every layer is simply uploaded.
+func (f *BuilderFactory) previousBuildpacks(repoName string) []BuilderBuildpackMetadata {
+	previous, err := f.ImageFactory.NewRemote(repoName)
+	if err != nil {
+		f.Logger.Verbose("Not reusing layers of builder %s: %s", style.Symbol(repoName), err)
+		return nil
+	}
+	if found, err := previous.Found(); err != nil || !found {
+		return nil
+	}
+	metadata, err := builderImageMetadata(previous)
+	if err != nil {
+		f.Logger.Verbose("Not reusing layers of builder %s: %s", style.Symbol(repoName), err)
+		return nil
+	}
+	return metadata.Buildpacks
+}
+
+// addBuildpackLayer appends the layer of buildpack to repo. When the previous builder contains the same version of
+// the buildpack with an identical layer, that layer is reused instead, so that it is not uploaded again.
+func (f *BuilderFactory) addBuildpackLayer(repo image.Image, tarFile string, buildpack BuilderBuildpackMetadata, previous []BuilderBuildpackMetadata) error {
+	for _, bp := range previous {
+		if bp.ID != buildpack.ID || bp.Version != buildpack.Version || bp.LayerDiffID != buildpack.LayerDiffID {
+			continue
+		}
+		if err := repo.ReuseLayer(buildpack.LayerDiffID); err != nil {
+			f.Logger.Verbose("Could not reuse layer of buildpack %s: %s", style.Symbol(buildpack.ID+"@"+buildpack.Version), err)
+			break
+		}
+		f.Logger.Verbose("Reusing unchanged layer of buildpack %s", style.Symbol(buildpack.ID+"@"+buildpack.Version))
+		return nil
+	}
+	return repo.AddLayer(tarFile)
+}
+
+// layerDiffID returns the diffID of an uncompressed layer tar, the sha256 of its contents
+func layerDiffID(tarFile string) (string, error) {
+	fh, err := os.Open(tarFile)
+	if err != nil {
+		return "", err
+	}
+	defer fh.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, fh); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("sha256:%x", hash.Sum(nil)), nil
+}
@@ -0,0 +1,541 @@
+package pack
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/pkg/errors"
+
+	"github.com/buildpack/pack/dlcache"
+	"github.com/buildpack/pack/style"
+)
+
+// BuilderConfigError lists every problem found in a builder config
+type BuilderConfigError struct {
+	Path     string
+	Problems []string
+}
+
+func (e *BuilderConfigError) Error() string {
+	return fmt.Sprintf("invalid builder config %s:\n  %s", e.Path, strings.Join(e.Problems, "\n  "))
+}
+
+// ValidateBuilderConfig reads the builder config at path and checks it without downloading, pulling or writing
+// anything. The buildpack.toml of local buildpacks, and of downloaded buildpacks found in the download cache, is
+// checked against the config. All problems are returned at once in a *BuilderConfigError.
+func (f *BuilderFactory) ValidateBuilderConfig(path string) (*BuilderTOML, error) {
+	return f.validateBuilderConfig(path, nil)
+}
+
+// validateBuilderConfig checks the builder config at path. When base is the metadata of a builder being extended, its
+// buildpacks can be referenced by groups and removed with [[remove]], and the stack table is optional.
+func (f *BuilderFactory) validateBuilderConfig(path string, base *BuilderImageMetadata) (*BuilderTOML, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to read builder config from file %s: %s`, path, err)
+	}
+	builderTOML := &BuilderTOML{}
+	md, err := toml.Decode(string(contents), builderTOML)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to decode builder config from file %s: %s`, path, err)
+	}
+
+	v := &builderValidator{
+		factory:    f,
+		path:       path,
+		builderDir: filepath.Dir(path),
+		lines:      scanBuilderTOMLLines(contents),
+		base:       base,
+		versions:   map[string][]string{},
+		unknown:    map[string]bool{},
+		latest:     map[string]int{},
+		baseLatest: map[string]bool{},
+	}
+	v.lines.reconcile(builderTOML)
+	v.validateGroupKeys(md.Undecoded())
+	v.validate(builderTOML)
+	if len(v.problems) > 0 {
+		return nil, &BuilderConfigError{Path: path, Problems: v.problems}
+	}
+	return builderTOML, nil
+}
+
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+type builderValidator struct {
+	factory    *BuilderFactory
+	path       string
+	builderDir string
+	lines      builderTOMLLines
+	base       *BuilderImageMetadata // metadata of the builder being extended, if any
+	problems   []string
+	versions   map[string][]string // included versions by buildpack ID
+	unknown    map[string]bool     // IDs with a buildpack whose version cannot be known before it is downloaded
+	latest     map[string]int      // index of the entry with latest = true by buildpack ID
+	baseLatest map[string]bool     // IDs with a latest version kept from the builder being extended
+}
+
+func (v *builderValidator) addProblem(line int, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	if line > 0 {
+		msg = fmt.Sprintf("%s:%d: %s", v.path, line, msg)
+	}
+	v.problems = append(v.problems, msg)
+}
+
+func (v *builderValidator) validate(builderTOML *BuilderTOML) {
+	baseVersions := v.validateRemovals(builderTOML.Remove)
+
+	type source struct{ id, uri string }
+	seen := map[source]int{}
+	for i, b := range builderTOML.Buildpacks {
+		line := v.lines.buildpack(i)
+		if b.ID == "" {
+			v.addProblem(line, "buildpack is missing an id")
+			continue
+		}
+		if err := ValidateBuildpackID(b.ID); err != nil {
+			v.addProblem(line, "%s", err)
+		}
+		if b.URI == "" {
+			v.addProblem(line, "buildpack %s is missing a uri", style.Symbol(b.ID))
+			continue
+		}
+		if first, ok := seen[source{b.ID, b.URI}]; ok {
+			v.addProblem(line, "buildpack %s from %q is already listed at line %d", style.Symbol(b.ID), b.URI, v.lines.buildpack(first))
+			continue
+		}
+		seen[source{b.ID, b.URI}] = i
+		if b.Latest {
+			if first, ok := v.latest[b.ID]; ok {
+				v.addProblem(line, "buildpack %s has latest = true on more than one version, also at line %d", style.Symbol(b.ID), v.lines.buildpack(first))
+			} else {
+				v.latest[b.ID] = i
+			}
+		}
+
+		id, version, known, err := v.factory.describeBuildpack(v.builderDir, b)
+		if err != nil {
+			v.addProblem(line, "%s", err)
+			v.unknown[b.ID] = true
+			continue
+		}
+		if !known {
+			v.unknown[b.ID] = true
+			continue
+		}
+		if id != b.ID {
+			v.addProblem(line, "buildpack %s does not match id %s in its buildpack.toml", style.Symbol(b.ID), style.Symbol(id))
+			continue
+		}
+		if version == "" {
+			v.addProblem(line, "buildpack.toml of buildpack %s must provide a version", style.Symbol(b.ID))
+			continue
+		}
+		for _, included := range v.versions[b.ID] {
+			if included == version {
+				v.addProblem(line, "version %s of buildpack %s is included more than once", style.Symbol(version), style.Symbol(b.ID))
+			}
+		}
+		v.versions[b.ID] = append(v.versions[b.ID], version)
+	}
+
+	for id, versions := range baseVersions {
+		v.versions[id] = append(v.versions[id], versions...)
+	}
+
+	if v.base != nil && len(builderTOML.Groups) == 0 {
+		v.validateBaseGroups()
+	}
+	descriptions := map[string]int{}
+	for i, group := range builderTOML.Groups {
+		if strings.ContainsAny(group.Description, "\r\n") {
+			v.addProblem(v.lines.group(i), "group description must be a single line")
+		} else if first, ok := descriptions[group.Description]; ok {
+			v.addProblem(v.lines.group(i), "group description %q is already used by the group at line %d", group.Description, v.lines.group(first))
+		} else if group.Description != "" {
+			descriptions[group.Description] = i
+		}
+		if len(group.Buildpacks) == 0 {
+			v.addProblem(v.lines.group(i), "group has no buildpacks")
+			continue
+		}
+		if onlyOptional(group) {
+			v.addProblem(v.lines.group(i), "group must contain at least one buildpack that is not optional")
+		}
+		inGroup := map[string]bool{}
+		for j, bp := range group.Buildpacks {
+			line := v.lines.groupBuildpack(i, j)
+			if inGroup[bp.ID] {
+				v.addProblem(line, "buildpack %s is listed more than once in the group", style.Symbol(bp.ID))
+				continue
+			}
+			inGroup[bp.ID] = true
+			versions, included := v.versions[bp.ID]
+			if !included && !v.unknown[bp.ID] {
+				v.addProblem(line, "group references buildpack %s, which is not included in [[buildpacks]]", style.Symbol(bp.ID))
+				continue
+			}
+			if bp.Version == "" {
+				v.addProblem(line, "group entry for buildpack %s is missing a version", style.Symbol(bp.ID))
+				continue
+			}
+			if bp.Version == "latest" && (v.hasLatest(bp.ID) || v.baseLatest[bp.ID]) {
+				continue
+			}
+			if !v.unknown[bp.ID] && !contains(versions, bp.Version) {
+				sort.Strings(versions)
+				v.addProblem(line, "group references version %s of buildpack %s, which is not included (included versions: %s)", style.Symbol(bp.Version), style.Symbol(bp.ID), strings.Join(versions, ", "))
+			}
+		}
+	}
+
+	v.validateLifecycle(builderTOML.Lifecycle)
+	v.validateEnv(builderTOML.Env)
+
+	if v.base != nil {
+		// the stack and run image of the extended builder are used unless overridden
+		return
+	}
+	stack := builderTOML.Stack
+	for _, field := range []struct{ name, value string }{
+		{"id", stack.ID},
+		{"build-image", stack.BuildImage},
+		{"run-image", stack.RunImage},
+	} {
+		if field.value == "" {
+			v.addProblem(v.lines.stack, "stack is missing %s", field.name)
+		}
+	}
+}
+
+// validateRemovals checks the [[remove]] entries against the builder being extended and returns the versions of
+// the buildpacks of that builder that are kept
+func (v *builderValidator) validateRemovals(removals []BuildpackRemoval) map[string][]string {
+	kept := map[string][]string{}
+	if v.base == nil {
+		for i := range removals {
+			v.addProblem(v.lines.removal(i), "buildpacks can only be removed when extending a builder with --from")
+		}
+		return kept
+	}
+	for i, r := range removals {
+		line := v.lines.removal(i)
+		if r.ID == "" {
+			v.addProblem(line, "removed buildpack is missing an id")
+			continue
+		}
+		found := false
+		for _, bp := range v.base.Buildpacks {
+			found = found || r.matches(bp)
+		}
+		if !found && r.Version == "" {
+			v.addProblem(line, "cannot remove buildpack %s, which is not in the builder", style.Symbol(r.ID))
+		} else if !found {
+			v.addProblem(line, "cannot remove version %s of buildpack %s, which is not in the builder", style.Symbol(r.Version), style.Symbol(r.ID))
+		}
+	}
+	for _, bp := range v.base.Buildpacks {
+		if removedBuildpack(bp, removals) {
+			continue
+		}
+		kept[bp.ID] = append(kept[bp.ID], bp.Version)
+		if bp.Latest {
+			v.baseLatest[bp.ID] = true
+		}
+	}
+	return kept
+}
+
+// validateLifecycle checks that the lifecycle of [lifecycle], if any, can be run by pack
+func (v *builderValidator) validateLifecycle(config LifecycleConfig) {
+	if config == (LifecycleConfig{}) {
+		return
+	}
+	line := v.lines.lifecycle
+	if config.Version == "" {
+		v.addProblem(line, "lifecycle is missing version")
+	} else if !lifecycleVersionPattern.MatchString(config.Version) {
+		v.addProblem(line, "lifecycle version %s is not a valid version, expected <major>.<minor>.<patch>", style.Symbol(config.Version))
+	} else if !supportedLifecycleVersion(config.Version) {
+		v.addProblem(line, "lifecycle version %s is not supported by this version of pack (supported versions: %s)", style.Symbol(config.Version), supportedLifecycleVersionList())
+	}
+	if config.URI == "" {
+		return
+	}
+	asurl, err := url.Parse(config.URI)
+	if err != nil {
+		v.addProblem(line, "invalid lifecycle uri %q: %s", config.URI, err)
+		return
+	}
+	switch asurl.Scheme {
+	case "", "file":
+		if filepath.Ext(asurl.Path) != ".tgz" {
+			v.addProblem(line, "lifecycle uri %q must be a .tgz archive", config.URI)
+		}
+	case "http", "https":
+	default:
+		v.addProblem(line, "unsupported protocol in lifecycle uri %q", config.URI)
+	}
+}
+
+// validateEnv checks that the variables of [env] can be written to /platform/env
+func (v *builderValidator) validateEnv(env map[string]string) {
+	var names []string
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !envNamePattern.MatchString(name) {
+			v.addProblem(v.lines.env, "invalid environment variable name %s, names may only contain letters, digits and underscores and may not start with a digit", style.Symbol(name))
+		}
+	}
+}
+
+// validateGroupKeys reports keys of [[groups]] that are not decoded, so that a misspelled optional or description is
+// not silently ignored
+func (v *builderValidator) validateGroupKeys(undecoded []toml.Key) {
+	for _, key := range undecoded {
+		if len(key) > 0 && key[0] == "groups" {
+			v.addProblem(0, "unknown key %s in [[groups]]", style.Symbol(key.String()))
+		}
+	}
+}
+
+// validateBaseGroups checks that the groups of the builder being extended, which are kept when the config has no
+// groups, do not reference removed buildpacks
+func (v *builderValidator) validateBaseGroups() {
+	for i, group := range v.base.Groups {
+		for _, bp := range group.Buildpacks {
+			if bp.Version == "latest" && (v.hasLatest(bp.ID) || v.baseLatest[bp.ID]) {
+				continue
+			}
+			if !contains(v.versions[bp.ID], bp.Version) && !v.unknown[bp.ID] {
+				v.addProblem(0, "group #%d of the builder references %s, which is removed: define new [[groups]]", i+1, style.Symbol(bp.ID+"@"+bp.Version))
+			}
+		}
+	}
+}
+
+func onlyOptional(group BuilderGroup) bool {
+	for _, bp := range group.Buildpacks {
+		if !bp.Optional {
+			return false
+		}
+	}
+	return true
+}
+
+func (v *builderValidator) hasLatest(id string) bool {
+	_, ok := v.latest[id]
+	return ok
+}
+
+// describeBuildpack returns the id and version from the buildpack.toml of a buildpack, when it can be read without
+// downloading or pulling the buildpack
+func (f *BuilderFactory) describeBuildpack(builderDir string, b Buildpack) (id, version string, known bool, err error) {
+	if strings.HasPrefix(b.URI, registryURIPrefix) {
+		resolved, err := f.resolveRegistryURI(b)
+		if err != nil {
+			return "", "", false, err
+		}
+		version := b.URI[strings.LastIndex(b.URI, "@")+1:]
+		return resolved.ID, version, true, nil
+	}
+
+	asurl, err := url.Parse(b.URI)
+	if err != nil {
+		return "", "", false, err
+	}
+	switch asurl.Scheme {
+	case "", "file":
+		bpPath := asurl.Path
+		if !asurl.IsAbs() && !filepath.IsAbs(bpPath) {
+			bpPath = filepath.Join(builderDir, bpPath)
+		}
+		var data BuildpackData
+		if filepath.Ext(bpPath) == ".tgz" {
+			data, err = buildpackDataFromArchive(bpPath)
+		} else {
+			_, err = toml.DecodeFile(filepath.Join(bpPath, "buildpack.toml"), &data)
+		}
+		if err != nil {
+			return "", "", false, errors.Wrapf(err, "reading buildpack.toml of buildpack %s", style.Symbol(b.ID))
+		}
+		return data.BP.ID, data.BP.Version, true, nil
+	case "http", "https":
+		entry, found, err := dlcache.New(f.Config.Path()).Lookup(b.URI)
+		if err != nil || !found || entry.BuildpackID == "" {
+			return "", "", false, err
+		}
+		return entry.BuildpackID, entry.BuildpackVersion, true, nil
+	case "docker":
+		return "", "", false, nil
+	default:
+		return "", "", false, fmt.Errorf("unsupported protocol in URI %q", b.URI)
+	}
+}
+
+// buildpackDataFromArchive reads buildpack.toml from a buildpack .tgz without extracting it
+func buildpackDataFromArchive(archivePath string) (BuildpackData, error) {
+	var data BuildpackData
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return data, err
+	}
+	defer file.Close()
+	gzr, err := gzip.NewReader(file)
+	if err != nil {
+		return data, errors.Wrapf(err, "could not unzip %s", archivePath)
+	}
+	defer gzr.Close()
+
+	tr := tar.NewReader(gzr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return data, fmt.Errorf("%s does not contain buildpack.toml", archivePath)
+		}
+		if err != nil {
+			return data, err
+		}
+		if path.Clean(strings.TrimPrefix(hdr.Name, "/")) == "buildpack.toml" {
+			_, err := toml.DecodeReader(tr, &data)
+			return data, err
+		}
+	}
+}
+
+// builderTOMLLines holds the line numbers of the tables of a builder config. BurntSushi/toml only reports positions
+// of syntax errors, so they are found by scanning the file.
+type builderTOMLLines struct {
+	buildpacks      []int
+	removals        []int
+	groups          []int
+	groupBuildpacks [][]int
+	stack           int
+	lifecycle       int
+	env             int
+}
+
+func scanBuilderTOMLLines(contents []byte) builderTOMLLines {
+	var lines builderTOMLLines
+	inGroup := false
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "[[buildpacks]]"):
+			lines.buildpacks = append(lines.buildpacks, n)
+			inGroup = false
+		case strings.HasPrefix(line, "[[remove]]"):
+			lines.removals = append(lines.removals, n)
+			inGroup = false
+		case strings.HasPrefix(line, "[[groups]]"):
+			lines.groups = append(lines.groups, n)
+			lines.groupBuildpacks = append(lines.groupBuildpacks, nil)
+			inGroup = true
+		case strings.HasPrefix(line, "[[groups.buildpacks]]") && inGroup:
+			last := len(lines.groupBuildpacks) - 1
+			lines.groupBuildpacks[last] = append(lines.groupBuildpacks[last], n)
+		case strings.HasPrefix(line, "[stack]"):
+			lines.stack = n
+			inGroup = false
+		case strings.HasPrefix(line, "[lifecycle]"):
+			lines.lifecycle = n
+			inGroup = false
+		case strings.HasPrefix(line, "[env]"):
+			lines.env = n
+			inGroup = false
+		case strings.HasPrefix(line, "["):
+			inGroup = false
+		case inGroup:
+			// inline tables of buildpacks = [ ... ], several of which may share a line
+			last := len(lines.groupBuildpacks) - 1
+			for range inlineBuildpackPattern.FindAllString(stripTOMLComment(line), -1) {
+				lines.groupBuildpacks[last] = append(lines.groupBuildpacks[last], n)
+			}
+		}
+	}
+	return lines
+}
+
+// inlineBuildpackPattern matches an inline table with an id key, such as { id = "some.bp", version = "1.2.3" }
+var inlineBuildpackPattern = regexp.MustCompile(`\{[^{}]*\bid\s*=[^{}]*\}`)
+
+// stripTOMLComment removes a comment from a line, ignoring # inside of strings
+func stripTOMLComment(line string) string {
+	var quote rune
+	for i, c := range line {
+		switch {
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote == 0 && (c == '"' || c == '\''):
+			quote = c
+		case quote == 0 && c == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// reconcile drops the lines of groups whose scanned buildpacks do not match the decoded config, so that problems
+// with their buildpacks are reported at the line of the group rather than at a wrong line
+func (l *builderTOMLLines) reconcile(builderTOML *BuilderTOML) {
+	for i, group := range builderTOML.Groups {
+		if i < len(l.groupBuildpacks) && len(l.groupBuildpacks[i]) != len(group.Buildpacks) {
+			l.groupBuildpacks[i] = nil
+		}
+	}
+}
+
+func (l builderTOMLLines) buildpack(i int) int {
+	return lineAt(l.buildpacks, i)
+}
+
+func (l builderTOMLLines) removal(i int) int {
+	return lineAt(l.removals, i)
+}
+
+func (l builderTOMLLines) group(i int) int {
+	return lineAt(l.groups, i)
+}
+
+func (l builderTOMLLines) groupBuildpack(i, j int) int {
+	if i < len(l.groupBuildpacks) {
+		if line := lineAt(l.groupBuildpacks[i], j); line > 0 {
+			return line
+		}
+	}
+	return l.group(i)
+}
+
+func lineAt(lines []int, i int) int {
+	if i < len(lines) {
+		return lines[i]
+	}
+	return 0
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,319 @@
+package pack_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fatih/color"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpack/pack"
+	"github.com/buildpack/pack/config"
+	"github.com/buildpack/pack/fs"
+	"github.com/buildpack/pack/logging"
+	h "github.com/buildpack/pack/testhelpers"
+)
+
+func TestBuilderValidation(t *testing.T) {
+	color.NoColor = true
+	spec.Run(t, "builder_validation", testBuilderValidation, spec.Parallel(), spec.Report(report.Terminal{}))
+}
+
+func testBuilderValidation(t *testing.T, when spec.G, it spec.S) {
+	var (
+		factory     pack.BuilderFactory
+		tmpDir      string
+		builderToml string
+		testdataDir string
+		outBuf      bytes.Buffer
+	)
+
+	it.Before(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "pack.builder.validation.test")
+		h.AssertNil(t, err)
+		cfg, err := config.New(filepath.Join(tmpDir, "home"))
+		h.AssertNil(t, err)
+		factory = pack.BuilderFactory{
+			FS:     &fs.FS{},
+			Logger: logging.NewLogger(&outBuf, &outBuf, true, false),
+			Config: cfg,
+		}
+		builderToml = filepath.Join(tmpDir, "builder.toml")
+		testdataDir, err = filepath.Abs("testdata")
+		h.AssertNil(t, err)
+	})
+
+	it.After(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	when("#ValidateBuilderConfig", func() {
+		it("accepts valid configs", func() {
+			builderTOML, err := factory.ValidateBuilderConfig(filepath.Join("testdata", "builder.toml"))
+			h.AssertNil(t, err)
+			h.AssertEq(t, len(builderTOML.Buildpacks), 3)
+		})
+
+		it("reports every problem with its line", func() {
+			h.AssertNil(t, ioutil.WriteFile(builderToml, []byte(fmt.Sprintf(`[[buildpacks]]
+id = "other.bp"
+uri = "%[1]s/some-path-1"
+
+[[buildpacks]]
+id = "some/bp2"
+uri = "%[1]s/some-path-2"
+latest = true
+
+[[buildpacks]]
+id = "some/bp2"
+uri = "%[1]s/some-latest-path-2"
+latest = true
+
+[[buildpacks]]
+id = "some.other.bp"
+uri = "%[1]s/used-to-test-various-uri-schemes/buildpack.tgz"
+
+[[groups]]
+buildpacks = [
+  { id = "some/bp2", version = "2.0.0" },
+  { id = "missing.bp", version = "1.0.0" },
+]
+
+[[groups]]
+buildpacks = []
+
+[stack]
+id = "com.example.stack"
+build-image = "some/build"
+`, testdataDir)), 0644))
+
+			_, err := factory.ValidateBuilderConfig(builderToml)
+			h.AssertNotNil(t, err)
+			configErr, ok := err.(*pack.BuilderConfigError)
+			h.AssertEq(t, ok, true)
+			h.AssertEq(t, configErr.Problems, []string{
+				builderToml + ":1: buildpack 'other.bp' does not match id 'some.bp1' in its buildpack.toml",
+				builderToml + ":10: buildpack 'some/bp2' has latest = true on more than one version, also at line 5",
+				builderToml + ":21: group references version '2.0.0' of buildpack 'some/bp2', which is not included (included versions: 1.2.4, 1.2.5)",
+				builderToml + ":22: group references buildpack 'missing.bp', which is not included in [[buildpacks]]",
+				builderToml + ":25: group has no buildpacks",
+				builderToml + ":28: stack is missing run-image",
+			})
+		})
+
+		it("reports buildpacks without a version", func() {
+			bpDir := filepath.Join(tmpDir, "bp")
+			h.AssertNil(t, os.MkdirAll(bpDir, 0755))
+			h.AssertNil(t, ioutil.WriteFile(filepath.Join(bpDir, "buildpack.toml"), []byte("[buildpack]\nid = \"some.bp\"\n"), 0644))
+			h.AssertNil(t, ioutil.WriteFile(builderToml, []byte(`[[buildpacks]]
+id = "some.bp"
+uri = "bp"
+
+[stack]
+id = "com.example.stack"
+build-image = "some/build"
+run-image = "some/run"
+`), 0644))
+
+			_, err := factory.ValidateBuilderConfig(builderToml)
+			h.AssertError(t, err, "invalid builder config "+builderToml+":\n  "+builderToml+":1: buildpack.toml of buildpack 'some.bp' must provide a version")
+		})
+
+		it("does not check versions of buildpacks that have not been downloaded", func() {
+			h.AssertNil(t, ioutil.WriteFile(builderToml, []byte(`[[buildpacks]]
+id = "some.bp"
+uri = "https://example.com/bp.tgz"
+
+[[groups]]
+buildpacks = [
+  { id = "some.bp", version = "1.2.3" },
+]
+
+[stack]
+id = "com.example.stack"
+build-image = "some/build"
+run-image = "some/run"
+`), 0644))
+
+			_, err := factory.ValidateBuilderConfig(builderToml)
+			h.AssertNil(t, err)
+		})
+
+		it("reports invalid lifecycles", func() {
+			h.AssertNil(t, ioutil.WriteFile(builderToml, []byte(`[stack]
+id = "com.example.stack"
+build-image = "some/build"
+run-image = "some/run"
+
+[lifecycle]
+uri = "lifecycle.zip"
+`), 0644))
+
+			_, err := factory.ValidateBuilderConfig(builderToml)
+			h.AssertNotNil(t, err)
+			configErr, ok := err.(*pack.BuilderConfigError)
+			h.AssertEq(t, ok, true)
+			h.AssertEq(t, configErr.Problems, []string{
+				builderToml + ":6: lifecycle is missing version",
+				builderToml + `:6: lifecycle uri "lifecycle.zip" must be a .tgz archive`,
+			})
+		})
+
+		it("reports invalid names in the build environment", func() {
+			h.AssertNil(t, ioutil.WriteFile(builderToml, []byte(`[stack]
+id = "com.example.stack"
+build-image = "some/build"
+run-image = "some/run"
+
+[env]
+BP_NO_TELEMETRY = "1"
+"MIRROR-URL" = "https://mirror.example.com"
+`), 0644))
+
+			_, err := factory.ValidateBuilderConfig(builderToml)
+			h.AssertError(t, err, "invalid builder config "+builderToml+":\n  "+builderToml+":6: invalid environment variable name 'MIRROR-URL', names may only contain letters, digits and underscores and may not start with a digit")
+		})
+
+		it("reports names in the build environment that would not name a file in /platform/env", func() {
+			h.AssertNil(t, ioutil.WriteFile(builderToml, []byte(`[stack]
+id = "com.example.stack"
+build-image = "some/build"
+run-image = "some/run"
+
+[env]
+"" = "empty"
+"." = "dot"
+".." = "dot-dot"
+"../etc/some-file" = "parent"
+"some/file" = "slash"
+`), 0644))
+
+			_, err := factory.ValidateBuilderConfig(builderToml)
+			configErr, ok := err.(*pack.BuilderConfigError)
+			h.AssertEq(t, ok, true)
+			var names []string
+			for _, problem := range configErr.Problems {
+				h.AssertContains(t, problem, builderToml+":6: invalid environment variable name")
+				names = append(names, strings.Split(problem, "'")[1])
+			}
+			h.AssertEq(t, names, []string{"", ".", "..", "../etc/some-file", "some/file"})
+		})
+
+		it("accepts optional buildpacks and group descriptions", func() {
+			h.AssertNil(t, ioutil.WriteFile(builderToml, []byte(`[[buildpacks]]
+id = "some.bp"
+uri = "https://example.com/bp.tgz"
+
+[[buildpacks]]
+id = "apm.bp"
+uri = "https://example.com/apm.tgz"
+
+[[groups]]
+description = "Java apps with opt-in APM"
+buildpacks = [
+  { id = "some.bp", version = "1.2.3" },
+  { id = "apm.bp", version = "1.0.0", optional = true },
+]
+
+[stack]
+id = "com.example.stack"
+build-image = "some/build"
+run-image = "some/run"
+`), 0644))
+
+			builderTOML, err := factory.ValidateBuilderConfig(builderToml)
+			h.AssertNil(t, err)
+			h.AssertEq(t, builderTOML.Groups[0].Description, "Java apps with opt-in APM")
+			h.AssertEq(t, builderTOML.Groups[0].Buildpacks[0].Optional, false)
+			h.AssertEq(t, builderTOML.Groups[0].Buildpacks[1].Optional, true)
+		})
+
+		it("reports unknown keys, invalid descriptions and only optional buildpacks in groups", func() {
+			h.AssertNil(t, ioutil.WriteFile(builderToml, []byte(`[[buildpacks]]
+id = "some.bp"
+uri = "https://example.com/bp.tgz"
+
+[[groups]]
+description = "Some apps"
+buildpacks = [
+  { id = "some.bp", version = "1.2.3", optinal = true },
+]
+
+[[groups]]
+description = "Some apps"
+buildpacks = [
+  { id = "some.bp", version = "1.2.3" },
+]
+
+[[groups]]
+description = """
+Other
+apps"""
+buildpacks = [
+  { id = "some.bp", version = "1.2.3" },
+]
+
+[[groups]]
+buildpacks = [
+  { id = "some.bp", version = "1.2.3", optional = true },
+]
+
+[stack]
+id = "com.example.stack"
+build-image = "some/build"
+run-image = "some/run"
+`), 0644))
+
+			_, err := factory.ValidateBuilderConfig(builderToml)
+			h.AssertNotNil(t, err)
+			configErr, ok := err.(*pack.BuilderConfigError)
+			h.AssertEq(t, ok, true)
+			h.AssertEq(t, configErr.Problems, []string{
+				"unknown key 'groups.buildpacks.optinal' in [[groups]]",
+				builderToml + ":11: group description \"Some apps\" is already used by the group at line 5",
+				builderToml + ":17: group description must be a single line",
+				builderToml + ":25: group must contain at least one buildpack that is not optional",
+			})
+		})
+
+		it("reports the line of each group buildpack when several share a line", func() {
+			h.AssertNil(t, ioutil.WriteFile(builderToml, []byte(`[[buildpacks]]
+id = "some.bp"
+uri = "https://example.com/bp.tgz"
+
+[[groups]]
+# { id = "commented.bp" }
+buildpacks = [{ id = "missing.bp", version = "1.0.0" }, { id = "some.bp", version = "1.2.3" }, { id = "other.missing.bp", version = "1.0.0" }]
+
+[[groups]]
+buildpacks = [
+  { id = "some.bp", version = "1.2.3" }, { id = "another.missing.bp", version = "1.0.0" },
+  { id = "last.missing.bp", version = "1.0.0" }, # { id = "commented.bp" }
+]
+
+[stack]
+id = "com.example.stack"
+build-image = "some/build"
+run-image = "some/run"
+`), 0644))
+
+			_, err := factory.ValidateBuilderConfig(builderToml)
+			h.AssertNotNil(t, err)
+			configErr, ok := err.(*pack.BuilderConfigError)
+			h.AssertEq(t, ok, true)
+			h.AssertEq(t, configErr.Problems, []string{
+				builderToml + ":7: group references buildpack 'missing.bp', which is not included in [[buildpacks]]",
+				builderToml + ":7: group references buildpack 'other.missing.bp', which is not included in [[buildpacks]]",
+				builderToml + ":11: group references buildpack 'another.missing.bp', which is not included in [[buildpacks]]",
+				builderToml + ":12: group references buildpack 'last.missing.bp', which is not included in [[buildpacks]]",
+			})
+		})
+	})
+}
@@ -1,6 +1,7 @@
 package pack
 
 import (
+	"path"
 	"strings"
 )
 
@@ -8,6 +9,7 @@ type Buildpack struct {
 	ID      string `toml:"id"`
 	URI     string `toml:"uri"`
 	Latest  bool   `toml:"latest"`
+	SHA256  string `toml:"sha256"`
 	Dir     string
 	Version string
 }
@@ -15,3 +17,8 @@ type Buildpack struct {
 func (b *Buildpack) escapedID() string {
 	return strings.Replace(b.ID, "/", "_", -1)
 }
+
+// layerDir is where the buildpack is placed in builder and buildpack images
+func (b *Buildpack) layerDir() string {
+	return path.Join(buildpacksDir, b.escapedID(), b.Version)
+}
@@ -0,0 +1,260 @@
+package pack
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/buildpack/lifecycle/image"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/daemon"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/pkg/errors"
+
+	"github.com/buildpack/pack/style"
+)
+
+const (
+	dockerURIPrefix   = "docker://"
+	registryURIPrefix = "urn:cnb:registry:"
+)
+
+// RegistryIndex lists the buildpacks that urn:cnb:registry:<id>@<version> URIs resolve to. Its location is set by
+// registry-index in config.toml.
+type RegistryIndex struct {
+	Buildpacks []RegistryIndexEntry `toml:"buildpacks"`
+}
+
+type RegistryIndexEntry struct {
+	ID      string `toml:"id"`
+	Version string `toml:"version"`
+	URI     string `toml:"uri"`
+	SHA256  string `toml:"sha256"`
+}
+
+// resolveRegistryURI returns the buildpack an urn:cnb:registry URI refers to in the registry index
+func (f *BuilderFactory) resolveRegistryURI(b Buildpack) (Buildpack, error) {
+	ref := strings.TrimPrefix(b.URI, registryURIPrefix)
+	parts := strings.SplitN(ref, "@", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return Buildpack{}, fmt.Errorf("invalid buildpack URI %q: must be of the form %s<id>@<version>", b.URI, registryURIPrefix)
+	}
+	id, version := parts[0], parts[1]
+	if id != b.ID {
+		return Buildpack{}, fmt.Errorf("buildpack URI %q does not match buildpack ID %s", b.URI, style.Symbol(b.ID))
+	}
+
+	if f.Config.RegistryIndex == "" {
+		return Buildpack{}, fmt.Errorf("cannot resolve %q: no registry-index is set in %s", b.URI, filepath.Join(f.Config.Path(), "config.toml"))
+	}
+	index := RegistryIndex{}
+	if _, err := toml.DecodeFile(f.Config.RegistryIndex, &index); err != nil {
+		return Buildpack{}, errors.Wrapf(err, "reading registry index %s", f.Config.RegistryIndex)
+	}
+
+	var versions []string
+	for _, entry := range index.Buildpacks {
+		if entry.ID != id {
+			continue
+		}
+		if entry.Version == version {
+			if strings.HasPrefix(entry.URI, registryURIPrefix) {
+				return Buildpack{}, fmt.Errorf("registry index entry for %s must not refer to another registry URI", style.Symbol(id+"@"+version))
+			}
+			f.Logger.Verbose("Resolved %q to %q", b.URI, entry.URI)
+			sha := entry.SHA256
+			if b.SHA256 != "" {
+				sha = b.SHA256
+			}
+			return Buildpack{ID: b.ID, URI: entry.URI, Latest: b.Latest, SHA256: sha}, nil
+		}
+		versions = append(versions, entry.Version)
+	}
+	if len(versions) == 0 {
+		return Buildpack{}, fmt.Errorf("buildpack %s is not in registry index %s", style.Symbol(id), f.Config.RegistryIndex)
+	}
+	sort.Strings(versions)
+	return Buildpack{}, fmt.Errorf("version %s of buildpack %s is not in registry index %s (available versions: %s)", style.Symbol(version), style.Symbol(id), f.Config.RegistryIndex, strings.Join(versions, ", "))
+}
+
+// extractBuildpackage extracts the buildpack directory from the layers of a buildpack image (buildpackage)
+// referenced by a docker:// URI. The image is read from the registry when publishing, otherwise it is pulled into the
+// daemon unless offline, which also serves as its cache. It returns the buildpack directory and the temporary directory
+// containing it.
+func (f *BuilderFactory) extractBuildpackage(b Buildpack, flags CreateBuilderFlags) (string, string, error) {
+	ref := strings.TrimPrefix(b.URI, dockerURIPrefix)
+	if b.SHA256 != "" {
+		return "", "", fmt.Errorf("sha256 of buildpack %s can only be verified for .tgz archives, pin %q by digest instead", style.Symbol(b.ID), b.URI)
+	}
+
+	fromRegistry := flags.Publish && !flags.Offline
+	var img image.Image
+	var err error
+	if fromRegistry {
+		img, err = f.ImageFactory.NewRemote(ref)
+	} else {
+		img, err = f.ImageFactory.NewLocal(ref, !flags.Offline)
+	}
+	if err != nil {
+		return "", "", errors.Wrapf(err, "opening buildpack image %s", style.Symbol(ref))
+	}
+	if found, err := img.Found(); err != nil {
+		return "", "", err
+	} else if !found {
+		return "", "", fmt.Errorf("buildpack image %s does not exist", style.Symbol(ref))
+	}
+	version, err := buildpackageLabelVersion(img, b)
+	if err != nil {
+		return "", "", err
+	}
+
+	layers, err := f.ImageReader.Read(ref, fromRegistry)
+	if err != nil {
+		return "", "", errors.Wrapf(err, "reading buildpack image %s", style.Symbol(ref))
+	}
+	tmpDir, err := ioutil.TempDir("", fmt.Sprintf("create-builder-%s-", b.escapedID()))
+	if err != nil {
+		return "", "", fmt.Errorf(`failed to create temporary directory: %s`, err)
+	}
+	// every version of the buildpack in the image is extracted, the version to use is only known afterwards for
+	// images without the label
+	rc := mutate.Extract(layers)
+	defer rc.Close()
+	entries := rerootTar(rc, strings.TrimPrefix(path.Join(buildpacksDir, b.escapedID()), "/")+"/")
+	defer entries.Close()
+	if err := f.FS.Untar(entries, tmpDir); err != nil {
+		os.RemoveAll(tmpDir)
+		return "", "", errors.Wrapf(err, "extracting buildpack %s from buildpack image %s", style.Symbol(b.ID), style.Symbol(ref))
+	}
+
+	if version == "" {
+		if version, err = buildpackageDirVersion(tmpDir, ref, b); err != nil {
+			os.RemoveAll(tmpDir)
+			return "", "", err
+		}
+	}
+	dir := filepath.Join(tmpDir, version)
+	if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+		os.RemoveAll(tmpDir)
+		return "", "", fmt.Errorf("buildpack image %s does not contain version %s of buildpack %s", style.Symbol(ref), style.Symbol(version), style.Symbol(b.ID))
+	}
+	// the directory becomes the buildpack directory in the builder, which must be readable by the build user
+	if err := os.Chmod(dir, 0755); err != nil {
+		os.RemoveAll(tmpDir)
+		return "", "", err
+	}
+	return dir, tmpDir, nil
+}
+
+// buildpackageLabelVersion returns the version of the buildpack in a buildpack image read from its BuildpackageLabel,
+// or an empty string for images without it
+func buildpackageLabelVersion(img image.Image, b Buildpack) (string, error) {
+	ref := strings.TrimPrefix(b.URI, dockerURIPrefix)
+	metadataJSON, err := img.Label(BuildpackageLabel)
+	if err != nil {
+		return "", err
+	}
+	if metadataJSON == "" {
+		return "", nil
+	}
+	var metadata BuildpackageMetadata
+	if err := json.Unmarshal([]byte(metadataJSON), &metadata); err != nil {
+		return "", errors.Wrapf(err, "parsing label %s of buildpack image %s", style.Symbol(BuildpackageLabel), style.Symbol(ref))
+	}
+	if metadata.ID != b.ID {
+		return "", fmt.Errorf("buildpack image %s contains buildpack %s, not %s", style.Symbol(ref), style.Symbol(metadata.ID), style.Symbol(b.ID))
+	}
+	// the version names the directory of the buildpack, it must not climb out of it
+	if version := metadata.Version; version == "" || version == "." || strings.ContainsAny(version, `/\`) || strings.Contains(version, "..") {
+		return "", fmt.Errorf("buildpack image %s contains buildpack %s with invalid version %q", style.Symbol(ref), style.Symbol(b.ID), metadata.Version)
+	}
+	return metadata.Version, nil
+}
+
+// buildpackageDirVersion returns the only version directory of a buildpack extracted from a buildpack image without
+// the BuildpackageLabel
+func buildpackageDirVersion(dir, ref string, b Buildpack) (string, error) {
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	var versions []string
+	for _, fi := range fis {
+		if fi.IsDir() && fi.Name() != "latest" {
+			versions = append(versions, fi.Name())
+		}
+	}
+	if len(versions) == 0 {
+		return "", fmt.Errorf("buildpack image %s does not contain buildpack %s", style.Symbol(ref), style.Symbol(b.ID))
+	}
+	if len(versions) != 1 {
+		return "", fmt.Errorf("buildpack image %s has no %s label and contains %d versions of buildpack %s (%s)", style.Symbol(ref), style.Symbol(BuildpackageLabel), len(versions), style.Symbol(b.ID), strings.Join(versions, ", "))
+	}
+	return versions[0], nil
+}
+
+// rerootTar returns the entries of the tar archive r below prefix, renamed relative to it
+func rerootTar(r io.Reader, prefix string) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		tr := tar.NewReader(r)
+		tw := tar.NewWriter(pw)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				pw.CloseWithError(tw.Close())
+				return
+			}
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			name := strings.TrimPrefix(strings.TrimPrefix(hdr.Name, "./"), "/")
+			if !strings.HasPrefix(name, prefix) || name == prefix {
+				continue
+			}
+			hdr.Name = strings.TrimPrefix(name, prefix)
+			if hdr.Typeflag == tar.TypeLink {
+				link := strings.TrimPrefix(strings.TrimPrefix(hdr.Linkname, "./"), "/")
+				if !strings.HasPrefix(link, prefix) {
+					continue
+				}
+				hdr.Linkname = strings.TrimPrefix(link, prefix)
+			}
+			if err := tw.WriteHeader(hdr); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			if _, err := io.Copy(tw, tr); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+	}()
+	return pr
+}
+
+// DefaultImageReader reads images from a registry with the credentials of the docker config, or from the docker daemon
+type DefaultImageReader struct{}
+
+func (DefaultImageReader) Read(repoName string, fromRegistry bool) (v1.Image, error) {
+	ref, err := name.ParseReference(repoName, name.WeakValidation)
+	if err != nil {
+		return nil, err
+	}
+	if fromRegistry {
+		return remote.Image(ref, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+	}
+	return daemon.Image(ref)
+}
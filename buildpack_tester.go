@@ -0,0 +1,263 @@
+package pack
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	lifecyclecmd "github.com/buildpack/lifecycle/cmd"
+	"github.com/docker/docker/api/types/container"
+	dockerclient "github.com/docker/docker/client"
+	"github.com/pkg/errors"
+
+	"github.com/buildpack/pack/containers"
+	"github.com/buildpack/pack/docker"
+	"github.com/buildpack/pack/style"
+)
+
+type TestBuildpackFlags struct {
+	BuildpackDir string
+	AppDir       string
+	Builder      string
+	SpecPath     string
+	NoPull       bool
+	Build        bool
+}
+
+// BuildpackTestSpec describes the expected outcome of running a buildpack against an app
+type BuildpackTestSpec struct {
+	Detect string   `toml:"detect"` // pass (the default) or fail
+	Plan   []string `toml:"plan"`   // entries the build plan contains after detection
+	Layers []string `toml:"layers"` // layers created by the build, checking them implies running the build
+}
+
+type TestBuildpackConfig struct {
+	Buildpack Buildpack
+	Spec      BuildpackTestSpec
+	RunBuild  bool
+	Build     *BuildConfig
+}
+
+// TestBuildpackConfigFromFlags prepares running the lifecycle of a builder against an app, with the builder's
+// buildpacks replaced by the directory buildpack being tested
+func (bf *BuildFactory) TestBuildpackConfigFromFlags(f *TestBuildpackFlags) (*TestBuildpackConfig, error) {
+	bpDir, err := filepath.Abs(f.BuildpackDir)
+	if err != nil {
+		return nil, err
+	}
+	if !isDirectoryBuildpack(bpDir) {
+		return nil, fmt.Errorf("%s is not a buildpack directory: buildpack.toml not found", style.Symbol(f.BuildpackDir))
+	}
+	bp, err := readBuildpackTOML(bpDir)
+	if err != nil {
+		return nil, err
+	}
+
+	spec := BuildpackTestSpec{}
+	if f.SpecPath != "" {
+		if _, err := toml.DecodeFile(f.SpecPath, &spec); err != nil {
+			return nil, errors.Wrapf(err, "reading test spec %s", f.SpecPath)
+		}
+	}
+	switch spec.Detect {
+	case "":
+		spec.Detect = "pass"
+	case "pass":
+	case "fail":
+		if len(spec.Plan) > 0 || len(spec.Layers) > 0 {
+			return nil, fmt.Errorf("invalid test spec %s: plan and layers cannot be expected when detect fails", f.SpecPath)
+		}
+	default:
+		return nil, fmt.Errorf("invalid test spec %s: detect must be %s or %s, not %s", f.SpecPath, style.Symbol("pass"), style.Symbol("fail"), style.Symbol(spec.Detect))
+	}
+
+	appDir, err := filepath.Abs(f.AppDir)
+	if err != nil {
+		return nil, err
+	}
+	if fi, err := os.Stat(appDir); err != nil {
+		return nil, errors.Wrapf(err, "invalid app %s", style.Symbol(f.AppDir))
+	} else if !fi.IsDir() {
+		return nil, fmt.Errorf("invalid app %s: not a directory", style.Symbol(f.AppDir))
+	}
+
+	builder := f.Builder
+	if builder == "" {
+		bf.Logger.Verbose("Using default builder image %s", style.Symbol(bf.Config.DefaultBuilder))
+		builder = bf.Config.DefaultBuilder
+	}
+	builderImage, err := bf.ImageFactory.NewLocal(builder, !f.NoPull)
+	if err != nil {
+		return nil, err
+	}
+	if stackID, err := builderImage.Label(StackLabel); err != nil {
+		return nil, fmt.Errorf("invalid builder image %s: %s", style.Symbol(builder), err)
+	} else if stackID == "" {
+		return nil, fmt.Errorf("invalid builder image %s: missing required label %s", style.Symbol(builder), style.Symbol(StackLabel))
+	}
+
+	return &TestBuildpackConfig{
+		Buildpack: bp,
+		Spec:      spec,
+		RunBuild:  f.Build || len(spec.Layers) > 0,
+		Build: &BuildConfig{
+			AppDir:       appDir,
+			Builder:      builder,
+			Buildpacks:   []string{bpDir},
+			Cli:          bf.Cli,
+			Logger:       bf.Logger,
+			FS:           bf.FS,
+			Config:       bf.Config,
+			ImageFactory: bf.ImageFactory,
+			Cache:        bf.Cache,
+		},
+	}, nil
+}
+
+// Run runs detection, and the build when requested, and returns an error listing every expectation of the test
+// spec that was not met
+func (t *TestBuildpackConfig) Run(ctx context.Context) error {
+	b := t.Build
+	defer b.Cache.Clear(context.Background())
+
+	var failures []string
+	detected := true
+	if err := b.Detect(ctx); err != nil {
+		if exitErr, ok := errors.Cause(err).(*docker.ExitError); !ok || exitErr.StatusCode != lifecyclecmd.CodeFailedDetect {
+			return err
+		}
+		detected = false
+	}
+	if detected != (t.Spec.Detect == "pass") {
+		failures = append(failures, fmt.Sprintf("expected detect to %s, but it did not", t.Spec.Detect))
+	}
+
+	if detected && len(t.Spec.Plan) > 0 {
+		plan, err := t.planEntries(ctx)
+		if err != nil {
+			return err
+		}
+		for _, entry := range missingEntries(t.Spec.Plan, plan) {
+			failures = append(failures, fmt.Sprintf("expected plan entry %s, found: %s", style.Symbol(entry), strings.Join(plan, ", ")))
+		}
+	}
+
+	if detected && t.RunBuild {
+		uid, gid, err := b.packUidGid(ctx, b.Builder)
+		if err != nil {
+			return errors.Wrap(err, "get pack uid and gid")
+		}
+		if err := b.chownDir(ctx, launchDir, uid, gid); err != nil {
+			return errors.Wrap(err, "chown launch dir")
+		}
+		b.Logger.Verbose(style.Step("BUILDING"))
+		if err := b.Build(ctx); err != nil {
+			failures = append(failures, fmt.Sprintf("expected build to succeed: %s", err))
+		} else if len(t.Spec.Layers) > 0 {
+			layers, err := t.layers(ctx)
+			if err != nil {
+				return err
+			}
+			for _, layer := range missingEntries(t.Spec.Layers, layers) {
+				failures = append(failures, fmt.Sprintf("expected layer %s, found: %s", style.Symbol(layer), strings.Join(layers, ", ")))
+			}
+		}
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("buildpack %s did not meet %d expectation(s):\n  %s", style.Symbol(t.Buildpack.ID), len(failures), strings.Join(failures, "\n  "))
+	}
+	return nil
+}
+
+// planEntries returns the names of the entries of the build plan written by the detector
+func (t *TestBuildpackConfig) planEntries(ctx context.Context) ([]string, error) {
+	var entries []string
+	err := t.copyFromWorkspace(ctx, planPath, func(hdr *tar.Header, r io.Reader) error {
+		plan := map[string]interface{}{}
+		if _, err := toml.DecodeReader(r, &plan); err != nil {
+			return errors.Wrap(err, "reading build plan")
+		}
+		for entry := range plan {
+			entries = append(entries, entry)
+		}
+		return nil
+	})
+	sort.Strings(entries)
+	return entries, err
+}
+
+// layers returns the names of the layers the buildpack created in the workspace
+func (t *TestBuildpackConfig) layers(ctx context.Context) ([]string, error) {
+	var layers []string
+	layersDir := path.Join(launchDir, t.Buildpack.escapedID())
+	err := t.copyFromWorkspace(ctx, layersDir, func(hdr *tar.Header, r io.Reader) error {
+		// entries are named <escaped id>/<layer>/...
+		parts := strings.Split(strings.Trim(hdr.Name, "/"), "/")
+		if len(parts) == 2 && hdr.Typeflag == tar.TypeDir {
+			layers = append(layers, parts[1])
+		}
+		return nil
+	})
+	sort.Strings(layers)
+	return layers, err
+}
+
+func (t *TestBuildpackConfig) copyFromWorkspace(ctx context.Context, srcPath string, fn func(*tar.Header, io.Reader) error) error {
+	b := t.Build
+	ctr, err := b.Cli.ContainerCreate(ctx, &container.Config{
+		Image:  b.Builder,
+		Cmd:    []string{"true"},
+		Labels: map[string]string{"author": "pack"},
+	}, &container.HostConfig{
+		Binds: []string{
+			fmt.Sprintf("%s:%s:", b.Cache.Volume(), launchDir),
+		},
+	}, nil, "")
+	if err != nil {
+		return err
+	}
+	defer containers.Remove(b.Cli, ctr.ID)
+
+	rc, _, err := b.Cli.CopyFromContainer(ctx, ctr.ID, srcPath)
+	if dockerclient.IsErrNotFound(err) {
+		return nil
+	} else if err != nil {
+		return errors.Wrapf(err, "copying %s from workspace", srcPath)
+	}
+	defer rc.Close()
+	tr := tar.NewReader(rc)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrapf(err, "copying %s from workspace", srcPath)
+		}
+		if err := fn(hdr, tr); err != nil {
+			return err
+		}
+	}
+}
+
+func missingEntries(expected, actual []string) []string {
+	found := map[string]bool{}
+	for _, a := range actual {
+		found[a] = true
+	}
+	var result []string
+	for _, e := range expected {
+		if !found[e] {
+			result = append(result, e)
+		}
+	}
+	return result
+}
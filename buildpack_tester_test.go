@@ -0,0 +1,233 @@
+package pack_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/fatih/color"
+	"github.com/golang/mock/gomock"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpack/pack"
+	"github.com/buildpack/pack/config"
+	"github.com/buildpack/pack/docker"
+	"github.com/buildpack/pack/fs"
+	"github.com/buildpack/pack/logging"
+	"github.com/buildpack/pack/mocks"
+	h "github.com/buildpack/pack/testhelpers"
+)
+
+func TestBuildpackTester(t *testing.T) {
+	color.NoColor = true
+	if runtime.GOOS == "windows" {
+		t.Skip("directory buildpacks are not implemented on windows")
+	}
+	spec.Run(t, "buildpack_tester", testBuildpackTester, spec.Parallel(), spec.Report(report.Terminal{}))
+}
+
+func testBuildpackTester(t *testing.T, when spec.G, it spec.S) {
+	var (
+		mockController   *gomock.Controller
+		mockDocker       *mocks.MockDocker
+		mockCache        *mocks.MockCache
+		mockImageFactory *mocks.MockImageFactory
+		factory          *pack.BuildFactory
+		tmpDir, specPath string
+		outBuf           bytes.Buffer
+	)
+
+	writeSpec := func(contents string) {
+		t.Helper()
+		h.AssertNil(t, ioutil.WriteFile(specPath, []byte(contents), 0644))
+	}
+
+	flags := func() *pack.TestBuildpackFlags {
+		return &pack.TestBuildpackFlags{
+			BuildpackDir: filepath.Join(tmpDir, "buildpack"),
+			AppDir:       filepath.Join(tmpDir, "app"),
+			Builder:      "some/builder",
+			SpecPath:     specPath,
+			NoPull:       true,
+		}
+	}
+
+	it.Before(func() {
+		mockController = gomock.NewController(t)
+		mockDocker = mocks.NewMockDocker(mockController)
+		mockCache = mocks.NewMockCache(mockController)
+		mockImageFactory = mocks.NewMockImageFactory(mockController)
+
+		var err error
+		tmpDir, err = ioutil.TempDir("", "pack.buildpack.tester.test")
+		h.AssertNil(t, err)
+		specPath = filepath.Join(tmpDir, "spec.toml")
+		h.AssertNil(t, os.MkdirAll(filepath.Join(tmpDir, "app"), 0755))
+		h.AssertNil(t, os.MkdirAll(filepath.Join(tmpDir, "buildpack"), 0755))
+		h.AssertNil(t, ioutil.WriteFile(filepath.Join(tmpDir, "buildpack", "buildpack.toml"), []byte("[buildpack]\nid = \"some/bp\"\nversion = \"1.2.3\"\n"), 0644))
+
+		factory = &pack.BuildFactory{
+			Cli:          mockDocker,
+			Logger:       logging.NewLogger(&outBuf, &outBuf, true, false),
+			FS:           &fs.FS{},
+			Config:       &config.Config{},
+			ImageFactory: mockImageFactory,
+			Cache:        mockCache,
+		}
+
+		builderImage := mocks.NewMockImage(mockController)
+		builderImage.EXPECT().Label("io.buildpacks.stack.id").Return("some.stack", nil).AnyTimes()
+		mockImageFactory.EXPECT().NewLocal("some/builder", false).Return(builderImage, nil).AnyTimes()
+	})
+
+	it.After(func() {
+		mockController.Finish()
+		os.RemoveAll(tmpDir)
+	})
+
+	when("#TestBuildpackConfigFromFlags", func() {
+		it("builds when the spec expects layers", func() {
+			writeSpec("plan = [\"some-entry\"]\nlayers = [\"some-layer\"]\n")
+			cfg, err := factory.TestBuildpackConfigFromFlags(flags())
+			h.AssertNil(t, err)
+			h.AssertEq(t, cfg.Spec, pack.BuildpackTestSpec{Detect: "pass", Plan: []string{"some-entry"}, Layers: []string{"some-layer"}})
+			h.AssertEq(t, cfg.RunBuild, true)
+			h.AssertEq(t, len(cfg.Build.Buildpacks), 1)
+		})
+
+		it("rejects specs expecting a plan from failing detection", func() {
+			writeSpec("detect = \"fail\"\nplan = [\"some-entry\"]\n")
+			_, err := factory.TestBuildpackConfigFromFlags(flags())
+			h.AssertError(t, err, "invalid test spec "+specPath+": plan and layers cannot be expected when detect fails")
+		})
+
+		it("rejects unknown detect outcomes", func() {
+			writeSpec("detect = \"maybe\"\n")
+			_, err := factory.TestBuildpackConfigFromFlags(flags())
+			h.AssertError(t, err, "invalid test spec "+specPath+": detect must be 'pass' or 'fail', not 'maybe'")
+		})
+	})
+
+	when("#Run", func() {
+		var (
+			detectResult error
+			workspace    map[string][]*tar.Header
+		)
+
+		// workspaceTar returns the archive docker copies from the workspace for the given entries, directories end with /
+		workspaceTar := func(names ...string) []*tar.Header {
+			var headers []*tar.Header
+			for _, name := range names {
+				if strings.HasSuffix(name, "/") {
+					headers = append(headers, &tar.Header{Name: name, Typeflag: tar.TypeDir, Mode: 0755})
+				} else {
+					headers = append(headers, &tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644})
+				}
+			}
+			return headers
+		}
+
+		it.Before(func() {
+			detectResult = nil
+			workspace = map[string][]*tar.Header{}
+			mockDocker.EXPECT().CopyFromContainer(gomock.Any(), "true", gomock.Any()).
+				DoAndReturn(func(_ context.Context, _, srcPath string) (io.ReadCloser, types.ContainerPathStat, error) {
+					headers, ok := workspace[srcPath]
+					if !ok {
+						return nil, types.ContainerPathStat{}, fmt.Errorf("no workspace contents expected at %s", srcPath)
+					}
+					buf := &bytes.Buffer{}
+					tw := tar.NewWriter(buf)
+					for _, hdr := range headers {
+						contents := ""
+						if hdr.Name == "plan.toml" {
+							contents = "[some-entry]\nversion = \"1.0\"\n\n[other-entry]\n"
+						}
+						hdr.Size = int64(len(contents))
+						h.AssertNil(t, tw.WriteHeader(hdr))
+						_, err := tw.Write([]byte(contents))
+						h.AssertNil(t, err)
+					}
+					h.AssertNil(t, tw.Close())
+					return ioutil.NopCloser(buf), types.ContainerPathStat{}, nil
+				}).AnyTimes()
+			mockCache.EXPECT().Volume().Return("some-volume").AnyTimes()
+			mockCache.EXPECT().Clear(gomock.Any())
+			mockDocker.EXPECT().ContainerCreate(gomock.Any(), gomock.Any(), gomock.Any(), nil, "").
+				DoAndReturn(func(_ context.Context, cfg *container.Config, _ *container.HostConfig, _ *network.NetworkingConfig, _ string) (container.ContainerCreateCreatedBody, error) {
+					return container.ContainerCreateCreatedBody{ID: cfg.Cmd[0]}, nil
+				}).AnyTimes()
+			mockDocker.EXPECT().ContainerRemove(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes()
+			mockDocker.EXPECT().CopyToContainer(gomock.Any(), gomock.Any(), "/", gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ context.Context, _, _ string, content io.Reader, _ types.CopyToContainerOptions) error {
+					_, err := io.Copy(ioutil.Discard, content)
+					return err
+				}).AnyTimes()
+			mockDocker.EXPECT().ImageInspectWithRaw(gomock.Any(), "some/builder").
+				Return(types.ImageInspect{Config: &container.Config{Env: []string{"PACK_USER_ID=1000", "PACK_GROUP_ID=1000"}}}, nil, nil).AnyTimes()
+			mockDocker.EXPECT().RunContainer(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ context.Context, id string, _, _ io.Writer) error {
+					if id == "/lifecycle/detector" {
+						return detectResult
+					}
+					return nil
+				}).AnyTimes()
+		})
+
+		it("passes when detection fails as expected", func() {
+			writeSpec("detect = \"fail\"\n")
+			cfg, err := factory.TestBuildpackConfigFromFlags(flags())
+			h.AssertNil(t, err)
+			detectResult = &docker.ExitError{StatusCode: 6}
+			h.AssertNil(t, cfg.Run(context.Background()))
+		})
+
+		it("reports unmet expectations", func() {
+			writeSpec("")
+			cfg, err := factory.TestBuildpackConfigFromFlags(flags())
+			h.AssertNil(t, err)
+			detectResult = &docker.ExitError{StatusCode: 6}
+			h.AssertError(t, cfg.Run(context.Background()), "buildpack 'some/bp' did not meet 1 expectation(s):\n  expected detect to pass, but it did not")
+		})
+
+		it("passes when the plan contains the expected entries and the build creates the expected layers", func() {
+			writeSpec("plan = [\"some-entry\"]\nlayers = [\"some-layer\"]\n")
+			workspace["/workspace/plan.toml"] = workspaceTar("plan.toml")
+			workspace["/workspace/some_bp"] = workspaceTar("some_bp/", "some_bp/some-layer/", "some_bp/some-layer/file", "some_bp/some-layer.toml")
+			cfg, err := factory.TestBuildpackConfigFromFlags(flags())
+			h.AssertNil(t, err)
+			h.AssertNil(t, cfg.Run(context.Background()))
+		})
+
+		it("reports missing plan entries and layers", func() {
+			writeSpec("plan = [\"missing-entry\"]\nlayers = [\"missing-layer\"]\n")
+			workspace["/workspace/plan.toml"] = workspaceTar("plan.toml")
+			workspace["/workspace/some_bp"] = workspaceTar("some_bp/", "some_bp/some-layer/", "some_bp/some-layer/nested/")
+			cfg, err := factory.TestBuildpackConfigFromFlags(flags())
+			h.AssertNil(t, err)
+			h.AssertError(t, cfg.Run(context.Background()), "buildpack 'some/bp' did not meet 2 expectation(s):\n"+
+				"  expected plan entry 'missing-entry', found: other-entry, some-entry\n"+
+				"  expected layer 'missing-layer', found: some-layer")
+		})
+
+		it("returns errors other than failed detection", func() {
+			writeSpec("")
+			cfg, err := factory.TestBuildpackConfigFromFlags(flags())
+			h.AssertNil(t, err)
+			detectResult = &docker.ExitError{StatusCode: 1}
+			h.AssertError(t, cfg.Run(context.Background()), "run detect container: failed with status code: 1")
+		})
+	})
+}
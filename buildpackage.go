@@ -0,0 +1,166 @@
+package pack
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/daemon"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/pkg/errors"
+
+	"github.com/buildpack/pack/logging"
+	"github.com/buildpack/pack/style"
+)
+
+// BuildpackageFactory packages a buildpack directory as a .tgz archive, as accepted by create-builder, or as a
+// buildpack image (buildpackage) holding the buildpack in a single layer at the location it has in builders.
+type BuildpackageFactory struct {
+	Logger *logging.Logger
+	FS     FS
+}
+
+// ValidateBuildpack checks that dir holds a buildpack.toml providing an id and version, as well as executable
+// bin/detect and bin/build, and returns the buildpack it describes. All problems found are reported at once.
+func (f *BuildpackageFactory) ValidateBuildpack(dir string) (Buildpack, error) {
+	var problems []string
+
+	data := BuildpackData{}
+	tomlPath := filepath.Join(dir, "buildpack.toml")
+	if _, err := toml.DecodeFile(tomlPath, &data); err != nil {
+		problems = append(problems, fmt.Sprintf("reading %s: %s", tomlPath, err))
+	} else {
+		if data.BP.ID == "" {
+			problems = append(problems, fmt.Sprintf("%s must provide buildpack.id", tomlPath))
+		}
+		if data.BP.Version == "" {
+			problems = append(problems, fmt.Sprintf("%s must provide buildpack.version", tomlPath))
+		}
+	}
+
+	for _, bin := range []string{"detect", "build"} {
+		binPath := filepath.Join(dir, "bin", bin)
+		fi, err := os.Stat(binPath)
+		switch {
+		case os.IsNotExist(err):
+			problems = append(problems, fmt.Sprintf("%s does not exist", binPath))
+		case err != nil:
+			problems = append(problems, err.Error())
+		case !fi.Mode().IsRegular():
+			problems = append(problems, fmt.Sprintf("%s is not a file", binPath))
+		case fi.Mode()&0111 == 0:
+			problems = append(problems, fmt.Sprintf("%s is not executable", binPath))
+		}
+	}
+
+	if len(problems) > 0 {
+		return Buildpack{}, fmt.Errorf("invalid buildpack %s:\n  %s", dir, strings.Join(problems, "\n  "))
+	}
+	return Buildpack{ID: data.BP.ID, Version: data.BP.Version, Dir: dir}, nil
+}
+
+// CreateArchive writes the buildpack to a gzipped tar file with buildpack.toml at its root
+func (f *BuildpackageFactory) CreateArchive(bp Buildpack, archivePath string) error {
+	file, err := os.Create(archivePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// the gzip header carries no name or modification time, which keeps archives of the same buildpack identical
+	gzw := gzip.NewWriter(file)
+	tr, errChan := f.FS.CreateTarReader(bp.Dir, ".", 0, 0)
+	if _, err := io.Copy(gzw, tr); err != nil {
+		return errors.Wrapf(err, "writing %s", archivePath)
+	}
+	if err := <-errChan; err != nil {
+		return errors.Wrapf(err, "packaging buildpack %s", style.Symbol(bp.ID))
+	}
+	if err := gzw.Close(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// CreateImage writes a buildpack image holding the buildpack in a single layer to store
+func (f *BuildpackageFactory) CreateImage(bp Buildpack, store WritableStore) error {
+	tmpDir, err := ioutil.TempDir("", "package-buildpack")
+	if err != nil {
+		return fmt.Errorf(`failed to create temporary directory: %s`, err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	tarFile := filepath.Join(tmpDir, fmt.Sprintf("%s.%s.tar", bp.escapedID(), bp.Version))
+	if err := f.FS.CreateTarFile(tarFile, bp.Dir, bp.layerDir(), 0, 0); err != nil {
+		return err
+	}
+	layer, err := tarball.LayerFromFile(tarFile)
+	if err != nil {
+		return err
+	}
+	img, err := mutate.AppendLayers(empty.Image, layer)
+	if err != nil {
+		return err
+	}
+
+	jsonBytes, err := json.Marshal(&BuildpackageMetadata{ID: bp.ID, Version: bp.Version})
+	if err != nil {
+		return fmt.Errorf(`failed marshal buildpack image metadata: %s`, err)
+	}
+	img, err = mutate.Config(img, v1.Config{Labels: map[string]string{BuildpackageLabel: string(jsonBytes)}})
+	if err != nil {
+		return err
+	}
+	return store.Write(img)
+}
+
+// NewBuildpackageStore returns the store buildpack images named repoName are written to: the docker daemon, or the
+// registry when publishing
+func NewBuildpackageStore(repoName string, publish bool) (WritableStore, error) {
+	if publish {
+		ref, err := name.ParseReference(repoName, name.WeakValidation)
+		if err != nil {
+			return nil, err
+		}
+		return &registryStore{ref: ref}, nil
+	}
+	tag, err := name.NewTag(repoName, name.WeakValidation)
+	if err != nil {
+		return nil, err
+	}
+	return &daemonStore{tag: tag}, nil
+}
+
+type registryStore struct {
+	ref name.Reference
+}
+
+func (s *registryStore) Write(img v1.Image) error {
+	authenticator, err := authn.DefaultKeychain.Resolve(s.ref.Context().Registry)
+	if err != nil {
+		return err
+	}
+	return remote.Write(s.ref, img, authenticator, http.DefaultTransport)
+}
+
+type daemonStore struct {
+	tag name.Tag
+}
+
+func (s *daemonStore) Write(img v1.Image) error {
+	_, err := daemon.Write(s.tag, img)
+	return err
+}
@@ -0,0 +1,157 @@
+package pack_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/golang/mock/gomock"
+	"github.com/google/go-containerregistry/pkg/v1"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpack/pack"
+	"github.com/buildpack/pack/fs"
+	"github.com/buildpack/pack/logging"
+	"github.com/buildpack/pack/mocks"
+	h "github.com/buildpack/pack/testhelpers"
+)
+
+func TestBuildpackageFactory(t *testing.T) {
+	color.NoColor = true
+	if runtime.GOOS == "windows" {
+		t.Skip("buildpack executables cannot be checked on windows")
+	}
+	spec.Run(t, "buildpackage_factory", testBuildpackageFactory, spec.Parallel(), spec.Report(report.Terminal{}))
+}
+
+func testBuildpackageFactory(t *testing.T, when spec.G, it spec.S) {
+	var (
+		mockController *gomock.Controller
+		factory        pack.BuildpackageFactory
+		bpDir, tmpDir  string
+		outBuf         bytes.Buffer
+	)
+
+	it.Before(func() {
+		mockController = gomock.NewController(t)
+		factory = pack.BuildpackageFactory{
+			Logger: logging.NewLogger(&outBuf, &outBuf, true, false),
+			FS:     &fs.FS{Reproducible: true},
+		}
+
+		var err error
+		tmpDir, err = ioutil.TempDir("", "pack.buildpackage.test")
+		h.AssertNil(t, err)
+		bpDir = filepath.Join(tmpDir, "buildpack")
+		h.AssertNil(t, os.MkdirAll(filepath.Join(bpDir, "bin"), 0755))
+		h.AssertNil(t, ioutil.WriteFile(filepath.Join(bpDir, "buildpack.toml"), []byte(`[buildpack]
+id = "some/bp"
+version = "1.2.3"
+`), 0644))
+		h.AssertNil(t, ioutil.WriteFile(filepath.Join(bpDir, "bin", "detect"), []byte("#!/bin/sh\n"), 0755))
+		h.AssertNil(t, ioutil.WriteFile(filepath.Join(bpDir, "bin", "build"), []byte("#!/bin/sh\n"), 0755))
+	})
+
+	it.After(func() {
+		mockController.Finish()
+		os.RemoveAll(tmpDir)
+	})
+
+	when("#ValidateBuildpack", func() {
+		it("returns the buildpack described by buildpack.toml", func() {
+			bp, err := factory.ValidateBuildpack(bpDir)
+			h.AssertNil(t, err)
+			h.AssertEq(t, bp, pack.Buildpack{ID: "some/bp", Version: "1.2.3", Dir: bpDir})
+		})
+
+		it("reports all problems at once", func() {
+			h.AssertNil(t, ioutil.WriteFile(filepath.Join(bpDir, "buildpack.toml"), []byte("[buildpack]\nid = \"some/bp\"\n"), 0644))
+			h.AssertNil(t, os.Chmod(filepath.Join(bpDir, "bin", "detect"), 0644))
+			h.AssertNil(t, os.Remove(filepath.Join(bpDir, "bin", "build")))
+
+			_, err := factory.ValidateBuildpack(bpDir)
+			h.AssertNotNil(t, err)
+			h.AssertContains(t, err.Error(), "buildpack.toml must provide buildpack.version")
+			h.AssertContains(t, err.Error(), filepath.Join(bpDir, "bin", "detect")+" is not executable")
+			h.AssertContains(t, err.Error(), filepath.Join(bpDir, "bin", "build")+" does not exist")
+		})
+	})
+
+	when("#CreateArchive", func() {
+		it("writes a reproducible archive create-builder can use", func() {
+			bp, err := factory.ValidateBuildpack(bpDir)
+			h.AssertNil(t, err)
+
+			first, second := filepath.Join(tmpDir, "first.tgz"), filepath.Join(tmpDir, "second.tgz")
+			h.AssertNil(t, factory.CreateArchive(bp, first))
+			h.AssertNil(t, os.Chtimes(filepath.Join(bpDir, "bin", "build"), time.Now().Add(time.Hour), time.Now().Add(time.Hour)))
+			h.AssertNil(t, factory.CreateArchive(bp, second))
+
+			firstBytes, err := ioutil.ReadFile(first)
+			h.AssertNil(t, err)
+			secondBytes, err := ioutil.ReadFile(second)
+			h.AssertNil(t, err)
+			h.AssertEq(t, bytes.Equal(firstBytes, secondBytes), true)
+
+			file, err := os.Open(first)
+			h.AssertNil(t, err)
+			defer file.Close()
+			extracted := filepath.Join(tmpDir, "extracted")
+			h.AssertNil(t, os.Mkdir(extracted, 0755))
+			gzr, err := gzip.NewReader(file)
+			h.AssertNil(t, err)
+			h.AssertNil(t, (&fs.FS{}).Untar(gzr, extracted))
+			h.AssertDirContainsFileWithContents(t, extracted, "bin/detect", "#!/bin/sh\n")
+			h.AssertDirContainsFileWithContents(t, extracted, "buildpack.toml", "[buildpack]\nid = \"some/bp\"\nversion = \"1.2.3\"\n")
+		})
+	})
+
+	when("#CreateImage", func() {
+		it("writes a single layer image labeled with the buildpack", func() {
+			bp, err := factory.ValidateBuildpack(bpDir)
+			h.AssertNil(t, err)
+
+			// layers are read from temporary files, which only exist until the image is written
+			var labels map[string]string
+			var names []string
+			store := mocks.NewMockWritableStore(mockController)
+			store.EXPECT().Write(gomock.Any()).DoAndReturn(func(img v1.Image) error {
+				cfg, err := img.ConfigFile()
+				h.AssertNil(t, err)
+				labels = cfg.Config.Labels
+
+				layers, err := img.Layers()
+				h.AssertNil(t, err)
+				h.AssertEq(t, len(layers), 1)
+				rc, err := layers[0].Uncompressed()
+				h.AssertNil(t, err)
+				defer rc.Close()
+				tr := tar.NewReader(rc)
+				for {
+					hdr, err := tr.Next()
+					if err == io.EOF {
+						break
+					}
+					h.AssertNil(t, err)
+					names = append(names, hdr.Name)
+				}
+				return nil
+			})
+			h.AssertNil(t, factory.CreateImage(bp, store))
+
+			h.AssertEq(t, labels["io.buildpacks.buildpackage.metadata"], `{"id":"some/bp","version":"1.2.3"}`)
+			h.AssertContains(t, fmt.Sprint(names), "/buildpacks/some_bp/1.2.3/bin/detect")
+			h.AssertContains(t, fmt.Sprint(names), "/buildpacks/some_bp/1.2.3/buildpack.toml")
+		})
+	})
+}
@@ -41,11 +41,17 @@ func main() {
 	rootCmd.AddCommand(commands.Build(&logger, &dockerClient, &imageFactory))
 	rootCmd.AddCommand(commands.Run(&logger, &dockerClient, &imageFactory))
 	rootCmd.AddCommand(commands.Rebase(&logger, &imageFactory))
+	rootCmd.AddCommand(commands.InspectImage(&logger, &imageFactory))
 
 	rootCmd.AddCommand(commands.CreateBuilder(&logger, &imageFactory))
+	rootCmd.AddCommand(commands.ValidateBuilderConfig(&logger))
 	rootCmd.AddCommand(commands.SetRunImagesMirrors(&logger))
 	rootCmd.AddCommand(commands.InspectBuilder(&logger, &inspect, &imageFactory))
 	rootCmd.AddCommand(commands.SetDefaultBuilder(&logger))
+	rootCmd.AddCommand(commands.DlCache(&logger))
+	rootCmd.AddCommand(commands.PackageBuildpack(&logger))
+	rootCmd.AddCommand(commands.NewBuildpack(&logger))
+	rootCmd.AddCommand(commands.TestBuildpack(&logger, &dockerClient, &imageFactory))
 
 	rootCmd.AddCommand(commands.AddStack(&logger))
 	rootCmd.AddCommand(commands.UpdateStack(&logger))
@@ -11,6 +11,7 @@ import (
 
 func Build(logger *logging.Logger, dockerClient pack.Docker, imageFactory pack.ImageFactory) *cobra.Command {
 	var buildFlags pack.BuildFlags
+	var dryRun bool
 	ctx := createCancellableContext()
 
 	cmd := &cobra.Command{
@@ -33,6 +34,13 @@ func Build(logger *logging.Logger, dockerClient pack.Docker, imageFactory pack.I
 			if err != nil {
 				return err
 			}
+			if dryRun {
+				if err := b.DryRun(); err != nil {
+					return err
+				}
+				logger.Info("Build configuration for %s is valid", style.Symbol(b.RepoName))
+				return nil
+			}
 			if err := b.Run(ctx); err != nil {
 				return err
 			}
@@ -42,16 +50,20 @@ func Build(logger *logging.Logger, dockerClient pack.Docker, imageFactory pack.I
 	}
 	buildCommandFlags(cmd, &buildFlags)
 	cmd.Flags().BoolVar(&buildFlags.Publish, "publish", false, "Publish to registry")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Validate the build configuration and print the build plan without running it")
 	AddHelpFlag(cmd, "build")
 	return cmd
 }
 
 func buildCommandFlags(cmd *cobra.Command, buildFlags *pack.BuildFlags) {
-	cmd.Flags().StringVarP(&buildFlags.AppDir, "path", "p", "", "Path to app dir (defaults to current working directory)")
+	cmd.Flags().StringVarP(&buildFlags.AppDir, "path", "p", "", "Path to app dir or archive (.zip, .jar, .war, .tar, .tgz), or - to read an archive from stdin (defaults to current working directory)")
+	cmd.Flags().StringVar(&buildFlags.SubPath, "sub-path", "", "Path to the app within the app dir (or git repository when used with --git-ref)")
+	cmd.Flags().StringVar(&buildFlags.GitRef, "git-ref", "", "Build from a git commit, tag or branch of the repository at --path instead of the working tree")
 	cmd.Flags().StringVar(&buildFlags.Builder, "builder", "", "Builder (defaults to builder configured by 'set-default-builder')")
 	cmd.Flags().StringVar(&buildFlags.RunImage, "run-image", "", "Run image (defaults to default stack's run image)")
 	cmd.Flags().StringVar(&buildFlags.EnvFile, "env-file", "", "Build-time environment variables file\nOne variable per line, of the form 'VAR=VALUE' or 'VAR'\nWhen using latter value-less form, value will be taken from current\n  environment at the time this command is executed")
 	cmd.Flags().BoolVar(&buildFlags.NoPull, "no-pull", false, "Skip pulling builder and run images before use")
 	cmd.Flags().BoolVar(&buildFlags.ClearCache, "clear-cache", false, "Clear image's associated cache before building")
 	cmd.Flags().StringSliceVar(&buildFlags.Buildpacks, "buildpack", nil, "Buildpack ID, path to directory, or path/URL to .tgz file"+multiValueHelp("buildpack"))
+	cmd.Flags().StringVar(&buildFlags.Order, "order", "", "Path to order TOML file with groups of buildpacks to use instead of the builder's order\nBuildpacks may be marked 'optional = true'")
 }
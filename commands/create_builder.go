@@ -15,6 +15,7 @@ import (
 
 func CreateBuilder(logger *logging.Logger, imageFactory pack.ImageFactory) *cobra.Command {
 	flags := pack.CreateBuilderFlags{}
+	var reproducible bool
 	cmd := &cobra.Command{
 		Use:   "create-builder <image-name> --builder-config <builder-config-path>",
 		Args:  cobra.ExactArgs(1),
@@ -31,10 +32,11 @@ func CreateBuilder(logger *logging.Logger, imageFactory pack.ImageFactory) *cobr
 				return err
 			}
 			builderFactory := pack.BuilderFactory{
-				FS:           &fs.FS{},
+				FS:           &fs.FS{Reproducible: reproducible},
 				Logger:       logger,
 				Config:       cfg,
 				ImageFactory: imageFactory,
+				ImageReader:  pack.DefaultImageReader{},
 			}
 			builderConfig, err := builderFactory.BuilderConfigFromFlags(flags)
 			if err != nil {
@@ -50,9 +52,12 @@ func CreateBuilder(logger *logging.Logger, imageFactory pack.ImageFactory) *cobr
 		}),
 	}
 	cmd.Flags().BoolVar(&flags.NoPull, "no-pull", false, "Skip pulling build image before use")
+	cmd.Flags().BoolVar(&flags.Offline, "offline", false, "Only use buildpacks from the download cache instead of downloading them")
 	cmd.Flags().StringVarP(&flags.BuilderTomlPath, "builder-config", "b", "", "Path to builder TOML file (required)")
 	cmd.MarkFlagRequired("builder-config")
 	cmd.Flags().BoolVar(&flags.Publish, "publish", false, "Publish to registry")
+	cmd.Flags().StringVar(&flags.From, "from", "", "Extend this builder instead of creating a builder from the build image of the stack")
+	cmd.Flags().BoolVar(&reproducible, "reproducible", true, "Normalize timestamps (honoring SOURCE_DATE_EPOCH), permissions and ownership of buildpack layers")
 	AddHelpFlag(cmd, "create-builder")
 	return cmd
 }
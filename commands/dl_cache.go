@@ -0,0 +1,170 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/buildpack/pack/config"
+	"github.com/buildpack/pack/dlcache"
+	"github.com/buildpack/pack/logging"
+	"github.com/buildpack/pack/style"
+)
+
+func DlCache(logger *logging.Logger) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "dl-cache",
+		Short: "Inspect and prune the cache of downloaded buildpacks",
+	}
+	cmd.AddCommand(dlCacheList(logger))
+	cmd.AddCommand(dlCacheRemove(logger))
+	cmd.AddCommand(dlCachePrune(logger))
+	AddHelpFlag(cmd, "dl-cache")
+	return cmd
+}
+
+func dlCacheList(logger *logging.Logger) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "ls",
+		Args:  cobra.NoArgs,
+		Short: "List downloaded buildpacks",
+		RunE: logError(logger, func(cmd *cobra.Command, args []string) error {
+			cache, err := defaultDlCache()
+			if err != nil {
+				return err
+			}
+			entries, err := cache.List()
+			if err != nil {
+				return err
+			}
+			if entries == nil {
+				entries = []dlcache.Entry{}
+			}
+			result := struct {
+				Entries []dlcache.Entry `json:"entries"`
+			}{entries}
+			return writeOutput(cmd, result, func() error {
+				if len(entries) == 0 {
+					logger.Info("The download cache is empty")
+					return nil
+				}
+
+				var buf bytes.Buffer
+				w := tabwriter.NewWriter(&buf, 0, 0, 4, ' ', 0)
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", style.Noop("URI"), style.Noop("Buildpack"), style.Noop("Size"), style.Noop("Downloaded"))
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", style.Noop("---"), style.Noop("---------"), style.Noop("----"), style.Noop("----------"))
+				for _, entry := range entries {
+					uri, buildpack, size := "(unknown, downloaded by an older version of pack)", "", ""
+					if entry.URI != "" {
+						uri = entry.URI
+						buildpack = entry.BuildpackID
+						if entry.BuildpackVersion != "" {
+							buildpack += "@" + entry.BuildpackVersion
+						}
+						size = fmt.Sprintf("%d", entry.Size)
+					}
+					fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", style.Noop("%s", uri), style.Noop("%s", buildpack), style.Noop("%s", size), style.Noop("%s", entry.DownloadedAt.Format(time.RFC3339)))
+				}
+				if err := w.Flush(); err != nil {
+					return err
+				}
+				logger.Info("%s", buf.String())
+				return nil
+			})
+		}),
+	}
+	AddOutputFlag(cmd)
+	AddHelpFlag(cmd, "ls")
+	return cmd
+}
+
+func dlCacheRemove(logger *logging.Logger) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rm <uri>",
+		Args:  cobra.ExactArgs(1),
+		Short: "Remove a downloaded buildpack",
+		RunE: logError(logger, func(cmd *cobra.Command, args []string) error {
+			cache, err := defaultDlCache()
+			if err != nil {
+				return err
+			}
+			_, found, err := cache.Lookup(args[0])
+			if err != nil {
+				return err
+			}
+			if !found {
+				return fmt.Errorf("%s is not in the download cache", style.Symbol(args[0]))
+			}
+			if err := cache.Remove(args[0]); err != nil {
+				return err
+			}
+			logger.Info("Removed %s from the download cache", style.Symbol(args[0]))
+			return nil
+		}),
+	}
+	AddHelpFlag(cmd, "rm")
+	return cmd
+}
+
+func dlCachePrune(logger *logging.Logger) *cobra.Command {
+	var olderThan string
+	cmd := &cobra.Command{
+		Use:   "prune --older-than <age>",
+		Args:  cobra.NoArgs,
+		Short: "Remove buildpacks downloaded before a given age",
+		RunE: logError(logger, func(cmd *cobra.Command, args []string) error {
+			age, err := parseAge(olderThan)
+			if err != nil {
+				return err
+			}
+			cache, err := defaultDlCache()
+			if err != nil {
+				return err
+			}
+			removed, err := cache.Prune(time.Now().Add(-age))
+			if err != nil {
+				return err
+			}
+			for _, entry := range removed {
+				if entry.URI != "" {
+					logger.Verbose("Removed %s", style.Symbol(entry.URI))
+				} else {
+					logger.Verbose("Removed %s", style.Symbol(entry.Key))
+				}
+			}
+			logger.Info("Removed %d buildpack(s) from the download cache", len(removed))
+			return nil
+		}),
+	}
+	cmd.Flags().StringVar(&olderThan, "older-than", "", "Age of the buildpacks to remove, e.g. 72h or 30d (required)")
+	cmd.MarkFlagRequired("older-than")
+	AddHelpFlag(cmd, "prune")
+	return cmd
+}
+
+func defaultDlCache() (*dlcache.Cache, error) {
+	cfg, err := config.NewDefault()
+	if err != nil {
+		return nil, err
+	}
+	return dlcache.New(cfg.Path()), nil
+}
+
+// parseAge parses a Go duration, also accepting a number of days such as 30d
+func parseAge(age string) (time.Duration, error) {
+	if strings.HasSuffix(age, "d") {
+		if days, err := strconv.Atoi(strings.TrimSuffix(age, "d")); err == nil && days >= 0 {
+			return time.Duration(days) * 24 * time.Hour, nil
+		}
+	}
+	d, err := time.ParseDuration(age)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid age %s: use a duration such as 72h or a number of days such as 30d", style.Symbol(age))
+	}
+	return d, nil
+}
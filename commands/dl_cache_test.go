@@ -0,0 +1,163 @@
+package commands_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpack/pack/commands"
+	"github.com/buildpack/pack/dlcache"
+	"github.com/buildpack/pack/logging"
+	h "github.com/buildpack/pack/testhelpers"
+)
+
+func TestDlCacheCommand(t *testing.T) {
+	color.NoColor = true
+	spec.Run(t, "dl-cache", testDlCacheCommand, spec.Report(report.Terminal{}))
+}
+
+func testDlCacheCommand(t *testing.T, when spec.G, it spec.S) {
+	var (
+		packHome, oldPackHome string
+		cache                 *dlcache.Cache
+		logger                *logging.Logger
+		outBuf                bytes.Buffer
+	)
+
+	store := func(uri string, downloadedAt time.Time) {
+		t.Helper()
+		dir, err := cache.TempDir(uri)
+		h.AssertNil(t, err)
+		h.AssertNil(t, cache.Store(dir, dlcache.Entry{
+			URI:              uri,
+			SHA256:           "some-sha",
+			Size:             123,
+			DownloadedAt:     downloadedAt,
+			BuildpackID:      "some.bp",
+			BuildpackVersion: "1.2.3",
+		}))
+	}
+
+	run := func(args ...string) error {
+		t.Helper()
+		command := commands.DlCache(logger)
+		command.SetArgs(args)
+		return command.Execute()
+	}
+
+	it.Before(func() {
+		var err error
+		packHome, err = ioutil.TempDir("", "pack.dl-cache.command.test")
+		h.AssertNil(t, err)
+		oldPackHome = os.Getenv("PACK_HOME")
+		h.AssertNil(t, os.Setenv("PACK_HOME", packHome))
+		cache = dlcache.New(packHome)
+		outBuf.Reset()
+		logger = logging.NewLogger(&outBuf, &outBuf, true, false)
+	})
+
+	it.After(func() {
+		os.Setenv("PACK_HOME", oldPackHome)
+		os.RemoveAll(packHome)
+	})
+
+	when("ls", func() {
+		it("lists the downloaded buildpacks", func() {
+			store("https://example.com/some%2Fbp.tgz", time.Date(2019, 2, 1, 10, 0, 0, 0, time.UTC))
+
+			h.AssertNil(t, run("ls"))
+
+			h.AssertContains(t, outBuf.String(), "URI                                  Buildpack        Size    Downloaded")
+			h.AssertContains(t, outBuf.String(), "https://example.com/some%2Fbp.tgz    some.bp@1.2.3    123     2019-02-01T10:00:00Z")
+		})
+
+		it("writes json with --output json", func() {
+			store("https://example.com/bp.tgz", time.Date(2019, 2, 1, 10, 0, 0, 0, time.UTC))
+			var stdout bytes.Buffer
+
+			command := commands.DlCache(logger)
+			command.SetOutput(&stdout)
+			command.SetArgs([]string{"ls", "--output", "json"})
+			h.AssertNil(t, command.Execute())
+
+			h.AssertEq(t, outBuf.String(), "")
+			h.AssertEq(t, stdout.String(), `{
+  "entries": [
+    {
+      "uri": "https://example.com/bp.tgz",
+      "sha256": "some-sha",
+      "size": 123,
+      "downloadedAt": "2019-02-01T10:00:00Z",
+      "buildpackId": "some.bp",
+      "buildpackVersion": "1.2.3"
+    }
+  ]
+}
+`)
+		})
+
+		it("writes yaml with --output yaml", func() {
+			var stdout bytes.Buffer
+
+			command := commands.DlCache(logger)
+			command.SetOutput(&stdout)
+			command.SetArgs([]string{"ls", "--output", "yaml"})
+			h.AssertNil(t, command.Execute())
+
+			h.AssertEq(t, stdout.String(), "entries: []\n")
+		})
+
+		it("reports an empty cache", func() {
+			h.AssertNil(t, run("ls"))
+
+			h.AssertContains(t, outBuf.String(), "The download cache is empty")
+		})
+	})
+
+	when("rm", func() {
+		it("removes the buildpack downloaded from the given URI", func() {
+			store("https://example.com/bp.tgz", time.Now())
+
+			h.AssertNil(t, run("rm", "https://example.com/bp.tgz"))
+
+			h.AssertContains(t, outBuf.String(), "Removed 'https://example.com/bp.tgz' from the download cache")
+			_, found, err := cache.Lookup("https://example.com/bp.tgz")
+			h.AssertNil(t, err)
+			h.AssertEq(t, found, false)
+		})
+
+		it("returns an error for URIs that are not in the cache", func() {
+			err := run("rm", "https://example.com/missing.tgz")
+
+			h.AssertError(t, err, "'https://example.com/missing.tgz' is not in the download cache")
+		})
+	})
+
+	when("prune", func() {
+		it("removes the buildpacks downloaded before the given age", func() {
+			store("https://example.com/new.tgz", time.Now())
+			store("https://example.com/old.tgz", time.Now().Add(-72*time.Hour))
+
+			h.AssertNil(t, run("prune", "--older-than", "2d"))
+
+			h.AssertContains(t, outBuf.String(), "Removed 'https://example.com/old.tgz'")
+			h.AssertContains(t, outBuf.String(), "Removed 1 buildpack(s) from the download cache")
+			entries, err := cache.List()
+			h.AssertNil(t, err)
+			h.AssertEq(t, len(entries), 1)
+			h.AssertEq(t, entries[0].URI, "https://example.com/new.tgz")
+		})
+
+		it("returns an error for invalid ages", func() {
+			err := run("prune", "--older-than", "soon")
+
+			h.AssertError(t, err, "invalid age 'soon': use a duration such as 72h or a number of days such as 30d")
+		})
+	})
+}
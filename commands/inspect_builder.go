@@ -1,6 +1,10 @@
 package commands
 
 import (
+	"fmt"
+	"sort"
+	"strings"
+
 	"github.com/buildpack/lifecycle/image"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
@@ -16,51 +20,111 @@ type BuilderInspector interface {
 }
 
 func InspectBuilder(logger *logging.Logger, inspector BuilderInspector, imageFactory pack.ImageFactory) *cobra.Command {
+	var local, remote bool
 	cmd := &cobra.Command{
 		Use:   "inspect-builder <builder-image-name>",
 		Short: "Show information about a builder",
 		Args:  cobra.ExactArgs(1),
 		RunE: logError(logger, func(cmd *cobra.Command, args []string) error {
 			imageName := args[0]
-			for _, remote := range []bool{true, false} {
-				inspectBuilderOutput(logger, imageName, remote, imageFactory, inspector)
-				logger.Info("")
+			if !local && !remote {
+				local, remote = true, true
+			}
+			format, err := outputFormat(cmd)
+			if err != nil {
+				return err
+			}
+
+			var result struct {
+				Remote *pack.Builder `json:"remote,omitempty"`
+				Local  *pack.Builder `json:"local,omitempty"`
+			}
+			var remoteErr, localErr error
+			if remote {
+				result.Remote, remoteErr = inspectBuilder(imageName, true, imageFactory, inspector)
+			}
+			if local {
+				result.Local, localErr = inspectBuilder(imageName, false, imageFactory, inspector)
 			}
-			return nil
+
+			if format != tableOutput {
+				// structured output only holds the builders that could be inspected
+				for _, err := range []error{remoteErr, localErr} {
+					if err != nil {
+						logger.Error(err.Error())
+					}
+				}
+			}
+			return writeOutput(cmd, result, func() error {
+				if remote {
+					logger.Info("Remote\n------")
+					logBuilder(logger, result.Remote, remoteErr)
+					logger.Info("")
+				}
+				if local {
+					logger.Info("Local\n-----")
+					logBuilder(logger, result.Local, localErr)
+					logger.Info("")
+				}
+				if result.Remote != nil && result.Local != nil {
+					if diff := builderDiff(result.Remote, result.Local); len(diff) > 0 {
+						logger.Info("Differences between remote and local\n------------------------------------")
+						for _, d := range diff {
+							logger.Info("%s", d)
+						}
+						logger.Info("")
+					}
+				}
+				return nil
+			})
 		}),
 	}
+	cmd.Flags().BoolVar(&local, "local", false, "Only show the builder image in the local daemon")
+	cmd.Flags().BoolVar(&remote, "remote", false, "Only show the builder image in the registry")
+	AddOutputFlag(cmd)
 	AddHelpFlag(cmd, "inspect-builder")
 	return cmd
 }
 
-func inspectBuilderOutput(logger *logging.Logger, imageName string, remote bool, imageFactory pack.ImageFactory, inspector BuilderInspector) {
+// inspectBuilder returns the builder imageName from the registry or the daemon, or nil when it is not present
+func inspectBuilder(imageName string, remote bool, imageFactory pack.ImageFactory, inspector BuilderInspector) (*pack.Builder, error) {
 	var builderImage image.Image
 	var err error
 	if remote {
 		builderImage, err = imageFactory.NewRemote(imageName)
-		logger.Info("Remote\n------")
 	} else {
 		builderImage, err = imageFactory.NewLocal(imageName, false)
-		logger.Info("Local\n-----")
 	}
 	if err != nil {
-		logger.Error(errors.Wrapf(err, "failed to get image %s", style.Symbol(imageName)).Error())
-		return
+		return nil, errors.Wrapf(err, "failed to get image %s", style.Symbol(imageName))
 	}
 	if found, err := builderImage.Found(); err != nil {
-		logger.Error(err.Error())
-		return
+		return nil, err
 	} else if !found {
-		logger.Info("Not present")
-		return
+		return nil, nil
 	}
 
 	builder, err := inspector.Inspect(builderImage)
+	if err != nil {
+		return nil, err
+	}
+	return &builder, nil
+}
+
+func logBuilder(logger *logging.Logger, builder *pack.Builder, err error) {
 	if err != nil {
 		logger.Error(err.Error())
 		return
 	}
+	if builder == nil {
+		logger.Info("Not present")
+		return
+	}
 
+	logger.Info("Stack: %s", valueOrUnknown(builder.StackID))
+	logger.Info("Lifecycle Version: %s", valueOrUnknown(builder.LifecycleVersion))
+	logger.Info("User ID: %s", valueOrUnknown(builder.UserID))
+	logger.Info("Group ID: %s", valueOrUnknown(builder.GroupID))
 	logger.Info("Run Image: %s", builder.RunImage)
 	logger.Info("Run Image Mirrors:")
 	for _, r := range builder.LocalRunImageMirrors {
@@ -69,4 +133,108 @@ func inspectBuilderOutput(logger *logging.Logger, imageName string, remote bool,
 	for _, r := range builder.RunImageMirrors {
 		logger.Info("\t%s", r)
 	}
+	logger.Info("Buildpacks:")
+	for _, bp := range buildpackRefs(builder.Buildpacks) {
+		logger.Info("\t%s", bp)
+	}
+	logger.Info("Detection Order:")
+	for i, group := range builder.Groups {
+		logger.Info("\tGroup #%d: %s", i+1, groupRef(group))
+		if group.Description != "" {
+			logger.Info("\t\t%s", group.Description)
+		}
+	}
+	logger.Info("Build Environment:")
+	for _, env := range envRefs(builder.Env) {
+		logger.Info("\t%s", env)
+	}
+}
+
+// builderDiff describes the fields of the builder metadata that differ between the remote and local images
+func builderDiff(remote, local *pack.Builder) []string {
+	var diff []string
+	for _, field := range []struct {
+		name          string
+		remote, local string
+	}{
+		{"Stack", remote.StackID, local.StackID},
+		{"Lifecycle Version", valueOrUnknown(remote.LifecycleVersion), valueOrUnknown(local.LifecycleVersion)},
+		{"User ID", valueOrUnknown(remote.UserID), valueOrUnknown(local.UserID)},
+		{"Group ID", valueOrUnknown(remote.GroupID), valueOrUnknown(local.GroupID)},
+		{"Run Image", remote.RunImage, local.RunImage},
+		{"Run Image Mirrors", strings.Join(remote.RunImageMirrors, ", "), strings.Join(local.RunImageMirrors, ", ")},
+		{"Buildpacks", strings.Join(buildpackRefs(remote.Buildpacks), ", "), strings.Join(buildpackRefs(local.Buildpacks), ", ")},
+		{"Detection Order", strings.Join(groupRefs(remote.Groups), "; "), strings.Join(groupRefs(local.Groups), "; ")},
+		{"Build Environment", strings.Join(envRefs(remote.Env), ", "), strings.Join(envRefs(local.Env), ", ")},
+	} {
+		if field.remote == field.local {
+			continue
+		}
+		if field.remote == "" {
+			field.remote = "(none)"
+		}
+		if field.local == "" {
+			field.local = "(none)"
+		}
+		diff = append(diff, fmt.Sprintf("%s:\n\tremote: %s\n\tlocal:  %s", field.name, field.remote, field.local))
+	}
+	return diff
+}
+
+func buildpackRefs(buildpacks []pack.BuilderBuildpackMetadata) []string {
+	var refs []string
+	for _, bp := range buildpacks {
+		ref := bp.ID + "@" + bp.Version
+		if bp.Latest {
+			ref += " (latest)"
+		}
+		refs = append(refs, ref)
+	}
+	return refs
+}
+
+// groupRefs describes each group by its description, if any, and its buildpacks
+func groupRefs(groups []pack.BuilderGroupMetadata) []string {
+	var refs []string
+	for _, group := range groups {
+		ref := groupRef(group)
+		if group.Description != "" {
+			ref = group.Description + ": " + ref
+		}
+		refs = append(refs, ref)
+	}
+	return refs
+}
+
+func groupRef(group pack.BuilderGroupMetadata) string {
+	var bps []string
+	for _, bp := range group.Buildpacks {
+		ref := bp.ID + "@" + bp.Version
+		if bp.Optional {
+			ref += " (optional)"
+		}
+		bps = append(bps, ref)
+	}
+	return strings.Join(bps, ", ")
+}
+
+// envRefs lists the variables of env as NAME=value, sorted by name
+func envRefs(env map[string]string) []string {
+	var names []string
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var refs []string
+	for _, name := range names {
+		refs = append(refs, name+"="+env[name])
+	}
+	return refs
+}
+
+func valueOrUnknown(value string) string {
+	if value == "" {
+		return "(unknown)"
+	}
+	return value
 }
@@ -92,7 +92,7 @@ ERROR: failed to get image 'some/image': some local error
 				command = commands.InspectBuilder(logger, mockInspector, mockImageFactory)
 			})
 
-			it("displays the run image information for local and remote", func() {
+			it("displays the builder information for local and remote", func() {
 				mockRemoteImage := mocks.NewMockImage(mockController)
 				mockLocalImage := mocks.NewMockImage(mockController)
 				mockImageFactory.EXPECT().NewLocal("some/image", false).Return(mockLocalImage, nil)
@@ -102,15 +102,34 @@ ERROR: failed to get image 'some/image': some local error
 				mockLocalImage.EXPECT().Found().Return(true, nil)
 
 				mockInspector.EXPECT().Inspect(mockRemoteImage).Return(pack.Builder{
+					StackID:              "some.stack",
+					LifecycleVersion:     "0.1.0",
+					UserID:               "1000",
+					GroupID:              "1000",
 					RunImage:             "run/image",
 					LocalRunImageMirrors: []string{"first/image", "second/image"},
 					RunImageMirrors:      []string{"first/default", "second/default"},
+					Buildpacks: []pack.BuilderBuildpackMetadata{
+						{ID: "some.bp", Version: "1.2.3", Latest: true},
+						{ID: "other.bp", Version: "4.5.6"},
+					},
+					Groups: []pack.BuilderGroupMetadata{
+						{Buildpacks: []pack.BuilderGroupBuildpackMetadata{{ID: "some.bp", Version: "latest"}, {ID: "other.bp", Version: "4.5.6", Optional: true}}},
+						{Description: "Other apps", Buildpacks: []pack.BuilderGroupBuildpackMetadata{{ID: "other.bp", Version: "4.5.6"}}},
+					},
+					Env: map[string]string{"MIRROR_URL": "https://mirror.example.com/some%2Fpath", "BP_NO_TELEMETRY": "1"},
 				}, nil)
 
-				mockInspector.EXPECT().Inspect(mockRemoteImage).Return(pack.Builder{
+				mockInspector.EXPECT().Inspect(mockLocalImage).Return(pack.Builder{
+					StackID:              "some.stack",
+					UserID:               "1000",
+					GroupID:              "1000",
 					RunImage:             "run/image",
 					LocalRunImageMirrors: []string{"first/local", "second/local"},
 					RunImageMirrors:      []string{"first/local-default", "second/local-default"},
+					Buildpacks: []pack.BuilderBuildpackMetadata{
+						{ID: "some.bp", Version: "1.2.3", Latest: true},
+					},
 				}, nil)
 
 				command.SetArgs([]string{
@@ -121,24 +140,160 @@ ERROR: failed to get image 'some/image': some local error
 
 				h.AssertContains(t, outBuf.String(), `Remote
 ------
+Stack: some.stack
+Lifecycle Version: 0.1.0
+User ID: 1000
+Group ID: 1000
 Run Image: run/image
 Run Image Mirrors:
 	first/image (user-configured)
 	second/image (user-configured)
 	first/default
 	second/default
+Buildpacks:
+	some.bp@1.2.3 (latest)
+	other.bp@4.5.6
+Detection Order:
+	Group #1: some.bp@latest, other.bp@4.5.6 (optional)
+	Group #2: other.bp@4.5.6
+		Other apps
+Build Environment:
+	BP_NO_TELEMETRY=1
+	MIRROR_URL=https://mirror.example.com/some%2Fpath
 
 Local
 -----
+Stack: some.stack
+Lifecycle Version: (unknown)
+User ID: 1000
+Group ID: 1000
 Run Image: run/image
 Run Image Mirrors:
 	first/local (user-configured)
 	second/local (user-configured)
 	first/local-default
 	second/local-default
+Buildpacks:
+	some.bp@1.2.3 (latest)
+Detection Order:
+Build Environment:
+
+Differences between remote and local
+------------------------------------
+Lifecycle Version:
+	remote: 0.1.0
+	local:  (unknown)
+Run Image Mirrors:
+	remote: first/default, second/default
+	local:  first/local-default, second/local-default
+Buildpacks:
+	remote: some.bp@1.2.3 (latest), other.bp@4.5.6
+	local:  some.bp@1.2.3 (latest)
+Detection Order:
+	remote: some.bp@latest, other.bp@4.5.6 (optional); Other apps: other.bp@4.5.6
+	local:  (none)
+Build Environment:
+	remote: BP_NO_TELEMETRY=1, MIRROR_URL=https://mirror.example.com/some%2Fpath
+	local:  (none)
+
+`)
+			})
+
+			it("only displays the local builder with --local", func() {
+				mockLocalImage := mocks.NewMockImage(mockController)
+				mockImageFactory.EXPECT().NewLocal("some/image", false).Return(mockLocalImage, nil)
+				mockLocalImage.EXPECT().Found().Return(true, nil)
+				mockInspector.EXPECT().Inspect(mockLocalImage).Return(pack.Builder{
+					StackID:  "some.stack",
+					RunImage: "run/image",
+				}, nil)
+
+				command.SetArgs([]string{
+					"some/image",
+					"--local",
+				})
+
+				h.AssertNil(t, command.Execute())
+
+				h.AssertEq(t, outBuf.String(), `Local
+-----
+Stack: some.stack
+Lifecycle Version: (unknown)
+User ID: (unknown)
+Group ID: (unknown)
+Run Image: run/image
+Run Image Mirrors:
+Buildpacks:
+Detection Order:
+Build Environment:
+
+`)
+			})
 
+			it("writes json without color codes with --output json", func() {
+				mockLocalImage := mocks.NewMockImage(mockController)
+				mockImageFactory.EXPECT().NewLocal("some/image", false).Return(mockLocalImage, nil)
+				mockLocalImage.EXPECT().Found().Return(true, nil)
+				mockInspector.EXPECT().Inspect(mockLocalImage).Return(pack.Builder{
+					Image:    "some/image",
+					StackID:  "some.stack",
+					RunImage: "run/image",
+					Buildpacks: []pack.BuilderBuildpackMetadata{
+						{ID: "some.bp", Version: "1.2.3", Latest: true},
+					},
+				}, nil)
+
+				var stdout bytes.Buffer
+				command.SetOutput(&stdout)
+				command.SetArgs([]string{"some/image", "--local", "--output", "json"})
+
+				h.AssertNil(t, command.Execute())
+
+				h.AssertEq(t, outBuf.String(), "")
+				h.AssertEq(t, stdout.String(), `{
+  "local": {
+    "image": "some/image",
+    "stackId": "some.stack",
+    "lifecycleVersion": "",
+    "userId": "",
+    "groupId": "",
+    "runImage": "run/image",
+    "localRunImageMirrors": null,
+    "runImageMirrors": null,
+    "buildpacks": [
+      {
+        "id": "some.bp",
+        "version": "1.2.3",
+        "latest": true
+      }
+    ],
+    "groups": null,
+    "env": null
+  }
+}
 `)
 			})
+
+			it("writes yaml with --output yaml", func() {
+				mockRemoteImage := mocks.NewMockImage(mockController)
+				mockImageFactory.EXPECT().NewRemote("some/image").Return(mockRemoteImage, nil)
+				mockRemoteImage.EXPECT().Found().Return(false, nil)
+
+				var stdout bytes.Buffer
+				command.SetOutput(&stdout)
+				command.SetArgs([]string{"some/image", "--remote", "--output", "yaml"})
+
+				h.AssertNil(t, command.Execute())
+
+				h.AssertEq(t, stdout.String(), "{}\n")
+			})
+
+			it("rejects unknown output formats", func() {
+				command.SetOutput(&bytes.Buffer{})
+				command.SetArgs([]string{"some/image", "--output", "xml"})
+
+				h.AssertError(t, command.Execute(), "invalid output format 'xml': must be 'table', 'json' or 'yaml'")
+			})
 		})
 	})
 }
@@ -0,0 +1,121 @@
+package commands
+
+import (
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/buildpack/pack"
+	"github.com/buildpack/pack/config"
+	"github.com/buildpack/pack/logging"
+)
+
+func InspectImage(logger *logging.Logger, imageFactory pack.ImageFactory) *cobra.Command {
+	var local, remote bool
+	cmd := &cobra.Command{
+		Use:   "inspect-image <image-name>",
+		Short: "Show information about an app image built by pack",
+		Args:  cobra.ExactArgs(1),
+		RunE: logError(logger, func(cmd *cobra.Command, args []string) error {
+			imageName := args[0]
+			if !local && !remote {
+				local, remote = true, true
+			}
+			format, err := outputFormat(cmd)
+			if err != nil {
+				return err
+			}
+			cfg, err := config.NewDefault()
+			if err != nil {
+				return err
+			}
+			inspector := pack.ImageInspector{
+				Logger:       logger,
+				Config:       cfg,
+				ImageFactory: imageFactory,
+			}
+
+			var result struct {
+				Remote *pack.AppImage `json:"remote,omitempty"`
+				Local  *pack.AppImage `json:"local,omitempty"`
+			}
+			var remoteErr, localErr error
+			if remote {
+				result.Remote, remoteErr = inspector.Inspect(imageName, true)
+			}
+			if local {
+				result.Local, localErr = inspector.Inspect(imageName, false)
+			}
+
+			if format != tableOutput {
+				// structured output only holds the images that could be inspected
+				for _, err := range []error{remoteErr, localErr} {
+					if err != nil {
+						logger.Error(err.Error())
+					}
+				}
+			}
+			return writeOutput(cmd, result, func() error {
+				if remote {
+					logger.Info("Remote\n------")
+					logAppImage(logger, result.Remote, remoteErr)
+					logger.Info("")
+				}
+				if local {
+					logger.Info("Local\n-----")
+					logAppImage(logger, result.Local, localErr)
+					logger.Info("")
+				}
+				return nil
+			})
+		}),
+	}
+	cmd.Flags().BoolVar(&local, "local", false, "Only show the image in the local daemon")
+	cmd.Flags().BoolVar(&remote, "remote", false, "Only show the image in the registry")
+	AddOutputFlag(cmd)
+	AddHelpFlag(cmd, "inspect-image")
+	return cmd
+}
+
+func logAppImage(logger *logging.Logger, appImage *pack.AppImage, err error) {
+	if err != nil {
+		logger.Error(err.Error())
+		return
+	}
+	if appImage == nil {
+		logger.Info("Not present")
+		return
+	}
+
+	logger.Info("Stack: %s", valueOrUnknown(appImage.StackID))
+	logger.Info("Run Image:")
+	logger.Info("\tTop Layer: %s", appImage.RunImage.TopLayer)
+	logger.Info("\tSHA: %s", appImage.RunImage.SHA)
+	if appImage.RunImage.Mirror != "" {
+		status := "up to date"
+		if appImage.RunImage.Outdated {
+			status = "outdated, run pack rebase to update"
+		}
+		logger.Info("\tCurrent Mirror: %s (%s)", appImage.RunImage.Mirror, status)
+	} else {
+		logger.Info("\tCurrent Mirror: (unknown)")
+	}
+	logger.Info("Buildpacks:")
+	for _, bp := range appImage.Buildpacks {
+		logger.Info("\t%s@%s", bp.ID, bp.Version)
+		for _, layer := range bp.Layers {
+			var kinds []string
+			if layer.Build {
+				kinds = append(kinds, "build")
+			}
+			if layer.Launch {
+				kinds = append(kinds, "launch")
+			}
+			if layer.Cache {
+				kinds = append(kinds, "cache")
+			}
+			logger.Info("\t\t%s: %s (%s)", layer.Name, valueOrUnknown(layer.SHA), strings.Join(kinds, ", "))
+		}
+	}
+	logger.Info("App Layer: %s", appImage.AppLayer)
+}
@@ -0,0 +1,43 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/buildpack/pack"
+	"github.com/buildpack/pack/config"
+	"github.com/buildpack/pack/logging"
+	"github.com/buildpack/pack/style"
+)
+
+func NewBuildpack(logger *logging.Logger) *cobra.Command {
+	flags := pack.ScaffoldBuildpackFlags{}
+	cmd := &cobra.Command{
+		Use:   "new-buildpack <id>",
+		Args:  cobra.ExactArgs(1),
+		Short: "Generate a new buildpack",
+		RunE: logError(logger, func(cmd *cobra.Command, args []string) error {
+			flags.ID = args[0]
+			cfg, err := config.NewDefault()
+			if err != nil {
+				return err
+			}
+			factory := pack.ScaffoldFactory{
+				Logger: logger,
+				Config: cfg,
+			}
+			dir, err := factory.Create(flags)
+			if err != nil {
+				return err
+			}
+			logger.Info("Successfully created buildpack %s in %s", style.Symbol(flags.ID), style.Symbol(dir))
+			logger.Tip("Run %s to package it", style.Symbol(fmt.Sprintf("pack package-buildpack %s", dir)))
+			return nil
+		}),
+	}
+	cmd.Flags().StringVar(&flags.Path, "path", "", "Directory to generate the buildpack in (default <id> with '/' replaced by '_')")
+	cmd.Flags().StringVarP(&flags.StackID, "stack", "s", "", "ID of the stack the buildpack supports, from the stacks in config.toml (default the default stack)")
+	AddHelpFlag(cmd, "new-buildpack")
+	return cmd
+}
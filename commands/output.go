@@ -0,0 +1,78 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+
+	"github.com/buildpack/pack/style"
+)
+
+const (
+	tableOutput = "table"
+	jsonOutput  = "json"
+	yamlOutput  = "yaml"
+)
+
+// AddOutputFlag adds the --output flag selecting how a read command renders its results to cmd. It is only added to
+// commands that show information, so other commands reject it as an unknown flag.
+func AddOutputFlag(cmd *cobra.Command) {
+	cmd.Flags().String("output", tableOutput, fmt.Sprintf("Output format: %s, %s or %s", tableOutput, jsonOutput, yamlOutput))
+}
+
+// writeOutput renders the result of a read command in the format selected with --output. Tables are printed by
+// table through the logger. JSON and YAML are written to the output of cmd as-is, so they contain neither colour
+// codes nor timestamps.
+func writeOutput(cmd *cobra.Command, result interface{}, table func() error) error {
+	format, err := outputFormat(cmd)
+	if err != nil {
+		return err
+	}
+	switch format {
+	case tableOutput:
+		return table()
+	case jsonOutput:
+		b, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(cmd.OutOrStdout(), string(b))
+		return err
+	default:
+		b, err := toYAML(result)
+		if err != nil {
+			return err
+		}
+		_, err = cmd.OutOrStdout().Write(b)
+		return err
+	}
+}
+
+// outputFormat returns the value of --output, which defaults to a table for commands without the flag
+func outputFormat(cmd *cobra.Command) (string, error) {
+	flag := cmd.Flag("output")
+	if flag == nil {
+		return tableOutput, nil
+	}
+	switch flag.Value.String() {
+	case tableOutput, jsonOutput, yamlOutput:
+		return flag.Value.String(), nil
+	default:
+		return "", fmt.Errorf("invalid output format %s: must be %s, %s or %s", style.Symbol(flag.Value.String()), style.Symbol(tableOutput), style.Symbol(jsonOutput), style.Symbol(yamlOutput))
+	}
+}
+
+// toYAML marshals result with the keys of its JSON encoding
+func toYAML(result interface{}) ([]byte, error) {
+	b, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	var doc interface{}
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(doc)
+}
@@ -0,0 +1,182 @@
+package commands_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/fatih/color"
+	"github.com/golang/mock/gomock"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+	"github.com/spf13/cobra"
+
+	"github.com/buildpack/pack"
+	"github.com/buildpack/pack/commands"
+	"github.com/buildpack/pack/commands/mocks"
+	"github.com/buildpack/pack/logging"
+	h "github.com/buildpack/pack/testhelpers"
+)
+
+func TestOutput(t *testing.T) {
+	color.NoColor = true
+	spec.Run(t, "output", testOutput, spec.Report(report.Terminal{}))
+}
+
+func testOutput(t *testing.T, when spec.G, it spec.S) {
+	var (
+		packHome, oldPackHome string
+		mockController        *gomock.Controller
+		mockImageFactory      *mocks.MockImageFactory
+		logger                *logging.Logger
+		outBuf                bytes.Buffer
+		stdout                bytes.Buffer
+	)
+
+	run := func(command *cobra.Command, args ...string) error {
+		t.Helper()
+		command.SetOutput(&stdout)
+		command.SetArgs(args)
+		return command.Execute()
+	}
+
+	it.Before(func() {
+		var err error
+		packHome, err = ioutil.TempDir("", "pack.output.test")
+		h.AssertNil(t, err)
+		oldPackHome = os.Getenv("PACK_HOME")
+		h.AssertNil(t, os.Setenv("PACK_HOME", packHome))
+
+		mockController = gomock.NewController(t)
+		mockImageFactory = mocks.NewMockImageFactory(mockController)
+		outBuf.Reset()
+		stdout.Reset()
+		logger = logging.NewLogger(&outBuf, &outBuf, false, false)
+	})
+
+	it.After(func() {
+		mockController.Finish()
+		os.Setenv("PACK_HOME", oldPackHome)
+		os.RemoveAll(packHome)
+	})
+
+	when("#InspectImage", func() {
+		it.Before(func() {
+			mockImage := mocks.NewMockImage(mockController)
+			mockImageFactory.EXPECT().NewLocal("some/app", false).Return(mockImage, nil)
+			mockImage.EXPECT().Found().Return(true, nil)
+			mockImage.EXPECT().Label(pack.AppMetadataLabel).Return(`{"app": {"sha": "app-sha"}, "runImage": {"topLayer": "top-layer", "sha": "run-sha"}, "buildpacks": [{"key": "some.bp", "version": "1.2.3", "layers": {"some-layer": {"sha": "layer-sha", "launch": true}}}]}`, nil)
+			mockImage.EXPECT().Label(pack.StackLabel).Return("some.stack", nil)
+		})
+
+		it("writes json with --output json", func() {
+			h.AssertNil(t, run(commands.InspectImage(logger, mockImageFactory), "some/app", "--local", "--output", "json"))
+
+			h.AssertEq(t, outBuf.String(), "")
+			h.AssertEq(t, stdout.String(), `{
+  "local": {
+    "image": "some/app",
+    "stackId": "some.stack",
+    "runImage": {
+      "topLayer": "top-layer",
+      "sha": "run-sha",
+      "outdated": false
+    },
+    "buildpacks": [
+      {
+        "id": "some.bp",
+        "version": "1.2.3",
+        "layers": [
+          {
+            "name": "some-layer",
+            "sha": "layer-sha",
+            "build": false,
+            "launch": true,
+            "cache": false
+          }
+        ]
+      }
+    ],
+    "appLayer": "app-sha"
+  }
+}
+`)
+		})
+
+		it("writes yaml with --output yaml", func() {
+			h.AssertNil(t, run(commands.InspectImage(logger, mockImageFactory), "some/app", "--local", "--output", "yaml"))
+
+			h.AssertEq(t, stdout.String(), `local:
+  appLayer: app-sha
+  buildpacks:
+  - id: some.bp
+    layers:
+    - build: false
+      cache: false
+      launch: true
+      name: some-layer
+      sha: layer-sha
+    version: 1.2.3
+  image: some/app
+  runImage:
+    outdated: false
+    sha: run-sha
+    topLayer: top-layer
+  stackId: some.stack
+`)
+		})
+	})
+
+	when("#ShowStacks", func() {
+		it("writes json with --output json", func() {
+			h.AssertNil(t, run(commands.ShowStacks(logger), "--output", "json"))
+
+			h.AssertEq(t, stdout.String(), `{
+  "stacks": [
+    {
+      "id": "io.buildpacks.stacks.bionic",
+      "buildImage": "packs/build:v3alpha2",
+      "runImages": [
+        "packs/run:v3alpha2"
+      ],
+      "default": true
+    }
+  ]
+}
+`)
+		})
+
+		it("writes yaml with --output yaml", func() {
+			h.AssertNil(t, run(commands.ShowStacks(logger), "--output", "yaml"))
+
+			h.AssertEq(t, stdout.String(), `stacks:
+- buildImage: packs/build:v3alpha2
+  default: true
+  id: io.buildpacks.stacks.bionic
+  runImages:
+  - packs/run:v3alpha2
+`)
+		})
+	})
+
+	when("#Version", func() {
+		it("writes json with --output json", func() {
+			h.AssertNil(t, run(commands.Version(logger, "1.2.3 (git sha: abc)\n"), "--output", "json"))
+
+			h.AssertEq(t, stdout.String(), "{\n  \"version\": \"1.2.3 (git sha: abc)\"\n}\n")
+		})
+
+		it("writes yaml with --output yaml", func() {
+			h.AssertNil(t, run(commands.Version(logger, "1.2.3 (git sha: abc)\n"), "--output", "yaml"))
+
+			h.AssertEq(t, stdout.String(), "version: '1.2.3 (git sha: abc)'\n")
+		})
+	})
+
+	it("is rejected by commands that do not show information", func() {
+		err := run(commands.Rebase(logger, mockImageFactory), "some/app", "--output", "json")
+
+		h.AssertError(t, err, "unknown flag: --output")
+	})
+}
@@ -0,0 +1,67 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/buildpack/pack"
+	"github.com/buildpack/pack/fs"
+	"github.com/buildpack/pack/logging"
+	"github.com/buildpack/pack/style"
+)
+
+func PackageBuildpack(logger *logging.Logger) *cobra.Command {
+	var archive, imageName string
+	var publish bool
+	cmd := &cobra.Command{
+		Use:   "package-buildpack <buildpack-dir>",
+		Args:  cobra.ExactArgs(1),
+		Short: "Package a buildpack as an archive or a buildpack image",
+		RunE: logError(logger, func(cmd *cobra.Command, args []string) error {
+			if archive != "" && imageName != "" {
+				return fmt.Errorf("%s and %s cannot be used together", style.Symbol("--archive"), style.Symbol("--image"))
+			}
+			if publish && imageName == "" {
+				return fmt.Errorf("%s requires %s", style.Symbol("--publish"), style.Symbol("--image"))
+			}
+
+			factory := pack.BuildpackageFactory{
+				Logger: logger,
+				FS:     &fs.FS{Reproducible: true},
+			}
+			bp, err := factory.ValidateBuildpack(args[0])
+			if err != nil {
+				return err
+			}
+
+			if imageName != "" {
+				store, err := pack.NewBuildpackageStore(imageName, publish)
+				if err != nil {
+					return err
+				}
+				if err := factory.CreateImage(bp, store); err != nil {
+					return err
+				}
+				logger.Info("Successfully created buildpack image %s", style.Symbol(imageName))
+				logger.Tip("Use it in builder.toml with %s", style.Symbol(fmt.Sprintf(`uri = "docker://%s"`, imageName)))
+				return nil
+			}
+
+			if archive == "" {
+				archive = fmt.Sprintf("%s-%s.tgz", strings.Replace(bp.ID, "/", "_", -1), bp.Version)
+			}
+			if err := factory.CreateArchive(bp, archive); err != nil {
+				return err
+			}
+			logger.Info("Successfully packaged buildpack %s into %s", style.Symbol(bp.ID+"@"+bp.Version), style.Symbol(archive))
+			return nil
+		}),
+	}
+	cmd.Flags().StringVarP(&archive, "archive", "a", "", "Path of the archive to write (default <id>-<version>.tgz)")
+	cmd.Flags().StringVar(&imageName, "image", "", "Create a buildpack image with this name instead of an archive")
+	cmd.Flags().BoolVar(&publish, "publish", false, "Publish the buildpack image to a registry")
+	AddHelpFlag(cmd, "package-buildpack")
+	return cmd
+}
@@ -23,26 +23,46 @@ func ShowStacks(logger *logging.Logger) *cobra.Command {
 			if err != nil {
 				return err
 			}
-			var buf bytes.Buffer
-			w := tabwriter.NewWriter(&buf, 0, 0, 4, ' ', 0)
-			// Note: Nop style is needed to keep color control characters from interfering with table formatting
-			// See https://stackoverflow.com/questions/35398497/how-do-i-get-colors-to-work-with-golang-tabwriter
-			fmt.Fprintf(w, "%s\t%s\t%s\n", style.Noop("Stack ID"), style.Noop("Build Image"), style.Noop("Run Image(s)"))
-			fmt.Fprintf(w, "%s\t%s\t%s\n", style.Noop("--------"), style.Noop("-----------"), style.Noop("------------"))
-			for _, stack := range cfg.Stacks {
-				displayID := style.Key(stack.ID)
-				if stack.ID == cfg.DefaultStackID {
-					displayID = fmt.Sprintf("%s (default)", displayID)
-				}
-				fmt.Fprintf(w, "%s\t%s\t%s\n", displayID, style.Noop(stack.BuildImage), style.Noop(strings.Join(stack.RunImages, ", ")))
+			type stackOutput struct {
+				ID         string   `json:"id"`
+				BuildImage string   `json:"buildImage"`
+				RunImages  []string `json:"runImages"`
+				Default    bool     `json:"default"`
 			}
-			if err := w.Flush(); err != nil {
-				return err
+			var result struct {
+				Stacks []stackOutput `json:"stacks"`
 			}
-			logger.Info(buf.String())
-			return nil
+			for _, stack := range cfg.Stacks {
+				result.Stacks = append(result.Stacks, stackOutput{
+					ID:         stack.ID,
+					BuildImage: stack.BuildImage,
+					RunImages:  stack.RunImages,
+					Default:    stack.ID == cfg.DefaultStackID,
+				})
+			}
+			return writeOutput(cmd, result, func() error {
+				var buf bytes.Buffer
+				w := tabwriter.NewWriter(&buf, 0, 0, 4, ' ', 0)
+				// Note: Nop style is needed to keep color control characters from interfering with table formatting
+				// See https://stackoverflow.com/questions/35398497/how-do-i-get-colors-to-work-with-golang-tabwriter
+				fmt.Fprintf(w, "%s\t%s\t%s\n", style.Noop("Stack ID"), style.Noop("Build Image"), style.Noop("Run Image(s)"))
+				fmt.Fprintf(w, "%s\t%s\t%s\n", style.Noop("--------"), style.Noop("-----------"), style.Noop("------------"))
+				for _, stack := range result.Stacks {
+					displayID := style.Key(stack.ID)
+					if stack.Default {
+						displayID = fmt.Sprintf("%s (default)", displayID)
+					}
+					fmt.Fprintf(w, "%s\t%s\t%s\n", displayID, style.Noop("%s", stack.BuildImage), style.Noop("%s", strings.Join(stack.RunImages, ", ")))
+				}
+				if err := w.Flush(); err != nil {
+					return err
+				}
+				logger.Info("%s", buf.String())
+				return nil
+			})
 		}),
 	}
+	AddOutputFlag(cmd)
 	AddHelpFlag(cmd, "stacks")
 	return cmd
 }
@@ -0,0 +1,54 @@
+package commands
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/buildpack/pack"
+	"github.com/buildpack/pack/cache"
+	"github.com/buildpack/pack/logging"
+	"github.com/buildpack/pack/style"
+)
+
+func TestBuildpack(logger *logging.Logger, dockerClient pack.Docker, imageFactory pack.ImageFactory) *cobra.Command {
+	var flags pack.TestBuildpackFlags
+	ctx := createCancellableContext()
+
+	cmd := &cobra.Command{
+		Use:   "test-buildpack <buildpack-dir> --app <app-dir>",
+		Args:  cobra.ExactArgs(1),
+		Short: "Run a buildpack against an app and check the outcome",
+		RunE: logError(logger, func(cmd *cobra.Command, args []string) error {
+			flags.BuildpackDir = args[0]
+
+			// every test starts from an empty workspace, which is removed afterwards
+			cacheObj, err := cache.New(fmt.Sprintf("pack.local/test-buildpack-%d", time.Now().UnixNano()), dockerClient)
+			if err != nil {
+				return err
+			}
+			bf, err := pack.DefaultBuildFactory(logger, cacheObj, dockerClient, imageFactory)
+			if err != nil {
+				return err
+			}
+			t, err := bf.TestBuildpackConfigFromFlags(&flags)
+			if err != nil {
+				return err
+			}
+			if err := t.Run(ctx); err != nil {
+				return err
+			}
+			logger.Info("Buildpack %s met all expectations for app %s", style.Symbol(t.Buildpack.ID), style.Symbol(flags.AppDir))
+			return nil
+		}),
+	}
+	cmd.Flags().StringVarP(&flags.AppDir, "app", "a", "", "Path to the app to run the buildpack against (required)")
+	cmd.MarkFlagRequired("app")
+	cmd.Flags().StringVar(&flags.SpecPath, "spec", "", "Path to a TOML file with the expected outcome (default: detect passes)")
+	cmd.Flags().StringVar(&flags.Builder, "builder", "", "Builder whose lifecycle and stack are used (default builder is used if not specified)")
+	cmd.Flags().BoolVar(&flags.Build, "build", false, "Also run the build, implied when the spec expects layers")
+	cmd.Flags().BoolVar(&flags.NoPull, "no-pull", false, "Skip pulling builder image before use")
+	AddHelpFlag(cmd, "test-buildpack")
+	return cmd
+}
@@ -0,0 +1,37 @@
+package commands
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/buildpack/pack"
+	"github.com/buildpack/pack/config"
+	"github.com/buildpack/pack/fs"
+	"github.com/buildpack/pack/logging"
+	"github.com/buildpack/pack/style"
+)
+
+func ValidateBuilderConfig(logger *logging.Logger) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "validate-builder-config <builder-config-path>",
+		Args:  cobra.ExactArgs(1),
+		Short: "Check a builder TOML file and its buildpacks without creating a builder",
+		RunE: logError(logger, func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.NewDefault()
+			if err != nil {
+				return err
+			}
+			builderFactory := pack.BuilderFactory{
+				FS:     &fs.FS{},
+				Logger: logger,
+				Config: cfg,
+			}
+			if _, err := builderFactory.ValidateBuilderConfig(args[0]); err != nil {
+				return err
+			}
+			logger.Info("Builder config %s is valid", style.Symbol(args[0]))
+			return nil
+		}),
+	}
+	AddHelpFlag(cmd, "validate-builder-config")
+	return cmd
+}
@@ -14,10 +14,16 @@ func Version(logger *logging.Logger, version string) *cobra.Command {
 		Args:  cobra.NoArgs,
 		Short: "Show current 'pack' version",
 		RunE: logError(logger, func(cmd *cobra.Command, args []string) error {
-			logger.Info(strings.TrimSpace(version))
-			return nil
+			result := struct {
+				Version string `json:"version"`
+			}{strings.TrimSpace(version)}
+			return writeOutput(cmd, result, func() error {
+				logger.Info("%s", result.Version)
+				return nil
+			})
 		}),
 	}
+	AddOutputFlag(cmd)
 	AddHelpFlag(cmd, "version")
 	return cmd
 }
@@ -17,6 +17,8 @@ type Config struct {
 	RunImages      []RunImage `toml:"run-images"`
 	DefaultStackID string     `toml:"default-stack-id"`
 	DefaultBuilder string     `toml:"default-builder"`
+	Download       *Download  `toml:"download,omitempty"`
+	RegistryIndex  string     `toml:"registry-index,omitempty"`
 	configPath     string
 }
 
@@ -27,6 +29,26 @@ type Stack struct {
 	RunImages   []string `toml:"run-images"`
 }
 
+// Download configures the HTTP client used to download buildpacks. Each setting can be overridden by an environment
+// variable, see the download package.
+type Download struct {
+	Proxy        string         `toml:"proxy,omitempty"`
+	CACerts      []string       `toml:"ca-certs,omitempty"`
+	Netrc        string         `toml:"netrc,omitempty"`
+	Timeout      string         `toml:"timeout,omitempty"`
+	Retries      *int           `toml:"retries,omitempty"`
+	RetryBackoff string         `toml:"retry-backoff,omitempty"`
+	Hosts        []DownloadHost `toml:"hosts,omitempty"`
+}
+
+// DownloadHost holds the credentials sent to a single host, either a bearer token or a username and password
+type DownloadHost struct {
+	Host     string `toml:"host"`
+	Token    string `toml:"token,omitempty"`
+	Username string `toml:"username,omitempty"`
+	Password string `toml:"password,omitempty"`
+}
+
 type RunImage struct {
 	Image   string   `toml:"image"`
 	Mirrors []string `toml:"mirrors"`
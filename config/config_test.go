@@ -236,6 +236,36 @@ default-builder = "packs/samples"
 				h.AssertEq(t, len(subject.Stacks[0].BuildImages), 0)
 			})
 		})
+
+		when("config.toml has download settings", func() {
+			it.Before(func() {
+				h.AssertNil(t, ioutil.WriteFile(filepath.Join(tmpDir, "config.toml"), []byte(`
+[download]
+  ca-certs = ["/etc/ssl/corp.pem"]
+  timeout = "5m"
+  retries = 0
+
+  [[download.hosts]]
+    host = "artifacts.example.com"
+    token = "some-token"
+`), 0644))
+			})
+
+			it("preserves them", func() {
+				subject, err := config.New(tmpDir)
+				h.AssertNil(t, err)
+
+				h.AssertEq(t, subject.Download.CACerts, []string{"/etc/ssl/corp.pem"})
+				h.AssertEq(t, subject.Download.Timeout, "5m")
+				h.AssertEq(t, *subject.Download.Retries, 0)
+				h.AssertEq(t, subject.Download.Hosts, []config.DownloadHost{{Host: "artifacts.example.com", Token: "some-token"}})
+
+				b, err := ioutil.ReadFile(filepath.Join(tmpDir, "config.toml"))
+				h.AssertNil(t, err)
+				h.AssertContains(t, string(b), `retries = 0`)
+				h.AssertContains(t, string(b), `token = "some-token"`)
+			})
+		})
 	})
 
 	when("Config#GetStack", func() {
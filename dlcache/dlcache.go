@@ -0,0 +1,203 @@
+// Package dlcache manages the buildpacks downloaded by create-builder. Each entry is the extracted archive of a
+// buildpack URI, stored in <PACK_HOME>/dl-cache/<sha256 of the URI>, next to a <sha256 of the URI>.json file holding
+// its metadata. The metadata is written last, so directories without it are incomplete and never used.
+package dlcache
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/buildpack/pack/style"
+)
+
+type Cache struct {
+	Dir string
+}
+
+// Entry describes a downloaded buildpack. Entries created by older versions of pack have no metadata beyond their
+// key and the time they were downloaded.
+type Entry struct {
+	Key              string    `json:"-"`
+	URI              string    `json:"uri"`
+	ETag             string    `json:"etag,omitempty"`
+	SHA256           string    `json:"sha256"`
+	Size             int64     `json:"size"`
+	DownloadedAt     time.Time `json:"downloadedAt"`
+	BuildpackID      string    `json:"buildpackId,omitempty"`
+	BuildpackVersion string    `json:"buildpackVersion,omitempty"`
+}
+
+// New returns the download cache stored in packHome
+func New(packHome string) *Cache {
+	return &Cache{Dir: filepath.Join(packHome, "dl-cache")}
+}
+
+// Key returns the name of the cache entry for uri
+func Key(uri string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(uri)))
+}
+
+// Path returns the directory holding the extracted buildpack downloaded from uri
+func (c *Cache) Path(uri string) string {
+	return filepath.Join(c.Dir, Key(uri))
+}
+
+// Lookup returns the metadata of the complete cache entry for uri, if any
+func (c *Cache) Lookup(uri string) (Entry, bool, error) {
+	entry, err := c.readEntry(Key(uri))
+	if os.IsNotExist(err) {
+		return Entry{}, false, nil
+	}
+	if err != nil {
+		return Entry{}, false, err
+	}
+	if _, err := os.Stat(c.Path(uri)); err != nil {
+		return Entry{}, false, nil
+	}
+	return entry, true, nil
+}
+
+// TempDir creates a directory in the cache to extract a download into before it is stored
+func (c *Cache) TempDir(uri string) (string, error) {
+	if err := os.MkdirAll(c.Dir, 0755); err != nil {
+		return "", err
+	}
+	dir, err := ioutil.TempDir(c.Dir, Key(uri)+".tmp-")
+	if err != nil {
+		return "", errors.Wrap(err, "creating temporary download directory")
+	}
+	// the directory becomes the buildpack directory in the builder, which must be readable by the build user
+	return dir, os.Chmod(dir, 0755)
+}
+
+// Store replaces the cache entry of entry.URI with dir, which must be on the same filesystem as the cache (see
+// TempDir), and records its metadata
+func (c *Cache) Store(dir string, entry Entry) error {
+	if err := c.Remove(entry.URI); err != nil {
+		return err
+	}
+	if err := os.Rename(dir, c.Path(entry.URI)); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+	metadataFile := c.metadataPath(Key(entry.URI))
+	if err := ioutil.WriteFile(metadataFile+".tmp", b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(metadataFile+".tmp", metadataFile)
+}
+
+// Remove deletes the cache entry of uri, if any
+func (c *Cache) Remove(uri string) error {
+	return c.removeKey(Key(uri))
+}
+
+// List returns the entries of the cache sorted by download time, oldest first
+func (c *Cache) List() ([]Entry, error) {
+	files, err := ioutil.ReadDir(c.Dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	for _, fi := range files {
+		if !fi.IsDir() || strings.Contains(fi.Name(), ".tmp-") {
+			continue
+		}
+		entry, err := c.readEntry(fi.Name())
+		if os.IsNotExist(err) {
+			entry = Entry{Key: fi.Name(), DownloadedAt: fi.ModTime()}
+		} else if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].DownloadedAt.Before(entries[j].DownloadedAt)
+	})
+	return entries, nil
+}
+
+// Prune removes the entries downloaded before the given time, as well as leftovers of interrupted downloads last
+// written to before that time, and returns the removed entries. Newer leftovers may belong to a download in
+// progress, so they are kept.
+func (c *Cache) Prune(before time.Time) ([]Entry, error) {
+	entries, err := c.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []Entry
+	for _, entry := range entries {
+		if !entry.DownloadedAt.Before(before) {
+			continue
+		}
+		if err := c.removeKey(entry.Key); err != nil {
+			return nil, err
+		}
+		removed = append(removed, entry)
+	}
+
+	leftovers, err := filepath.Glob(filepath.Join(c.Dir, "*.tmp-*"))
+	if err != nil {
+		return nil, err
+	}
+	for _, leftover := range leftovers {
+		fi, err := os.Stat(leftover)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if !fi.ModTime().Before(before) {
+			continue
+		}
+		if err := os.RemoveAll(leftover); err != nil {
+			return nil, err
+		}
+	}
+	return removed, nil
+}
+
+func (c *Cache) readEntry(key string) (Entry, error) {
+	b, err := ioutil.ReadFile(c.metadataPath(key))
+	if err != nil {
+		return Entry{}, err
+	}
+	var entry Entry
+	if err := json.Unmarshal(b, &entry); err != nil {
+		return Entry{}, errors.Wrapf(err, "reading metadata of download cache entry %s", style.Symbol(key))
+	}
+	entry.Key = key
+	return entry, nil
+}
+
+func (c *Cache) removeKey(key string) error {
+	// metadata goes first, so that an interrupted removal leaves an incomplete entry behind rather than a broken one
+	base := filepath.Join(c.Dir, key)
+	for _, path := range []string{c.metadataPath(key), base + ".etag", base + ".sha256", base} {
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Cache) metadataPath(key string) string {
+	return filepath.Join(c.Dir, key+".json")
+}
@@ -0,0 +1,165 @@
+package dlcache_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpack/pack/dlcache"
+	h "github.com/buildpack/pack/testhelpers"
+)
+
+func TestDlCache(t *testing.T) {
+	color.NoColor = true
+	spec.Run(t, "dlcache", testDlCache, spec.Parallel(), spec.Report(report.Terminal{}))
+}
+
+func testDlCache(t *testing.T, when spec.G, it spec.S) {
+	var (
+		packHome string
+		cache    *dlcache.Cache
+	)
+
+	store := func(uri string, downloadedAt time.Time) {
+		t.Helper()
+		dir, err := cache.TempDir(uri)
+		h.AssertNil(t, err)
+		h.AssertNil(t, ioutil.WriteFile(filepath.Join(dir, "buildpack.toml"), []byte(uri), 0644))
+		h.AssertNil(t, cache.Store(dir, dlcache.Entry{
+			URI:              uri,
+			ETag:             `"some-etag"`,
+			SHA256:           "some-sha",
+			Size:             123,
+			DownloadedAt:     downloadedAt,
+			BuildpackID:      "some.bp",
+			BuildpackVersion: "1.2.3",
+		}))
+	}
+
+	it.Before(func() {
+		var err error
+		packHome, err = ioutil.TempDir("", "pack.dlcache.test")
+		h.AssertNil(t, err)
+		cache = dlcache.New(packHome)
+	})
+
+	it.After(func() {
+		os.RemoveAll(packHome)
+	})
+
+	when("#Store", func() {
+		it("stores the extracted buildpack and its metadata", func() {
+			downloadedAt := time.Date(2019, 2, 1, 10, 0, 0, 0, time.UTC)
+			store("https://example.com/bp.tgz", downloadedAt)
+
+			entry, found, err := cache.Lookup("https://example.com/bp.tgz")
+			h.AssertNil(t, err)
+			h.AssertEq(t, found, true)
+			h.AssertEq(t, entry, dlcache.Entry{
+				Key:              dlcache.Key("https://example.com/bp.tgz"),
+				URI:              "https://example.com/bp.tgz",
+				ETag:             `"some-etag"`,
+				SHA256:           "some-sha",
+				Size:             123,
+				DownloadedAt:     downloadedAt,
+				BuildpackID:      "some.bp",
+				BuildpackVersion: "1.2.3",
+			})
+			h.AssertDirContainsFileWithContents(t, cache.Path("https://example.com/bp.tgz"), "buildpack.toml", "https://example.com/bp.tgz")
+		})
+
+		it("replaces previous downloads of the uri", func() {
+			store("https://example.com/bp.tgz", time.Now())
+			h.AssertNil(t, ioutil.WriteFile(filepath.Join(cache.Path("https://example.com/bp.tgz"), "stale"), []byte("stale"), 0644))
+			store("https://example.com/bp.tgz", time.Now())
+
+			_, err := os.Stat(filepath.Join(cache.Path("https://example.com/bp.tgz"), "stale"))
+			h.AssertEq(t, os.IsNotExist(err), true)
+		})
+	})
+
+	when("#Lookup", func() {
+		it("ignores entries without metadata", func() {
+			h.AssertNil(t, os.MkdirAll(cache.Path("https://example.com/bp.tgz"), 0755))
+
+			_, found, err := cache.Lookup("https://example.com/bp.tgz")
+			h.AssertNil(t, err)
+			h.AssertEq(t, found, false)
+		})
+	})
+
+	when("#List", func() {
+		it("lists entries by download time, including ones downloaded by older versions of pack", func() {
+			store("https://example.com/new.tgz", time.Now())
+			store("https://example.com/old.tgz", time.Now().Add(-48*time.Hour))
+			legacyDir := filepath.Join(cache.Dir, "legacy")
+			h.AssertNil(t, os.MkdirAll(legacyDir, 0755))
+			h.AssertNil(t, os.Chtimes(legacyDir, time.Now().Add(-72*time.Hour), time.Now().Add(-72*time.Hour)))
+
+			entries, err := cache.List()
+			h.AssertNil(t, err)
+			h.AssertEq(t, len(entries), 3)
+			h.AssertEq(t, entries[0].Key, "legacy")
+			h.AssertEq(t, entries[0].URI, "")
+			h.AssertEq(t, entries[1].URI, "https://example.com/old.tgz")
+			h.AssertEq(t, entries[2].URI, "https://example.com/new.tgz")
+		})
+
+		it("returns nothing when nothing was downloaded", func() {
+			entries, err := cache.List()
+			h.AssertNil(t, err)
+			h.AssertEq(t, len(entries), 0)
+		})
+	})
+
+	when("#Remove", func() {
+		it("removes the entry and its metadata", func() {
+			store("https://example.com/bp.tgz", time.Now())
+			h.AssertNil(t, cache.Remove("https://example.com/bp.tgz"))
+
+			files, err := ioutil.ReadDir(cache.Dir)
+			h.AssertNil(t, err)
+			h.AssertEq(t, len(files), 0)
+		})
+	})
+
+	when("#Prune", func() {
+		it("removes entries downloaded before the given time and leftovers of interrupted downloads", func() {
+			store("https://example.com/new.tgz", time.Now())
+			store("https://example.com/old.tgz", time.Now().Add(-48*time.Hour))
+			interrupted, err := cache.TempDir("https://example.com/interrupted.tgz")
+			h.AssertNil(t, err)
+			h.AssertNil(t, os.Chtimes(interrupted, time.Now().Add(-48*time.Hour), time.Now().Add(-48*time.Hour)))
+
+			removed, err := cache.Prune(time.Now().Add(-24 * time.Hour))
+			h.AssertNil(t, err)
+			h.AssertEq(t, len(removed), 1)
+			h.AssertEq(t, removed[0].URI, "https://example.com/old.tgz")
+
+			entries, err := cache.List()
+			h.AssertNil(t, err)
+			h.AssertEq(t, len(entries), 1)
+			h.AssertEq(t, entries[0].URI, "https://example.com/new.tgz")
+			leftovers, err := filepath.Glob(filepath.Join(cache.Dir, "*.tmp-*"))
+			h.AssertNil(t, err)
+			h.AssertEq(t, len(leftovers), 0)
+		})
+
+		it("keeps leftovers of downloads that may still be in progress", func() {
+			inProgress, err := cache.TempDir("https://example.com/in-progress.tgz")
+			h.AssertNil(t, err)
+
+			_, err = cache.Prune(time.Now().Add(-24 * time.Hour))
+			h.AssertNil(t, err)
+
+			_, err = os.Stat(inProgress)
+			h.AssertNil(t, err)
+		})
+	})
+}
@@ -16,6 +16,15 @@ type Client struct {
 	*dockercli.Client
 }
 
+// ExitError is returned by RunContainer when the container exits with a non-zero status code
+type ExitError struct {
+	StatusCode int64
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("failed with status code: %d", e.StatusCode)
+}
+
 func New() (*Client, error) {
 	cli, err := dockercli.NewClientWithOpts(dockercli.FromEnv, dockercli.WithVersion("1.38"))
 	if err != nil {
@@ -48,7 +57,7 @@ func (d *Client) RunContainer(ctx context.Context, id string, stdout io.Writer,
 	select {
 	case body := <-bodyChan:
 		if body.StatusCode != 0 {
-			return fmt.Errorf("failed with status code: %d", body.StatusCode)
+			return &ExitError{StatusCode: body.StatusCode}
 		}
 	case err := <-errChan:
 		return err
@@ -0,0 +1,317 @@
+// Package download provides the HTTP client used to download buildpacks. It is configured by the [download] table
+// of config.toml, each setting of which can be overridden by an environment variable:
+//
+//	PACK_DOWNLOAD_PROXY           proxy URL (defaults to HTTPS_PROXY, HTTP_PROXY and NO_PROXY)
+//	PACK_DOWNLOAD_CA_CERTS        PEM files with extra CA certificates, separated by the OS path list separator
+//	PACK_DOWNLOAD_NETRC           netrc file providing credentials per host (defaults to ~/.netrc when present)
+//	PACK_DOWNLOAD_TIMEOUT         time limit of a download, e.g. 5m (no limit by default)
+//	PACK_DOWNLOAD_RETRIES         number of retries of failed or interrupted downloads (defaults to 3)
+//	PACK_DOWNLOAD_RETRY_BACKOFF   delay before the first retry, doubled for each further retry (defaults to 1s)
+//	PACK_DOWNLOAD_TOKEN_<HOST>    bearer token for a host, e.g. PACK_DOWNLOAD_TOKEN_ARTIFACTS_EXAMPLE_COM
+package download
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/buildpack/pack/config"
+	"github.com/buildpack/pack/logging"
+)
+
+const (
+	defaultRetries      = 3
+	defaultRetryBackoff = time.Second
+)
+
+type Client struct {
+	http         *http.Client
+	retries      int
+	retryBackoff time.Duration
+	hosts        map[string]config.DownloadHost
+	netrc        []netrcMachine
+	logger       *logging.Logger
+}
+
+// NewClient creates a client from the download settings of config.toml, which may be nil, and their environment
+// variable overrides
+func NewClient(cfg *config.Download, logger *logging.Logger) (*Client, error) {
+	if cfg == nil {
+		cfg = &config.Download{}
+	}
+
+	c := &Client{
+		retries:      defaultRetries,
+		retryBackoff: defaultRetryBackoff,
+		hosts:        map[string]config.DownloadHost{},
+		logger:       logger,
+	}
+
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 60 * time.Second,
+	}
+	c.http = &http.Client{Transport: transport}
+
+	if proxy := override("PACK_DOWNLOAD_PROXY", cfg.Proxy); proxy != "" {
+		proxyURL, err := url.Parse(proxy)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing download proxy %q", proxy)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	caCerts := cfg.CACerts
+	if env := os.Getenv("PACK_DOWNLOAD_CA_CERTS"); env != "" {
+		caCerts = filepath.SplitList(env)
+	}
+	if len(caCerts) > 0 {
+		pool, err := certPool(caCerts)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+
+	if timeout := override("PACK_DOWNLOAD_TIMEOUT", cfg.Timeout); timeout != "" {
+		d, err := time.ParseDuration(timeout)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing download timeout %q", timeout)
+		}
+		c.http.Timeout = d
+	}
+
+	if cfg.Retries != nil {
+		c.retries = *cfg.Retries
+	}
+	if env := os.Getenv("PACK_DOWNLOAD_RETRIES"); env != "" {
+		retries, err := strconv.Atoi(env)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing PACK_DOWNLOAD_RETRIES %q", env)
+		}
+		c.retries = retries
+	}
+
+	if backoff := override("PACK_DOWNLOAD_RETRY_BACKOFF", cfg.RetryBackoff); backoff != "" {
+		d, err := time.ParseDuration(backoff)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing download retry backoff %q", backoff)
+		}
+		c.retryBackoff = d
+	}
+
+	for _, host := range cfg.Hosts {
+		c.hosts[strings.ToLower(host.Host)] = host
+	}
+
+	netrcPath := override("PACK_DOWNLOAD_NETRC", cfg.Netrc)
+	if netrcPath == "" {
+		if home := homeDir(); home != "" {
+			if _, err := os.Stat(filepath.Join(home, ".netrc")); err == nil {
+				netrcPath = filepath.Join(home, ".netrc")
+			}
+		}
+	}
+	if netrcPath != "" {
+		var err error
+		if c.netrc, err = parseNetrc(netrcPath); err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// Get requests uri, sending If-None-Match when etag is set. Transient connection errors, 408, 429 and 5xx
+// responses are retried with exponential backoff; any other response is returned to the caller.
+func (c *Client) Get(uri, etag string) (*http.Response, error) {
+	var resp *http.Response
+	err := c.retry(uri, func() (bool, error) {
+		var again bool
+		var err error
+		resp, again, err = c.attempt(uri, etag)
+		return again, err
+	})
+	return resp, err
+}
+
+// Download requests uri like Get and passes the response to read, closing its body afterwards. When reading the body
+// fails with a transient error, e.g. because the connection was reset, the request and read are retried, so read must
+// discard anything it kept from the failed attempt.
+func (c *Client) Download(uri, etag string, read func(resp *http.Response) error) error {
+	return c.retry(uri, func() (bool, error) {
+		resp, again, err := c.attempt(uri, etag)
+		if err != nil {
+			return again, err
+		}
+		body := &bodyReader{ReadCloser: resp.Body}
+		resp.Body = body
+		defer body.Close()
+		if err := read(resp); err != nil {
+			return body.err != nil && transient(body.err), err
+		}
+		return false, nil
+	})
+}
+
+// retry calls attempt until it succeeds, fails with an error that is not worth retrying or the retries are used up,
+// doubling the delay between attempts
+func (c *Client) retry(uri string, attempt func() (again bool, err error)) error {
+	backoff := c.retryBackoff
+	for i := 0; ; i++ {
+		again, err := attempt()
+		if err == nil || !again || i >= c.retries {
+			return err
+		}
+		if c.logger != nil {
+			c.logger.Verbose("Retrying download of %q in %s (%d of %d retries): %s", uri, backoff, i+1, c.retries, err)
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// attempt sends a single request. Responses with a retryable status are closed and returned as an error.
+func (c *Client) attempt(uri, etag string) (resp *http.Response, again bool, err error) {
+	resp, err = c.get(uri, etag)
+	if err != nil {
+		return nil, transient(err), err
+	}
+	if retryable(resp.StatusCode) {
+		resp.Body.Close()
+		return nil, true, fmt.Errorf("http status %d", resp.StatusCode)
+	}
+	return resp, false, nil
+}
+
+func (c *Client) get(uri, etag string) (*http.Response, error) {
+	req, err := http.NewRequest("GET", uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	c.authenticate(req)
+	return c.http.Do(req)
+}
+
+// authenticate adds the credentials configured for the host of req. Go's http client drops them when a redirect
+// leaves the host.
+func (c *Client) authenticate(req *http.Request) {
+	host := strings.ToLower(req.URL.Hostname())
+	if token := os.Getenv("PACK_DOWNLOAD_TOKEN_" + envHost(host)); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+		return
+	}
+	if creds, ok := c.hosts[host]; ok {
+		if creds.Token != "" {
+			req.Header.Set("Authorization", "Bearer "+creds.Token)
+			return
+		}
+		if creds.Username != "" {
+			req.SetBasicAuth(creds.Username, creds.Password)
+			return
+		}
+	}
+	if machine, ok := findMachine(c.netrc, host); ok {
+		req.SetBasicAuth(machine.login, machine.password)
+	}
+}
+
+// bodyReader records the first error other than the end of the body while reading a response
+type bodyReader struct {
+	io.ReadCloser
+	err error
+}
+
+func (b *bodyReader) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && err != io.EOF && b.err == nil {
+		b.err = err
+	}
+	return n, err
+}
+
+func retryable(status int) bool {
+	return status == http.StatusRequestTimeout || status == http.StatusTooManyRequests || status >= 500
+}
+
+// transient reports whether a failed request may succeed when it is retried. Certificate and TLS errors, invalid
+// URLs and hosts that do not resolve fail the same way every time.
+func transient(err error) bool {
+	if urlErr, ok := err.(*url.Error); ok {
+		err = urlErr.Err
+	}
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return true
+	}
+	if opErr, ok := err.(*net.OpError); ok {
+		if dnsErr, ok := opErr.Err.(*net.DNSError); ok {
+			return dnsErr.IsTimeout || dnsErr.IsTemporary
+		}
+		return true
+	}
+	if dnsErr, ok := err.(*net.DNSError); ok {
+		return dnsErr.IsTimeout || dnsErr.IsTemporary
+	}
+	if netErr, ok := err.(net.Error); ok {
+		return netErr.Timeout()
+	}
+	return false
+}
+
+// homeDir returns the home directory of the user, which is USERPROFILE on Windows
+func homeDir() string {
+	if runtime.GOOS == "windows" {
+		return os.Getenv("USERPROFILE")
+	}
+	return os.Getenv("HOME")
+}
+
+func override(env, value string) string {
+	if v := os.Getenv(env); v != "" {
+		return v
+	}
+	return value
+}
+
+// envHost turns a host name into the suffix of its token environment variable
+func envHost(host string) string {
+	return strings.ToUpper(strings.NewReplacer(".", "_", "-", "_", ":", "_").Replace(host))
+}
+
+func certPool(files []string) (*x509.CertPool, error) {
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	for _, file := range files {
+		pem, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading CA certificates")
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no PEM encoded certificates found in %s", file)
+		}
+	}
+	return pool, nil
+}
@@ -0,0 +1,312 @@
+package download_test
+
+import (
+	"bytes"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpack/pack/config"
+	"github.com/buildpack/pack/download"
+	"github.com/buildpack/pack/logging"
+	h "github.com/buildpack/pack/testhelpers"
+)
+
+func TestDownload(t *testing.T) {
+	color.NoColor = true
+	spec.Run(t, "download", testDownload, spec.Report(report.Terminal{}))
+}
+
+func testDownload(t *testing.T, when spec.G, it spec.S) {
+	var (
+		tmpDir, caFile, home string
+		server               *httptest.Server
+		requests             []*http.Request
+		handler              http.HandlerFunc
+	)
+
+	body := func(resp *http.Response, err error) string {
+		t.Helper()
+		h.AssertNil(t, err)
+		defer resp.Body.Close()
+		b, err := ioutil.ReadAll(resp.Body)
+		h.AssertNil(t, err)
+		return string(b)
+	}
+
+	it.Before(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "pack.download.test")
+		h.AssertNil(t, err)
+		home = os.Getenv("HOME")
+		os.Setenv("HOME", tmpDir)
+
+		requests = nil
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, "buildpack")
+		}
+		server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests = append(requests, r)
+			handler(w, r)
+		}))
+
+		caFile = filepath.Join(tmpDir, "ca.pem")
+		pemBytes := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+		h.AssertNil(t, ioutil.WriteFile(caFile, pemBytes, 0644))
+	})
+
+	it.After(func() {
+		server.Close()
+		os.Setenv("HOME", home)
+		for _, env := range []string{"PACK_DOWNLOAD_CA_CERTS", "PACK_DOWNLOAD_TOKEN_127_0_0_1", "PACK_DOWNLOAD_RETRIES"} {
+			os.Unsetenv(env)
+		}
+		os.RemoveAll(tmpDir)
+	})
+
+	when("CA certificates", func() {
+		it("rejects servers signed by an unknown CA without retrying", func() {
+			var outBuf bytes.Buffer
+			client, err := download.NewClient(&config.Download{RetryBackoff: "1ms"}, logging.NewLogger(&outBuf, &outBuf, true, false))
+			h.AssertNil(t, err)
+			_, err = client.Get(server.URL, "")
+			h.AssertContains(t, err.Error(), "certificate")
+			h.AssertNotContains(t, outBuf.String(), "Retrying")
+		})
+
+		it("trusts the configured CA certificates", func() {
+			client, err := download.NewClient(&config.Download{CACerts: []string{caFile}}, nil)
+			h.AssertNil(t, err)
+			h.AssertEq(t, body(client.Get(server.URL, "")), "buildpack")
+		})
+
+		it("trusts the CA certificates from the environment", func() {
+			os.Setenv("PACK_DOWNLOAD_CA_CERTS", caFile)
+			client, err := download.NewClient(nil, nil)
+			h.AssertNil(t, err)
+			h.AssertEq(t, body(client.Get(server.URL, "")), "buildpack")
+		})
+
+		it("returns an error for files without certificates", func() {
+			h.AssertNil(t, ioutil.WriteFile(filepath.Join(tmpDir, "empty.pem"), []byte("nothing"), 0644))
+			_, err := download.NewClient(&config.Download{CACerts: []string{filepath.Join(tmpDir, "empty.pem")}}, nil)
+			h.AssertContains(t, err.Error(), "no PEM encoded certificates found")
+		})
+	})
+
+	when("credentials", func() {
+		it("sends the bearer token configured for the host", func() {
+			client, err := download.NewClient(&config.Download{
+				CACerts: []string{caFile},
+				Hosts: []config.DownloadHost{
+					{Host: "other.example.com", Token: "other-token"},
+					{Host: "127.0.0.1", Token: "some-token"},
+				},
+			}, nil)
+			h.AssertNil(t, err)
+			body(client.Get(server.URL, ""))
+			h.AssertEq(t, requests[0].Header.Get("Authorization"), "Bearer some-token")
+		})
+
+		it("prefers the token from the environment", func() {
+			os.Setenv("PACK_DOWNLOAD_TOKEN_127_0_0_1", "env-token")
+			client, err := download.NewClient(&config.Download{
+				CACerts: []string{caFile},
+				Hosts:   []config.DownloadHost{{Host: "127.0.0.1", Token: "some-token"}},
+			}, nil)
+			h.AssertNil(t, err)
+			body(client.Get(server.URL, ""))
+			h.AssertEq(t, requests[0].Header.Get("Authorization"), "Bearer env-token")
+		})
+
+		it("uses basic auth from the netrc file", func() {
+			netrc := filepath.Join(tmpDir, ".netrc")
+			h.AssertNil(t, ioutil.WriteFile(netrc, []byte("machine other.example.com login other password other\nmachine 127.0.0.1\n  login some-user\n  password some-password\n"), 0600))
+			client, err := download.NewClient(&config.Download{CACerts: []string{caFile}}, nil)
+			h.AssertNil(t, err)
+			body(client.Get(server.URL, ""))
+			user, password, ok := requests[0].BasicAuth()
+			h.AssertEq(t, ok, true)
+			h.AssertEq(t, user, "some-user")
+			h.AssertEq(t, password, "some-password")
+		})
+
+		it("sends no credentials to other hosts", func() {
+			client, err := download.NewClient(&config.Download{
+				CACerts: []string{caFile},
+				Hosts:   []config.DownloadHost{{Host: "other.example.com", Token: "other-token"}},
+			}, nil)
+			h.AssertNil(t, err)
+			body(client.Get(server.URL, ""))
+			h.AssertEq(t, requests[0].Header.Get("Authorization"), "")
+		})
+	})
+
+	when("retries", func() {
+		var retries = 1
+
+		it.Before(func() {
+			handler = func(w http.ResponseWriter, r *http.Request) {
+				if len(requests) < 3 {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				fmt.Fprint(w, "buildpack")
+			}
+		})
+
+		it("retries failed requests with backoff", func() {
+			client, err := download.NewClient(&config.Download{CACerts: []string{caFile}, RetryBackoff: "1ms"}, nil)
+			h.AssertNil(t, err)
+			h.AssertEq(t, body(client.Get(server.URL, "")), "buildpack")
+			h.AssertEq(t, len(requests), 3)
+		})
+
+		it("gives up after the configured number of retries", func() {
+			client, err := download.NewClient(&config.Download{CACerts: []string{caFile}, RetryBackoff: "1ms", Retries: &retries}, nil)
+			h.AssertNil(t, err)
+			_, err = client.Get(server.URL, "")
+			h.AssertError(t, err, "http status 503")
+			h.AssertEq(t, len(requests), 2)
+		})
+
+		it("prefers the number of retries from the environment", func() {
+			os.Setenv("PACK_DOWNLOAD_RETRIES", "0")
+			client, err := download.NewClient(&config.Download{CACerts: []string{caFile}, RetryBackoff: "1ms", Retries: &retries}, nil)
+			h.AssertNil(t, err)
+			_, err = client.Get(server.URL, "")
+			h.AssertError(t, err, "http status 503")
+			h.AssertEq(t, len(requests), 1)
+		})
+
+		it("retries request timeouts", func() {
+			handler = func(w http.ResponseWriter, r *http.Request) {
+				if len(requests) < 2 {
+					w.WriteHeader(http.StatusRequestTimeout)
+					return
+				}
+				fmt.Fprint(w, "buildpack")
+			}
+			client, err := download.NewClient(&config.Download{CACerts: []string{caFile}, RetryBackoff: "1ms"}, nil)
+			h.AssertNil(t, err)
+			h.AssertEq(t, body(client.Get(server.URL, "")), "buildpack")
+			h.AssertEq(t, len(requests), 2)
+		})
+
+		it("retries connection errors", func() {
+			var outBuf bytes.Buffer
+			server.Close()
+			client, err := download.NewClient(&config.Download{CACerts: []string{caFile}, RetryBackoff: "1ms", Retries: &retries}, logging.NewLogger(&outBuf, &outBuf, true, false))
+			h.AssertNil(t, err)
+			_, err = client.Get(server.URL, "")
+			h.AssertNotNil(t, err)
+			h.AssertContains(t, outBuf.String(), "Retrying download")
+		})
+
+		it("does not retry client errors", func() {
+			handler = func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+			}
+			client, err := download.NewClient(&config.Download{CACerts: []string{caFile}, RetryBackoff: "1ms"}, nil)
+			h.AssertNil(t, err)
+			resp, err := client.Get(server.URL, "")
+			h.AssertNil(t, err)
+			h.AssertEq(t, resp.StatusCode, http.StatusNotFound)
+			h.AssertEq(t, len(requests), 1)
+		})
+
+		when("the body is cut short", func() {
+			it.Before(func() {
+				handler = func(w http.ResponseWriter, r *http.Request) {
+					if len(requests) < 2 {
+						w.Header().Set("Content-Length", "100")
+						fmt.Fprint(w, "build")
+						return
+					}
+					fmt.Fprint(w, "buildpack")
+				}
+			})
+
+			it("retries the whole download", func() {
+				client, err := download.NewClient(&config.Download{CACerts: []string{caFile}, RetryBackoff: "1ms"}, nil)
+				h.AssertNil(t, err)
+				var bodies []string
+				h.AssertNil(t, client.Download(server.URL, "", func(resp *http.Response) error {
+					b, err := ioutil.ReadAll(resp.Body)
+					bodies = append(bodies, string(b))
+					return err
+				}))
+				h.AssertEq(t, bodies, []string{"build", "buildpack"})
+				h.AssertEq(t, len(requests), 2)
+			})
+
+			it("does not retry failures to read a complete body", func() {
+				handler = func(w http.ResponseWriter, r *http.Request) {
+					fmt.Fprint(w, "buildpack")
+				}
+				client, err := download.NewClient(&config.Download{CACerts: []string{caFile}, RetryBackoff: "1ms"}, nil)
+				h.AssertNil(t, err)
+				err = client.Download(server.URL, "", func(resp *http.Response) error {
+					if _, err := ioutil.ReadAll(resp.Body); err != nil {
+						return err
+					}
+					return errors.New("invalid archive")
+				})
+				h.AssertError(t, err, "invalid archive")
+				h.AssertEq(t, len(requests), 1)
+			})
+		})
+	})
+
+	when("timeout", func() {
+		it("fails downloads exceeding the timeout", func() {
+			handler = func(w http.ResponseWriter, r *http.Request) {
+				time.Sleep(200 * time.Millisecond)
+			}
+			retries := 0
+			client, err := download.NewClient(&config.Download{CACerts: []string{caFile}, Timeout: "50ms", Retries: &retries}, nil)
+			h.AssertNil(t, err)
+			_, err = client.Get(server.URL, "")
+			h.AssertContains(t, err.Error(), "Client.Timeout exceeded")
+		})
+
+		it("returns an error for invalid durations", func() {
+			_, err := download.NewClient(&config.Download{Timeout: "soon"}, nil)
+			h.AssertContains(t, err.Error(), `parsing download timeout "soon"`)
+		})
+	})
+
+	when("proxy", func() {
+		it("sends requests through the configured proxy", func() {
+			var proxied string
+			proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				proxied = r.URL.String()
+				fmt.Fprint(w, "from proxy")
+			}))
+			defer proxy.Close()
+
+			client, err := download.NewClient(&config.Download{Proxy: proxy.URL}, nil)
+			h.AssertNil(t, err)
+			h.AssertEq(t, body(client.Get("http://buildpacks.example.com/bp.tgz", "")), "from proxy")
+			h.AssertEq(t, proxied, "http://buildpacks.example.com/bp.tgz")
+		})
+	})
+
+	it("sends the etag of cached downloads", func() {
+		client, err := download.NewClient(&config.Download{CACerts: []string{caFile}}, nil)
+		h.AssertNil(t, err)
+		body(client.Get(server.URL, `"some-etag"`))
+		h.AssertEq(t, requests[0].Header.Get("If-None-Match"), `"some-etag"`)
+	})
+}
@@ -0,0 +1,84 @@
+package download
+
+import (
+	"bufio"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+type netrcMachine struct {
+	name     string // empty for the default entry
+	login    string
+	password string
+}
+
+// parseNetrc reads the machine, default, login and password tokens of a netrc file. Macro definitions are not
+// supported and skipped up to the next empty line.
+func parseNetrc(path string) ([]netrcMachine, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading netrc file")
+	}
+	defer f.Close()
+
+	var (
+		machines []netrcMachine
+		current  *netrcMachine
+		inMacro  bool
+	)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if inMacro {
+			inMacro = strings.TrimSpace(line) != ""
+			continue
+		}
+		fields := strings.Fields(line)
+		for i := 0; i < len(fields); i++ {
+			next := func() string {
+				if i+1 < len(fields) {
+					i++
+					return fields[i]
+				}
+				return ""
+			}
+			switch fields[i] {
+			case "machine":
+				machines = append(machines, netrcMachine{name: strings.ToLower(next())})
+				current = &machines[len(machines)-1]
+			case "default":
+				machines = append(machines, netrcMachine{})
+				current = &machines[len(machines)-1]
+			case "login":
+				if current != nil {
+					current.login = next()
+				}
+			case "password":
+				if current != nil {
+					current.password = next()
+				}
+			case "macdef":
+				inMacro = true
+				i = len(fields)
+			}
+		}
+	}
+	return machines, scanner.Err()
+}
+
+// findMachine returns the entry for host, falling back to the default entry
+func findMachine(machines []netrcMachine, host string) (netrcMachine, bool) {
+	for _, m := range machines {
+		if m.name == host {
+			return m, true
+		}
+	}
+	for _, m := range machines {
+		if m.name == "" {
+			return m, true
+		}
+	}
+	return netrcMachine{}, false
+}
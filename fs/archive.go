@@ -0,0 +1,213 @@
+package fs
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+)
+
+// CreateTarReaderFromArchive streams the contents of an app archive as a tar rooted at tarDir. Tar and gzipped tar
+// archives are re-rooted, while zip based archives (.zip, .jar, .war) are expanded entry by entry, which is what
+// buildpacks expecting an exploded archive require. The format is detected from the content, so r may be a pipe.
+func (*FS) CreateTarReaderFromArchive(r io.Reader, tarDir string, uid, gid int) (io.Reader, chan error) {
+	pr, pw := io.Pipe()
+	errChan := make(chan error, 1)
+
+	go func() {
+		err := writeArchiveAsTar(pw, r, tarDir, uid, gid)
+		pw.CloseWithError(err)
+		errChan <- err
+	}()
+	return pr, errChan
+}
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zipMagic  = []byte("PK\x03\x04")
+)
+
+func writeArchiveAsTar(w io.Writer, r io.Reader, tarDir string, uid, gid int) error {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(4)
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("read app archive: %s", err)
+	}
+
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		gzr, err := gzip.NewReader(br)
+		if err != nil {
+			return fmt.Errorf("read gzipped app archive: %s", err)
+		}
+		defer gzr.Close()
+		return retar(w, tar.NewReader(gzr), tarDir, uid, gid)
+	case bytes.HasPrefix(magic, zipMagic):
+		zr, cleanup, err := openZip(r, br)
+		if err != nil {
+			return fmt.Errorf("read zip app archive: %s", err)
+		}
+		defer cleanup()
+		return zipToTar(w, zr, tarDir, uid, gid)
+	default:
+		return retar(w, tar.NewReader(br), tarDir, uid, gid)
+	}
+}
+
+// openZip returns a zip reader for the archive. Zip archives need random access, so archives that are not read from
+// a file (e.g. stdin) are spooled to a temporary file first.
+func openZip(r io.Reader, br *bufio.Reader) (*zip.Reader, func(), error) {
+	if f, ok := r.(*os.File); ok {
+		if fi, err := f.Stat(); err == nil && fi.Mode().IsRegular() {
+			zr, err := zip.NewReader(f, fi.Size())
+			return zr, func() {}, err
+		}
+	}
+
+	tmp, err := ioutil.TempFile("", "pack.app-archive")
+	if err != nil {
+		return nil, nil, err
+	}
+	cleanup := func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}
+	size, err := io.Copy(tmp, br)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	zr, err := zip.NewReader(tmp, size)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	return zr, cleanup, nil
+}
+
+func retar(w io.Writer, tr *tar.Reader, tarDir string, uid, gid int) error {
+	tw := tar.NewWriter(w)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("read tar app archive: %s", err)
+		}
+		if hdr.Typeflag == tar.TypeXGlobalHeader {
+			continue
+		}
+		name, ok := archiveEntryName(tarDir, hdr.Name)
+		if !ok {
+			return fmt.Errorf("invalid entry in app archive: %s", hdr.Name)
+		}
+		hdr.Name = name
+		if hdr.Typeflag == tar.TypeDir {
+			hdr.Name += "/"
+		}
+		if hdr.Typeflag == tar.TypeLink {
+			if hdr.Linkname, ok = archiveEntryName(tarDir, hdr.Linkname); !ok {
+				return fmt.Errorf("invalid hardlink in app archive: %s", hdr.Linkname)
+			}
+		}
+		hdr.Uid = uid
+		hdr.Gid = gid
+		hdr.Uname = ""
+		hdr.Gname = ""
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := io.Copy(tw, tr); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+func zipToTar(w io.Writer, zr *zip.Reader, tarDir string, uid, gid int) error {
+	tw := tar.NewWriter(w)
+	for _, f := range zr.File {
+		name, ok := archiveEntryName(tarDir, f.Name)
+		if !ok {
+			return fmt.Errorf("invalid entry in app archive: %s", f.Name)
+		}
+
+		fi := f.FileInfo()
+		hdr := &tar.Header{
+			Name:    name,
+			Mode:    int64(fi.Mode().Perm()),
+			ModTime: f.Modified,
+			Uid:     uid,
+			Gid:     gid,
+		}
+		if hdr.Mode == 0 {
+			hdr.Mode = 0644
+		}
+
+		switch {
+		case fi.IsDir():
+			hdr.Typeflag = tar.TypeDir
+			hdr.Name += "/"
+			if fi.Mode().Perm() == 0 {
+				hdr.Mode = 0755
+			}
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+		case fi.Mode()&os.ModeSymlink != 0:
+			target, err := readZipEntry(f)
+			if err != nil {
+				return err
+			}
+			hdr.Typeflag = tar.TypeSymlink
+			hdr.Linkname = string(target)
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+		default:
+			hdr.Typeflag = tar.TypeReg
+			hdr.Size = int64(f.UncompressedSize64)
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(tw, rc)
+			rc.Close()
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return tw.Close()
+}
+
+func readZipEntry(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return ioutil.ReadAll(rc)
+}
+
+// archiveEntryName roots an archive entry under tarDir, rejecting entries that would escape it
+func archiveEntryName(tarDir, name string) (string, bool) {
+	cleaned, ok := cleanEntryName(name)
+	if !ok {
+		return "", false
+	}
+	if cleaned == "." {
+		return tarDir, true
+	}
+	return path.Join(tarDir, cleaned), true
+}
@@ -0,0 +1,137 @@
+package fs_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fatih/color"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpack/pack/fs"
+	h "github.com/buildpack/pack/testhelpers"
+)
+
+func TestArchive(t *testing.T) {
+	color.NoColor = true
+	spec.Run(t, "archive", testArchive, spec.Report(report.Terminal{}))
+}
+
+func testArchive(t *testing.T, when spec.G, it spec.S) {
+	var fs fs.FS
+
+	readTar := func(r io.Reader, errChan chan error) (map[string]string, map[string]*tar.Header) {
+		t.Helper()
+		contents := map[string]string{}
+		headers := map[string]*tar.Header{}
+		tr := tar.NewReader(r)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			h.AssertNil(t, err)
+			b, err := ioutil.ReadAll(tr)
+			h.AssertNil(t, err)
+			contents[hdr.Name] = string(b)
+			headers[hdr.Name] = hdr
+		}
+		h.AssertNil(t, <-errChan)
+		return contents, headers
+	}
+
+	zipArchive := func() []byte {
+		t.Helper()
+		buf := &bytes.Buffer{}
+		zw := zip.NewWriter(buf)
+		_, err := zw.Create("META-INF/")
+		h.AssertNil(t, err)
+		w, err := zw.Create("META-INF/MANIFEST.MF")
+		h.AssertNil(t, err)
+		_, err = w.Write([]byte("Main-Class: App"))
+		h.AssertNil(t, err)
+		w, err = zw.Create("App.class")
+		h.AssertNil(t, err)
+		_, err = w.Write([]byte("class"))
+		h.AssertNil(t, err)
+		h.AssertNil(t, zw.Close())
+		return buf.Bytes()
+	}
+
+	tarArchive := func(entries map[string]string) []byte {
+		t.Helper()
+		buf := &bytes.Buffer{}
+		tw := tar.NewWriter(buf)
+		for name, content := range entries {
+			h.AssertNil(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Uid: 501, Uname: "someone"}))
+			_, err := tw.Write([]byte(content))
+			h.AssertNil(t, err)
+		}
+		h.AssertNil(t, tw.Close())
+		return buf.Bytes()
+	}
+
+	when("#CreateTarReaderFromArchive", func() {
+		it("expands zip based archives", func() {
+			r, errChan := fs.CreateTarReaderFromArchive(bytes.NewReader(zipArchive()), "/workspace/app", 1000, 1001)
+			contents, headers := readTar(r, errChan)
+
+			h.AssertEq(t, contents, map[string]string{
+				"/workspace/app/META-INF/":            "",
+				"/workspace/app/META-INF/MANIFEST.MF": "Main-Class: App",
+				"/workspace/app/App.class":            "class",
+			})
+			h.AssertEq(t, headers["/workspace/app/META-INF/"].Typeflag, byte(tar.TypeDir))
+			h.AssertEq(t, headers["/workspace/app/App.class"].Uid, 1000)
+			h.AssertEq(t, headers["/workspace/app/App.class"].Gid, 1001)
+		})
+
+		it("expands zip archives read from a file", func() {
+			tmpDir, err := ioutil.TempDir("", "pack.archive.test")
+			h.AssertNil(t, err)
+			defer os.RemoveAll(tmpDir)
+			jar := filepath.Join(tmpDir, "app.jar")
+			h.AssertNil(t, ioutil.WriteFile(jar, zipArchive(), 0644))
+
+			f, err := os.Open(jar)
+			h.AssertNil(t, err)
+			defer f.Close()
+			contents, _ := readTar(fs.CreateTarReaderFromArchive(f, "/workspace/app", 0, 0))
+			h.AssertEq(t, contents["/workspace/app/App.class"], "class")
+		})
+
+		it("re-roots tar archives", func() {
+			r, errChan := fs.CreateTarReaderFromArchive(bytes.NewReader(tarArchive(map[string]string{"./src/main.go": "package main"})), "/workspace/app", 1000, 1001)
+			contents, headers := readTar(r, errChan)
+
+			h.AssertEq(t, contents, map[string]string{"/workspace/app/src/main.go": "package main"})
+			h.AssertEq(t, headers["/workspace/app/src/main.go"].Uid, 1000)
+			h.AssertEq(t, headers["/workspace/app/src/main.go"].Uname, "")
+		})
+
+		it("re-roots gzipped tar archives", func() {
+			buf := &bytes.Buffer{}
+			gzw := gzip.NewWriter(buf)
+			_, err := gzw.Write(tarArchive(map[string]string{"index.js": "console.log()"}))
+			h.AssertNil(t, err)
+			h.AssertNil(t, gzw.Close())
+
+			contents, _ := readTar(fs.CreateTarReaderFromArchive(buf, "/workspace/app", 0, 0))
+			h.AssertEq(t, contents, map[string]string{"/workspace/app/index.js": "console.log()"})
+		})
+
+		it("rejects entries escaping the destination", func() {
+			r, errChan := fs.CreateTarReaderFromArchive(bytes.NewReader(tarArchive(map[string]string{"../evil": "x"})), "/workspace/app", 0, 0)
+			_, err := ioutil.ReadAll(r)
+			h.AssertNotNil(t, err)
+			h.AssertError(t, <-errChan, "invalid entry in app archive: ../evil")
+		})
+	})
+}
@@ -0,0 +1,22 @@
+//go:build !windows
+// +build !windows
+
+package fs
+
+import (
+	"os"
+	"syscall"
+)
+
+type fileID struct {
+	dev, ino uint64
+}
+
+// hardlinkID identifies the file behind fi when it has more than one link, so that hardlinks can be preserved
+func hardlinkID(fi os.FileInfo) (fileID, bool) {
+	stat, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok || !fi.Mode().IsRegular() || stat.Nlink < 2 {
+		return fileID{}, false
+	}
+	return fileID{dev: uint64(stat.Dev), ino: uint64(stat.Ino)}, true
+}
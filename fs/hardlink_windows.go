@@ -0,0 +1,10 @@
+package fs
+
+import "os"
+
+type fileID struct{}
+
+// hardlinkID is not supported on Windows, where hardlinked files are archived as regular files
+func hardlinkID(fi os.FileInfo) (fileID, bool) {
+	return fileID{}, false
+}
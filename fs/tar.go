@@ -8,38 +8,59 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
+	"time"
 )
 
+// defaultSourceDate is the modification time of reproducible tar entries when SOURCE_DATE_EPOCH is not set. It is
+// the earliest date that can be stored in a zip archive, so that jars built from the same sources match as well.
+var defaultSourceDate = time.Date(1980, time.January, 1, 0, 0, 1, 0, time.UTC)
+
 type FS struct {
+	// Reproducible makes tars bit-for-bit identical across runs and hosts: entries get the SourceDateEpoch as
+	// modification time, normalized permissions and no user or group names or extended attributes
+	Reproducible bool
+	// MaxUntarSize limits the number of bytes Untar extracts, defaulting to DefaultMaxUntarSize
+	MaxUntarSize int64
+}
+
+// SourceDateEpoch returns the time set by SOURCE_DATE_EPOCH (see https://reproducible-builds.org/specs/source-date-epoch/),
+// defaulting to 1980-01-01T00:00:01Z when it is unset or invalid
+func SourceDateEpoch() time.Time {
+	seconds, err := strconv.ParseInt(os.Getenv("SOURCE_DATE_EPOCH"), 10, 64)
+	if err != nil {
+		return defaultSourceDate
+	}
+	return time.Unix(seconds, 0).UTC()
 }
 
-func (*FS) CreateTarFile(tarFile, srcDir, tarDir string, uid, gid int) error {
+func (f *FS) CreateTarFile(tarFile, srcDir, tarDir string, uid, gid int) error {
 	fh, err := os.Create(tarFile)
 	if err != nil {
 		return fmt.Errorf("create file for tar: %s", err)
 	}
 	defer fh.Close()
-	return writeTarArchive(fh, srcDir, tarDir, uid, gid)
+	return f.writeTarArchive(fh, srcDir, tarDir, uid, gid)
 }
 
-func (*FS) CreateTarReader(srcDir, tarDir string, uid, gid int) (io.Reader, chan error) {
+func (f *FS) CreateTarReader(srcDir, tarDir string, uid, gid int) (io.Reader, chan error) {
 	r, w := io.Pipe()
 	errChan := make(chan error, 1)
 
 	go func() {
 		defer w.Close()
-		err := writeTarArchive(w, srcDir, tarDir, uid, gid)
+		err := f.writeTarArchive(w, srcDir, tarDir, uid, gid)
 		w.Close()
 		errChan <- err
 	}()
 	return r, errChan
 }
 
-func (*FS) CreateSingleFileTar(path, txt string) (io.Reader, error) {
+func (f *FS) CreateSingleFileTar(path, txt string) (io.Reader, error) {
 	var buf bytes.Buffer
 	tw := tar.NewWriter(&buf)
-	if err := tw.WriteHeader(&tar.Header{Name: path, Size: int64(len(txt)), Mode: 0666}); err != nil {
+	if err := tw.WriteHeader(f.normalize(&tar.Header{Name: path, Size: int64(len(txt)), Mode: 0666})); err != nil {
 		return nil, err
 	}
 	if _, err := tw.Write([]byte(txt)); err != nil {
@@ -51,17 +72,18 @@ func (*FS) CreateSingleFileTar(path, txt string) (io.Reader, error) {
 	return bytes.NewReader(buf.Bytes()), nil
 }
 
-func writeTarArchive(w io.Writer, srcDir, tarDir string, uid, gid int) error {
+// writeTarArchive writes srcDir and its contents to w. filepath.Walk visits entries in lexical order, so the order of
+// entries does not depend on the host filesystem. Files linked more than once are written as hardlinks to the first
+// entry for the same file.
+func (f *FS) writeTarArchive(w io.Writer, srcDir, tarDir string, uid, gid int) error {
 	tw := tar.NewWriter(w)
 	defer tw.Close()
 
+	hardlinks := map[fileID]string{}
 	return filepath.Walk(srcDir, func(file string, fi os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
-		if fi.Mode().IsDir() {
-			return nil
-		}
 		relPath, err := filepath.Rel(srcDir, file)
 		if err != nil {
 			return err
@@ -87,19 +109,48 @@ func writeTarArchive(w io.Writer, srcDir, tarDir string, uid, gid int) error {
 		if runtime.GOOS == "windows" {
 			header.Name = strings.Replace(header.Name, "\\", "/", -1)
 		}
+		if fi.IsDir() {
+			if header.Name == "/" || header.Name == "." {
+				return nil
+			}
+			header.Name += "/"
+		}
 		header.Uid = uid
 		header.Gid = gid
 
-		if err := tw.WriteHeader(header); err != nil {
+		if id, ok := hardlinkID(fi); ok {
+			if target, seen := hardlinks[id]; seen {
+				header.Typeflag = tar.TypeLink
+				header.Linkname = target
+				header.Size = 0
+			} else {
+				hardlinks[id] = header.Name
+			}
+		}
+
+		var xattrs map[string]string
+		if fi.Mode()&os.ModeSymlink == 0 {
+			if xattrs, err = readXattrs(file); err != nil {
+				return err
+			}
+		}
+		for name, value := range xattrs {
+			if header.PAXRecords == nil {
+				header.PAXRecords = map[string]string{}
+			}
+			header.PAXRecords[xattrPAXPrefix+name] = value
+		}
+
+		if err := tw.WriteHeader(f.normalize(header)); err != nil {
 			return err
 		}
-		if fi.Mode().IsRegular() {
-			f, err := os.Open(file)
+		if header.Typeflag == tar.TypeReg {
+			fh, err := os.Open(file)
 			if err != nil {
 				return err
 			}
-			defer f.Close()
-			if _, err := io.Copy(tw, f); err != nil {
+			defer fh.Close()
+			if _, err := io.Copy(tw, fh); err != nil {
 				return err
 			}
 		}
@@ -107,6 +158,31 @@ func writeTarArchive(w io.Writer, srcDir, tarDir string, uid, gid int) error {
 	})
 }
 
+// normalize strips host specific metadata from header when building reproducible tars
+func (f *FS) normalize(header *tar.Header) *tar.Header {
+	if !f.Reproducible {
+		return header
+	}
+
+	header.ModTime = SourceDateEpoch()
+	header.AccessTime = time.Time{}
+	header.ChangeTime = time.Time{}
+	header.Uname = ""
+	header.Gname = ""
+	// extended attributes such as SELinux labels depend on the host
+	header.PAXRecords = nil
+	header.Format = tar.FormatUnknown
+	switch {
+	case header.Typeflag == tar.TypeSymlink:
+		header.Mode = 0777
+	case header.Typeflag == tar.TypeDir || header.Mode&0111 != 0:
+		header.Mode = 0755
+	default:
+		header.Mode = 0644
+	}
+	return header
+}
+
 func (*FS) AddTextToTar(tw *tar.Writer, name string, contents []byte) error {
 	hdr := &tar.Header{Name: name, Mode: 0644, Size: int64(len(contents))}
 	if err := tw.WriteHeader(hdr); err != nil {
@@ -128,49 +204,3 @@ func (*FS) AddFileToTar(tw *tar.Writer, name string, contents *os.File) error {
 	_, err = io.Copy(tw, contents)
 	return err
 }
-
-func (*FS) Untar(r io.Reader, dest string) error {
-	tr := tar.NewReader(r)
-	for {
-		hdr, err := tr.Next()
-		if err == io.EOF {
-			// end of tar archive
-			return nil
-		}
-		if err != nil {
-			return err
-		}
-
-		path := filepath.Join(dest, hdr.Name)
-
-		switch hdr.Typeflag {
-		case tar.TypeDir:
-			if err := os.MkdirAll(path, hdr.FileInfo().Mode()); err != nil {
-				return err
-			}
-		case tar.TypeReg, tar.TypeRegA:
-			_, err := os.Stat(filepath.Dir(path))
-			if os.IsNotExist(err) {
-				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
-					return err
-				}
-			}
-
-			fh, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, hdr.FileInfo().Mode())
-			if err != nil {
-				return err
-			}
-			if _, err := io.Copy(fh, tr); err != nil {
-				fh.Close()
-				return err
-			}
-			fh.Close()
-		case tar.TypeSymlink:
-			if err := os.Symlink(hdr.Linkname, path); err != nil {
-				return err
-			}
-		default:
-			return fmt.Errorf("unknown file type in tar %d", hdr.Typeflag)
-		}
-	}
-}
@@ -2,7 +2,9 @@ package fs_test
 
 import (
 	"archive/tar"
+	"flag"
 	"github.com/fatih/color"
+	"io"
 	"io/ioutil"
 	"math/rand"
 	"os"
@@ -15,8 +17,11 @@ import (
 	"github.com/sclevine/spec/report"
 
 	"github.com/buildpack/pack/fs"
+	h "github.com/buildpack/pack/testhelpers"
 )
 
+var updateGolden = flag.Bool("update", false, "update the golden archives in testdata/golden")
+
 func TestFS(t *testing.T) {
 	color.NoColor = true
 	rand.Seed(time.Now().UTC().UnixNano())
@@ -57,11 +62,20 @@ func testFS(t *testing.T, when spec.G, it spec.S) {
 		defer file.Close()
 		tr := tar.NewReader(file)
 
-		t.Log("handles regular files")
+		t.Log("handles directories")
 		header, err := tr.Next()
 		if err != nil {
 			t.Fatalf("Failed to get next file: %s", err)
 		}
+		if header.Name != "/dir-in-archive/" || header.Typeflag != tar.TypeDir {
+			t.Fatalf(`expected directory with name /dir-in-archive/, got %s`, header.Name)
+		}
+
+		t.Log("handles regular files")
+		header, err = tr.Next()
+		if err != nil {
+			t.Fatalf("Failed to get next file: %s", err)
+		}
 		if header.Name != "/dir-in-archive/some-file.txt" {
 			t.Fatalf(`expected file with name /dir-in-archive/some-file.txt, got %s`, header.Name)
 		}
@@ -78,6 +92,14 @@ func testFS(t *testing.T, when spec.G, it spec.S) {
 		}
 
 		if runtime.GOOS != "windows" {
+			header, err = tr.Next()
+			if err != nil {
+				t.Fatalf("Failed to get next file: %s", err)
+			}
+			if header.Name != "/dir-in-archive/sub-dir/" || header.Typeflag != tar.TypeDir {
+				t.Fatalf(`expected directory with name /dir-in-archive/sub-dir/, got %s`, header.Name)
+			}
+
 			t.Log("handles symlinks")
 			header, err = tr.Next()
 			if err != nil {
@@ -98,4 +120,154 @@ func testFS(t *testing.T, when spec.G, it spec.S) {
 			}
 		}
 	})
+
+	when("reproducible", func() {
+		it.Before(func() {
+			fs.Reproducible = true
+		})
+
+		it.After(func() {
+			os.Unsetenv("SOURCE_DATE_EPOCH")
+		})
+
+		it("produces identical tars regardless of file metadata", func() {
+			h.AssertNil(t, os.Chtimes(filepath.Join(src, "some-file.txt"), time.Now(), time.Now()))
+			first := filepath.Join(tmpDir, "first.tar")
+			h.AssertNil(t, fs.CreateTarFile(first, src, "/dir-in-archive", 1234, 2345))
+
+			h.AssertNil(t, os.Chtimes(filepath.Join(src, "some-file.txt"), time.Now().Add(time.Hour), time.Now().Add(time.Hour)))
+			second := filepath.Join(tmpDir, "second.tar")
+			h.AssertNil(t, fs.CreateTarFile(second, src, "/dir-in-archive", 1234, 2345))
+
+			firstContents, err := ioutil.ReadFile(first)
+			h.AssertNil(t, err)
+			secondContents, err := ioutil.ReadFile(second)
+			h.AssertNil(t, err)
+			h.AssertEq(t, firstContents, secondContents)
+		})
+
+		it("normalizes timestamps and permissions", func() {
+			r, errChan := fs.CreateTarReader(src, "/dir-in-archive", 1234, 2345)
+			tr := tar.NewReader(r)
+			header, err := tr.Next()
+			h.AssertNil(t, err)
+			h.AssertEq(t, header.Name, "/dir-in-archive/")
+			h.AssertEq(t, header.Mode, int64(0755))
+			header, err = tr.Next()
+			h.AssertNil(t, err)
+			h.AssertEq(t, header.Name, "/dir-in-archive/some-file.txt")
+			h.AssertEq(t, header.ModTime.UTC(), time.Date(1980, time.January, 1, 0, 0, 1, 0, time.UTC))
+			h.AssertEq(t, header.Mode, int64(0644))
+			h.AssertEq(t, header.Uname, "")
+			_, err = io.Copy(ioutil.Discard, r)
+			h.AssertNil(t, err)
+			h.AssertNil(t, <-errChan)
+		})
+
+		it("honors SOURCE_DATE_EPOCH", func() {
+			os.Setenv("SOURCE_DATE_EPOCH", "1550000000")
+			r, err := fs.CreateSingleFileTar("/some/file.txt", "contents")
+			h.AssertNil(t, err)
+			header, err := tar.NewReader(r).Next()
+			h.AssertNil(t, err)
+			h.AssertEq(t, header.ModTime.UTC(), time.Unix(1550000000, 0).UTC())
+			h.AssertEq(t, header.Mode, int64(0644))
+		})
+	})
+
+	when("#Untar", func() {
+		var dest string
+
+		it.Before(func() {
+			dest = filepath.Join(tmpDir, "dest")
+			h.AssertNil(t, os.MkdirAll(filepath.Join(dest, "app"), 0755))
+			h.AssertNil(t, ioutil.WriteFile(filepath.Join(dest, "app", "config.txt"), []byte("some much longer existing content"), 0644))
+		})
+
+		it("restores directories, hardlinks, symlinks, modes and mtimes from the golden archive", func() {
+			golden, err := os.Open(filepath.Join("testdata", "golden", "untar.tar"))
+			h.AssertNil(t, err)
+			defer golden.Close()
+			h.AssertNil(t, fs.Untar(golden, dest))
+
+			mtime := time.Date(2019, 2, 1, 10, 0, 0, 0, time.UTC)
+			for name, mode := range map[string]os.FileMode{
+				"app":            os.ModeDir | 0700,
+				"app/empty":      os.ModeDir | 0750,
+				"app/run.sh":     0755,
+				"app/config.txt": 0600,
+			} {
+				fi, err := os.Stat(filepath.Join(dest, name))
+				h.AssertNil(t, err)
+				h.AssertEq(t, fi.Mode(), mode)
+				h.AssertEq(t, fi.ModTime().UTC(), mtime)
+			}
+
+			contents, err := ioutil.ReadFile(filepath.Join(dest, "app", "config.txt"))
+			h.AssertNil(t, err)
+			h.AssertEq(t, string(contents), "short")
+
+			if runtime.GOOS != "windows" {
+				target, err := os.Stat(filepath.Join(dest, "app", "run.sh"))
+				h.AssertNil(t, err)
+				link, err := os.Stat(filepath.Join(dest, "app", "run-link.sh"))
+				h.AssertNil(t, err)
+				h.AssertEq(t, os.SameFile(target, link), true)
+
+				linkTarget, err := os.Readlink(filepath.Join(dest, "app", "current"))
+				h.AssertNil(t, err)
+				h.AssertEq(t, linkTarget, "run.sh")
+			}
+		})
+	})
+
+	when("round-tripping a directory", func() {
+		var layerSrc string
+
+		it.Before(func() {
+			fs.Reproducible = true
+			layerSrc = filepath.Join(tmpDir, "layer")
+			h.AssertNil(t, os.MkdirAll(filepath.Join(layerSrc, "bin"), 0755))
+			h.AssertNil(t, os.MkdirAll(filepath.Join(layerSrc, "empty"), 0700))
+			h.AssertNil(t, ioutil.WriteFile(filepath.Join(layerSrc, "bin", "build"), []byte("#!/bin/sh"), 0700))
+			h.AssertNil(t, ioutil.WriteFile(filepath.Join(layerSrc, "buildpack.toml"), []byte("[buildpack]"), 0600))
+			if runtime.GOOS != "windows" {
+				h.AssertNil(t, os.Link(filepath.Join(layerSrc, "bin", "build"), filepath.Join(layerSrc, "bin", "detect")))
+				h.AssertNil(t, os.Symlink("bin/build", filepath.Join(layerSrc, "run")))
+			}
+		})
+
+		it("writes the golden archive and extracts it back", func() {
+			if runtime.GOOS == "windows" {
+				t.Skip("hardlinks and symlinks are not archived on windows")
+			}
+			tarFile := filepath.Join(tmpDir, "layer.tar")
+			h.AssertNil(t, fs.CreateTarFile(tarFile, layerSrc, "/layer", 0, 0))
+
+			actual, err := ioutil.ReadFile(tarFile)
+			h.AssertNil(t, err)
+			goldenFile := filepath.Join("testdata", "golden", "reproducible.tar")
+			if *updateGolden {
+				h.AssertNil(t, ioutil.WriteFile(goldenFile, actual, 0644))
+			}
+			expected, err := ioutil.ReadFile(goldenFile)
+			h.AssertNil(t, err)
+			h.AssertEq(t, actual, expected)
+
+			dest := filepath.Join(tmpDir, "dest")
+			tarReader, err := os.Open(tarFile)
+			h.AssertNil(t, err)
+			defer tarReader.Close()
+			h.AssertNil(t, fs.Untar(tarReader, dest))
+
+			fi, err := os.Stat(filepath.Join(dest, "layer", "empty"))
+			h.AssertNil(t, err)
+			h.AssertEq(t, fi.Mode(), os.ModeDir|0755)
+			build, err := os.Stat(filepath.Join(dest, "layer", "bin", "build"))
+			h.AssertNil(t, err)
+			detect, err := os.Stat(filepath.Join(dest, "layer", "bin", "detect"))
+			h.AssertNil(t, err)
+			h.AssertEq(t, os.SameFile(build, detect), true)
+		})
+	})
 }
@@ -0,0 +1,209 @@
+package fs
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultMaxUntarSize is the number of bytes Untar extracts at most unless FS.MaxUntarSize is set
+const DefaultMaxUntarSize = 1 << 30
+
+// UnsafeEntryError is returned by Untar for entries that would be extracted outside of the destination, or that
+// exceed the size limit
+type UnsafeEntryError struct {
+	Entry  string
+	Reason string
+}
+
+func (e *UnsafeEntryError) Error() string {
+	return fmt.Sprintf("refusing to extract '%s': %s", e.Entry, e.Reason)
+}
+
+// Untar extracts the archive read from r into dest. Modes, modification times and extended attributes (where the
+// platform supports them) are restored; existing files are overwritten.
+//
+// Archives are not trusted: entries with names or hardlinks escaping dest, relative symlinks pointing outside of
+// dest and entries that would be written through a symlink are rejected with an UnsafeEntryError. Absolute symlinks
+// are extracted as-is, as they are only meaningful in the image the files end up in, but are never followed.
+func (f *FS) Untar(r io.Reader, dest string) error {
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return err
+	}
+	root, err := filepath.Abs(dest)
+	if err != nil {
+		return err
+	}
+	if root, err = filepath.EvalSymlinks(root); err != nil {
+		return err
+	}
+
+	maxSize := f.MaxUntarSize
+	if maxSize <= 0 {
+		maxSize = DefaultMaxUntarSize
+	}
+
+	var (
+		dirs []*tar.Header
+		size int64
+	)
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			// end of tar archive
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		name, ok := cleanEntryName(hdr.Name)
+		if !ok {
+			return &UnsafeEntryError{Entry: hdr.Name, Reason: "path escapes the destination"}
+		}
+		target := filepath.Join(root, filepath.FromSlash(name))
+		if err := checkWithin(root, filepath.Dir(target), hdr.Name); err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeDir {
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+		}
+		// never follow a symlink extracted earlier when writing an entry
+		if fi, err := os.Lstat(target); err == nil && fi.Mode()&os.ModeSymlink != 0 {
+			if err := os.Remove(target); err != nil {
+				return err
+			}
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+			// the directory stays writable until its contents are extracted, its mode is restored last
+			if err := os.Chmod(target, hdr.FileInfo().Mode().Perm()|0700); err != nil {
+				return err
+			}
+			hdr.Name = target
+			dirs = append(dirs, hdr)
+		case tar.TypeReg, tar.TypeRegA:
+			size += hdr.Size
+			if size > maxSize {
+				return &UnsafeEntryError{Entry: hdr.Name, Reason: fmt.Sprintf("archive exceeds the maximum extracted size of %d bytes", maxSize)}
+			}
+			// an existing file may be read-only, or a hardlink whose other names must keep their contents
+			if err := removeExisting(target); err != nil {
+				return err
+			}
+			fh, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_EXCL, hdr.FileInfo().Mode())
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(fh, tr); err != nil {
+				fh.Close()
+				return err
+			}
+			fh.Close()
+			if err := os.Chmod(target, hdr.FileInfo().Mode().Perm()); err != nil {
+				return err
+			}
+		case tar.TypeLink:
+			linkName, ok := cleanEntryName(hdr.Linkname)
+			if !ok {
+				return &UnsafeEntryError{Entry: hdr.Name, Reason: fmt.Sprintf("hardlink to '%s' escapes the destination", hdr.Linkname)}
+			}
+			linkTarget := filepath.Join(root, filepath.FromSlash(linkName))
+			if err := checkWithin(root, filepath.Dir(linkTarget), hdr.Name); err != nil {
+				return err
+			}
+			if err := removeExisting(target); err != nil {
+				return err
+			}
+			if err := os.Link(linkTarget, target); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if !path.IsAbs(hdr.Linkname) {
+				if _, ok := cleanEntryName(path.Join(path.Dir(name), hdr.Linkname)); !ok {
+					return &UnsafeEntryError{Entry: hdr.Name, Reason: fmt.Sprintf("symlink to '%s' escapes the destination", hdr.Linkname)}
+				}
+			}
+			if err := removeExisting(target); err != nil {
+				return err
+			}
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return err
+			}
+			continue
+		default:
+			return fmt.Errorf("unknown file type in tar %d for %s", hdr.Typeflag, hdr.Name)
+		}
+
+		if err := writeXattrs(target, hdr.PAXRecords); err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeDir {
+			if err := os.Chtimes(target, hdr.ModTime, hdr.ModTime); err != nil {
+				return err
+			}
+		}
+	}
+
+	// directory modes would prevent extracting read-only directories and mtimes change as their contents are
+	// extracted, so both are restored last, deepest first
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := os.Chmod(dirs[i].Name, dirs[i].FileInfo().Mode().Perm()); err != nil {
+			return err
+		}
+		if err := os.Chtimes(dirs[i].Name, dirs[i].ModTime, dirs[i].ModTime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cleanEntryName returns the slash separated path of an archive entry relative to the destination. Leading slashes
+// are dropped, names climbing out of the destination are reported as not ok.
+func cleanEntryName(name string) (string, bool) {
+	cleaned := path.Clean(strings.TrimLeft(strings.Replace(name, "\\", "/", -1), "/"))
+	if cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", false
+	}
+	return cleaned, true
+}
+
+// checkWithin verifies that dir, once symlinks in its existing ancestors are resolved, is inside root
+func checkWithin(root, dir, entry string) error {
+	existing := dir
+	for {
+		if _, err := os.Lstat(existing); err == nil {
+			break
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+		existing = filepath.Dir(existing)
+	}
+	resolved, err := filepath.EvalSymlinks(existing)
+	if err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(root, resolved)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return &UnsafeEntryError{Entry: entry, Reason: "path resolves outside of the destination through a symlink"}
+	}
+	return nil
+}
+
+func removeExisting(path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
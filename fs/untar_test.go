@@ -0,0 +1,158 @@
+package fs_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/fatih/color"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpack/pack/fs"
+	h "github.com/buildpack/pack/testhelpers"
+)
+
+func TestUntar(t *testing.T) {
+	color.NoColor = true
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks require elevated privileges on windows")
+	}
+	spec.Run(t, "untar", testUntar, spec.Report(report.Terminal{}))
+}
+
+func testUntar(t *testing.T, when spec.G, it spec.S) {
+	var (
+		tmpDir, dest, outside string
+		fsys                  fs.FS
+	)
+
+	it.Before(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "pack.untar.test")
+		h.AssertNil(t, err)
+		dest = filepath.Join(tmpDir, "dest")
+		outside = filepath.Join(tmpDir, "outside")
+		h.AssertNil(t, os.MkdirAll(outside, 0755))
+		h.AssertNil(t, ioutil.WriteFile(filepath.Join(outside, "secret"), []byte("secret"), 0644))
+	})
+
+	it.After(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	archive := func(headers ...*tar.Header) *bytes.Buffer {
+		t.Helper()
+		buf := &bytes.Buffer{}
+		tw := tar.NewWriter(buf)
+		for _, hdr := range headers {
+			content := make([]byte, hdr.Size)
+			h.AssertNil(t, tw.WriteHeader(hdr))
+			_, err := tw.Write(content)
+			h.AssertNil(t, err)
+		}
+		h.AssertNil(t, tw.Close())
+		return buf
+	}
+
+	assertUnsafe := func(err error, entry string) {
+		t.Helper()
+		unsafeErr, ok := err.(*fs.UnsafeEntryError)
+		if !ok {
+			t.Fatalf("expected an UnsafeEntryError, got: %v", err)
+		}
+		h.AssertEq(t, unsafeErr.Entry, entry)
+	}
+
+	assertOutsideUntouched := func() {
+		t.Helper()
+		files, err := ioutil.ReadDir(outside)
+		h.AssertNil(t, err)
+		h.AssertEq(t, len(files), 1)
+		contents, err := ioutil.ReadFile(filepath.Join(outside, "secret"))
+		h.AssertNil(t, err)
+		h.AssertEq(t, string(contents), "secret")
+	}
+
+	it("rejects entries climbing out of the destination", func() {
+		err := fsys.Untar(archive(&tar.Header{Name: "../outside/evil", Typeflag: tar.TypeReg, Mode: 0644, Size: 4}), dest)
+		assertUnsafe(err, "../outside/evil")
+		h.AssertEq(t, err.Error(), "refusing to extract '../outside/evil': path escapes the destination")
+		assertOutsideUntouched()
+	})
+
+	it("extracts absolute entries inside the destination", func() {
+		h.AssertNil(t, fsys.Untar(archive(&tar.Header{Name: "/bin/build", Typeflag: tar.TypeReg, Mode: 0755, Size: 4}), dest))
+		_, err := os.Stat(filepath.Join(dest, "bin", "build"))
+		h.AssertNil(t, err)
+	})
+
+	it("rejects relative symlinks pointing outside of the destination", func() {
+		err := fsys.Untar(archive(&tar.Header{Name: "bin/link", Typeflag: tar.TypeSymlink, Linkname: "../../outside"}), dest)
+		assertUnsafe(err, "bin/link")
+	})
+
+	it("rejects entries written through a symlink", func() {
+		err := fsys.Untar(archive(
+			&tar.Header{Name: "link", Typeflag: tar.TypeSymlink, Linkname: outside},
+			&tar.Header{Name: "link/evil", Typeflag: tar.TypeReg, Mode: 0644, Size: 4},
+		), dest)
+		assertUnsafe(err, "link/evil")
+		assertOutsideUntouched()
+	})
+
+	it("replaces symlinks instead of writing through them", func() {
+		h.AssertNil(t, fsys.Untar(archive(
+			&tar.Header{Name: "secret", Typeflag: tar.TypeSymlink, Linkname: filepath.Join(outside, "secret")},
+			&tar.Header{Name: "secret", Typeflag: tar.TypeReg, Mode: 0644, Size: 4},
+		), dest))
+		assertOutsideUntouched()
+		fi, err := os.Lstat(filepath.Join(dest, "secret"))
+		h.AssertNil(t, err)
+		h.AssertEq(t, fi.Mode().IsRegular(), true)
+	})
+
+	it("extracts the contents of read-only directories", func() {
+		h.AssertNil(t, fsys.Untar(archive(
+			&tar.Header{Name: "bin/", Typeflag: tar.TypeDir, Mode: 0555},
+			&tar.Header{Name: "bin/build", Typeflag: tar.TypeReg, Mode: 0755, Size: 4},
+		), dest))
+		defer os.Chmod(filepath.Join(dest, "bin"), 0755)
+
+		fi, err := os.Stat(filepath.Join(dest, "bin"))
+		h.AssertNil(t, err)
+		h.AssertEq(t, fi.Mode().Perm(), os.FileMode(0555))
+		_, err = os.Stat(filepath.Join(dest, "bin", "build"))
+		h.AssertNil(t, err)
+	})
+
+	it("overwrites existing read-only files", func() {
+		h.AssertNil(t, os.MkdirAll(dest, 0755))
+		h.AssertNil(t, ioutil.WriteFile(filepath.Join(dest, "file"), []byte("old"), 0444))
+
+		h.AssertNil(t, fsys.Untar(archive(&tar.Header{Name: "file", Typeflag: tar.TypeReg, Mode: 0644, Size: 4}), dest))
+
+		contents, err := ioutil.ReadFile(filepath.Join(dest, "file"))
+		h.AssertNil(t, err)
+		h.AssertEq(t, contents, make([]byte, 4))
+	})
+
+	it("rejects hardlinks to files outside of the destination", func() {
+		err := fsys.Untar(archive(&tar.Header{Name: "link", Typeflag: tar.TypeLink, Linkname: "../outside/secret"}), dest)
+		assertUnsafe(err, "link")
+	})
+
+	it("enforces the maximum extracted size", func() {
+		fsys.MaxUntarSize = 10
+		err := fsys.Untar(archive(
+			&tar.Header{Name: "small", Typeflag: tar.TypeReg, Mode: 0644, Size: 8},
+			&tar.Header{Name: "big", Typeflag: tar.TypeReg, Mode: 0644, Size: 8},
+		), dest)
+		assertUnsafe(err, "big")
+		h.AssertEq(t, err.Error(), "refusing to extract 'big': archive exceeds the maximum extracted size of 10 bytes")
+	})
+}
@@ -0,0 +1,54 @@
+package fs
+
+import (
+	"bytes"
+	"strings"
+	"syscall"
+)
+
+// xattrPAXPrefix is the PAX record prefix used by GNU tar and archive/tar for extended attributes
+const xattrPAXPrefix = "SCHILY.xattr."
+
+func readXattrs(path string) (map[string]string, error) {
+	size, err := syscall.Listxattr(path, nil)
+	if err != nil || size <= 0 {
+		// symlinks and filesystems without extended attribute support have nothing to preserve
+		return nil, nil
+	}
+	buf := make([]byte, size)
+	size, err = syscall.Listxattr(path, buf)
+	if err != nil {
+		return nil, nil
+	}
+
+	xattrs := map[string]string{}
+	for _, name := range bytes.Split(buf[:size], []byte{0}) {
+		if len(name) == 0 {
+			continue
+		}
+		valueSize, err := syscall.Getxattr(path, string(name), nil)
+		if err != nil {
+			continue
+		}
+		value := make([]byte, valueSize)
+		valueSize, err = syscall.Getxattr(path, string(name), value)
+		if err != nil {
+			continue
+		}
+		xattrs[string(name)] = string(value[:valueSize])
+	}
+	return xattrs, nil
+}
+
+func writeXattrs(path string, records map[string]string) error {
+	for key, value := range records {
+		if !strings.HasPrefix(key, xattrPAXPrefix) {
+			continue
+		}
+		err := syscall.Setxattr(path, strings.TrimPrefix(key, xattrPAXPrefix), []byte(value), 0)
+		if err != nil && err != syscall.ENOTSUP && err != syscall.EPERM {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package fs_test
+
+import (
+	"archive/tar"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpack/pack/fs"
+	h "github.com/buildpack/pack/testhelpers"
+)
+
+func TestXattrs(t *testing.T) {
+	spec.Run(t, "xattrs", testXattrs, spec.Report(report.Terminal{}))
+}
+
+func testXattrs(t *testing.T, when spec.G, it spec.S) {
+	var tmpDir, src string
+
+	paxRecords := func(fs *fs.FS) map[string]string {
+		t.Helper()
+		tarFile := filepath.Join(tmpDir, "xattrs.tar")
+		h.AssertNil(t, fs.CreateTarFile(tarFile, src, "/some", 0, 0))
+		f, err := os.Open(tarFile)
+		h.AssertNil(t, err)
+		defer f.Close()
+
+		tr := tar.NewReader(f)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				t.Fatal("file.txt not found in tar")
+			}
+			h.AssertNil(t, err)
+			if hdr.Name == "/some/file.txt" {
+				return hdr.PAXRecords
+			}
+		}
+	}
+
+	it.Before(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "xattrs-test")
+		h.AssertNil(t, err)
+		src = filepath.Join(tmpDir, "src")
+		h.AssertNil(t, os.Mkdir(src, 0755))
+		file := filepath.Join(src, "file.txt")
+		h.AssertNil(t, ioutil.WriteFile(file, []byte("some-content"), 0644))
+		if err := syscall.Setxattr(file, "user.pack.test", []byte("some-value"), 0); err != nil {
+			t.Skipf("extended attributes are not supported in %s: %s", tmpDir, err)
+		}
+	})
+
+	it.After(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	it("archives extended attributes", func() {
+		h.AssertEq(t, paxRecords(&fs.FS{})["SCHILY.xattr.user.pack.test"], "some-value")
+	})
+
+	it("drops extended attributes from reproducible tars", func() {
+		h.AssertEq(t, len(paxRecords(&fs.FS{Reproducible: true})), 0)
+	})
+}
@@ -0,0 +1,17 @@
+//go:build !linux
+// +build !linux
+
+package fs
+
+// xattrPAXPrefix is the PAX record prefix used by GNU tar and archive/tar for extended attributes
+const xattrPAXPrefix = "SCHILY.xattr."
+
+// readXattrs is only supported on Linux
+func readXattrs(path string) (map[string]string, error) {
+	return nil, nil
+}
+
+// writeXattrs is only supported on Linux
+func writeXattrs(path string, records map[string]string) error {
+	return nil
+}
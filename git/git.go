@@ -0,0 +1,137 @@
+package git
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Repository is a git repository on the local filesystem. App sources are read from its object store, so the
+// state of the working tree (uncommitted or ignored files) never ends up in a build.
+type Repository struct {
+	Dir string
+}
+
+// ResolveCommit returns the full SHA of the commit referenced by ref (a SHA, tag or branch)
+func (r *Repository) ResolveCommit(ref string) (string, error) {
+	out, err := r.git("rev-parse", "--verify", "--quiet", ref+"^{commit}")
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve git ref '%s' in %s: %s", ref, r.Dir, err)
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// CommitTime returns the committer date of the given commit
+func (r *Repository) CommitTime(commit string) (time.Time, error) {
+	out, err := r.git("show", "-s", "--format=%ct", commit)
+	if err != nil {
+		return time.Time{}, err
+	}
+	seconds, err := strconv.ParseInt(strings.TrimSpace(out), 10, 64)
+	if err != nil {
+		return time.Time{}, errors.Wrapf(err, "parsing commit time of %s", commit)
+	}
+	return time.Unix(seconds, 0).UTC(), nil
+}
+
+// CreateTarReader streams the tree of the given commit (or the subPath directory within it) as a tar archive rooted
+// at tarDir. Entries get reproducible metadata: the commit time as modification time, the given uid/gid and the
+// file modes recorded by git.
+func (r *Repository) CreateTarReader(commit, subPath, tarDir string, uid, gid int) (io.Reader, chan error) {
+	pr, pw := io.Pipe()
+	errChan := make(chan error, 1)
+
+	go func() {
+		err := r.writeTarArchive(pw, commit, subPath, tarDir, uid, gid)
+		pw.CloseWithError(err)
+		errChan <- err
+	}()
+	return pr, errChan
+}
+
+func (r *Repository) writeTarArchive(w io.Writer, commit, subPath, tarDir string, uid, gid int) error {
+	modTime, err := r.CommitTime(commit)
+	if err != nil {
+		return err
+	}
+
+	treeish := commit
+	if subPath != "" && subPath != "." {
+		treeish = commit + ":" + path.Clean(strings.Replace(subPath, "\\", "/", -1))
+	}
+
+	cmd := exec.Command("git", "-C", r.Dir, "-c", "tar.umask=0022", "archive", "--format=tar", treeish)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return errors.Wrap(err, "running git archive")
+	}
+
+	tw := tar.NewWriter(w)
+	tr := tar.NewReader(out)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			cmd.Wait()
+			return errors.Wrapf(err, "reading git archive of %s: %s", treeish, strings.TrimSpace(stderr.String()))
+		}
+		if hdr.Typeflag == tar.TypeXGlobalHeader {
+			continue
+		}
+
+		hdr.Name = path.Join(tarDir, hdr.Name)
+		if hdr.Typeflag == tar.TypeDir {
+			hdr.Name += "/"
+		}
+		hdr.Uid = uid
+		hdr.Gid = gid
+		hdr.Uname = ""
+		hdr.Gname = ""
+		hdr.ModTime = modTime
+		hdr.PAXRecords = nil
+		hdr.Format = tar.FormatUnknown
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			cmd.Wait()
+			return err
+		}
+		if _, err := io.Copy(tw, tr); err != nil {
+			cmd.Wait()
+			return err
+		}
+	}
+
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("git archive of %s failed: %s", treeish, strings.TrimSpace(stderr.String()))
+	}
+	return tw.Close()
+}
+
+func (r *Repository) git(args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"-C", r.Dir}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", errors.New(msg)
+		}
+		return "", err
+	}
+	return string(out), nil
+}
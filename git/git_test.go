@@ -0,0 +1,115 @@
+package git_test
+
+import (
+	"archive/tar"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpack/pack/git"
+	h "github.com/buildpack/pack/testhelpers"
+)
+
+func TestGit(t *testing.T) {
+	color.NoColor = true
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	spec.Run(t, "git", testGit, spec.Report(report.Terminal{}))
+}
+
+func testGit(t *testing.T, when spec.G, it spec.S) {
+	var (
+		repoDir string
+		repo    *git.Repository
+		commit  string
+	)
+
+	run := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", repoDir}, args...)...)
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=pack", "GIT_AUTHOR_EMAIL=pack@example.com",
+			"GIT_COMMITTER_NAME=pack", "GIT_COMMITTER_EMAIL=pack@example.com",
+			"GIT_COMMITTER_DATE=2019-02-01T10:00:00Z", "GIT_AUTHOR_DATE=2019-02-01T10:00:00Z",
+		)
+		return h.Run(t, cmd)
+	}
+
+	it.Before(func() {
+		var err error
+		repoDir, err = ioutil.TempDir("", "pack.git.test")
+		h.AssertNil(t, err)
+		repo = &git.Repository{Dir: repoDir}
+
+		h.AssertNil(t, os.MkdirAll(filepath.Join(repoDir, "services", "api"), 0755))
+		h.AssertNil(t, ioutil.WriteFile(filepath.Join(repoDir, "README.md"), []byte("readme"), 0644))
+		h.AssertNil(t, ioutil.WriteFile(filepath.Join(repoDir, "services", "api", "main.go"), []byte("package main"), 0644))
+		h.AssertNil(t, ioutil.WriteFile(filepath.Join(repoDir, "services", "api", "run.sh"), []byte("#!/bin/sh"), 0755))
+
+		run("init", "-q")
+		run("add", ".")
+		run("commit", "-q", "-m", "initial")
+		run("tag", "v1.0.0")
+		commit = run("rev-parse", "HEAD")[:40]
+
+		h.AssertNil(t, ioutil.WriteFile(filepath.Join(repoDir, "services", "api", "main.go"), []byte("dirty"), 0644))
+	})
+
+	it.After(func() {
+		os.RemoveAll(repoDir)
+	})
+
+	when("#ResolveCommit", func() {
+		it("resolves tags to the commit SHA", func() {
+			sha, err := repo.ResolveCommit("v1.0.0")
+			h.AssertNil(t, err)
+			h.AssertEq(t, sha, commit)
+		})
+
+		it("returns an error for unknown refs", func() {
+			_, err := repo.ResolveCommit("does-not-exist")
+			h.AssertNotNil(t, err)
+		})
+	})
+
+	when("#CreateTarReader", func() {
+		it("streams the committed tree of the sub path with reproducible metadata", func() {
+			r, errChan := repo.CreateTarReader(commit, "services/api", "/workspace/app", 1000, 1001)
+
+			contents := map[string]string{}
+			modes := map[string]int64{}
+			tr := tar.NewReader(r)
+			for {
+				hdr, err := tr.Next()
+				if err == io.EOF {
+					break
+				}
+				h.AssertNil(t, err)
+				h.AssertEq(t, hdr.Uid, 1000)
+				h.AssertEq(t, hdr.Gid, 1001)
+				h.AssertEq(t, hdr.ModTime.UTC(), time.Date(2019, 2, 1, 10, 0, 0, 0, time.UTC))
+				b, err := ioutil.ReadAll(tr)
+				h.AssertNil(t, err)
+				contents[hdr.Name] = string(b)
+				modes[hdr.Name] = hdr.Mode
+			}
+			h.AssertNil(t, <-errChan)
+
+			h.AssertEq(t, contents, map[string]string{
+				"/workspace/app/main.go": "package main",
+				"/workspace/app/run.sh":  "#!/bin/sh",
+			})
+			h.AssertEq(t, modes["/workspace/app/main.go"], int64(0644))
+			h.AssertEq(t, modes["/workspace/app/run.sh"], int64(0755))
+		})
+	})
+}
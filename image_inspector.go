@@ -0,0 +1,146 @@
+package pack
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/buildpack/lifecycle"
+	"github.com/buildpack/lifecycle/image"
+	"github.com/pkg/errors"
+
+	"github.com/buildpack/pack/config"
+	"github.com/buildpack/pack/logging"
+	"github.com/buildpack/pack/style"
+)
+
+type ImageInspector struct {
+	Logger       *logging.Logger
+	Config       *config.Config
+	ImageFactory ImageFactory
+}
+
+// AppImage describes an app image built by pack, as recorded in its labels
+type AppImage struct {
+	Image      string              `json:"image"`
+	StackID    string              `json:"stackId"`
+	RunImage   AppImageRunImage    `json:"runImage"`
+	Buildpacks []AppImageBuildpack `json:"buildpacks"`
+	AppLayer   string              `json:"appLayer"`
+}
+
+type AppImageRunImage struct {
+	TopLayer string `json:"topLayer"`
+	SHA      string `json:"sha"`
+	// Mirror is the run image of the stack the image would be rebased on, empty when it could not be determined
+	Mirror   string `json:"mirror,omitempty"`
+	Outdated bool   `json:"outdated"`
+}
+
+type AppImageBuildpack struct {
+	ID      string          `json:"id"`
+	Version string          `json:"version"`
+	Layers  []AppImageLayer `json:"layers"`
+}
+
+type AppImageLayer struct {
+	Name   string `json:"name"`
+	SHA    string `json:"sha"`
+	Build  bool   `json:"build"`
+	Launch bool   `json:"launch"`
+	Cache  bool   `json:"cache"`
+}
+
+// Inspect reads the app image repoName from the registry when remote is true, and from the daemon otherwise. It
+// returns nil when the image does not exist.
+func (i *ImageInspector) Inspect(repoName string, remote bool) (*AppImage, error) {
+	newImage := i.newImageFunc(remote)
+	img, err := newImage(repoName)
+	if err != nil {
+		return nil, err
+	}
+	if found, err := img.Found(); err != nil {
+		return nil, err
+	} else if !found {
+		return nil, nil
+	}
+
+	label, err := img.Label(AppMetadataLabel)
+	if err != nil {
+		return nil, err
+	}
+	if label == "" {
+		return nil, fmt.Errorf("invalid app image %s: missing required label %s -- was it built by pack?", style.Symbol(repoName), style.Symbol(AppMetadataLabel))
+	}
+	var metadata lifecycle.AppImageMetadata
+	if err := json.Unmarshal([]byte(label), &metadata); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse metadata of app image %s", style.Symbol(repoName))
+	}
+	stackID, err := img.Label(StackLabel)
+	if err != nil {
+		return nil, err
+	}
+
+	appImage := &AppImage{
+		Image:   repoName,
+		StackID: stackID,
+		RunImage: AppImageRunImage{
+			TopLayer: metadata.RunImage.TopLayer,
+			SHA:      metadata.RunImage.SHA,
+		},
+		AppLayer: metadata.App.SHA,
+	}
+	for _, bp := range metadata.Buildpacks {
+		buildpack := AppImageBuildpack{ID: bp.ID, Version: bp.Version, Layers: []AppImageLayer{}}
+		for name, layer := range bp.Layers {
+			buildpack.Layers = append(buildpack.Layers, AppImageLayer{
+				Name:   name,
+				SHA:    layer.SHA,
+				Build:  layer.Build,
+				Launch: layer.Launch,
+				Cache:  layer.Cache,
+			})
+		}
+		sort.Slice(buildpack.Layers, func(i, j int) bool { return buildpack.Layers[i].Name < buildpack.Layers[j].Name })
+		appImage.Buildpacks = append(appImage.Buildpacks, buildpack)
+	}
+
+	// an outdated run image is not an error, the image can still be inspected when it cannot be checked
+	if err := i.checkRunImage(appImage, newImage); err != nil {
+		i.Logger.Verbose("Could not check whether the run image of %s is outdated: %s", style.Symbol(repoName), err)
+	}
+	return appImage, nil
+}
+
+// checkRunImage compares the run image of the app image with the run image mirror that rebase would use
+func (i *ImageInspector) checkRunImage(appImage *AppImage, newImage func(string) (image.Image, error)) error {
+	mirror, err := runImageName(i.Config, appImage.StackID, appImage.Image)
+	if err != nil {
+		return err
+	}
+	runImage, err := newImage(mirror)
+	if err != nil {
+		return err
+	}
+	if found, err := runImage.Found(); err != nil {
+		return err
+	} else if !found {
+		return fmt.Errorf("run image %s not found", style.Symbol(mirror))
+	}
+	topLayer, err := runImage.TopLayer()
+	if err != nil {
+		return err
+	}
+	appImage.RunImage.Mirror = mirror
+	appImage.RunImage.Outdated = topLayer != appImage.RunImage.TopLayer
+	return nil
+}
+
+func (i *ImageInspector) newImageFunc(remote bool) func(string) (image.Image, error) {
+	if remote {
+		return i.ImageFactory.NewRemote
+	}
+	return func(name string) (image.Image, error) {
+		return i.ImageFactory.NewLocal(name, false)
+	}
+}
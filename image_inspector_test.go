@@ -0,0 +1,130 @@
+package pack_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/fatih/color"
+	"github.com/golang/mock/gomock"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpack/pack"
+	"github.com/buildpack/pack/config"
+	"github.com/buildpack/pack/logging"
+	"github.com/buildpack/pack/mocks"
+	h "github.com/buildpack/pack/testhelpers"
+)
+
+func TestImageInspector(t *testing.T) {
+	color.NoColor = true
+	spec.Run(t, "image_inspector", testImageInspector, spec.Parallel(), spec.Report(report.Terminal{}))
+}
+
+func testImageInspector(t *testing.T, when spec.G, it spec.S) {
+	var (
+		mockController   *gomock.Controller
+		mockImageFactory *mocks.MockImageFactory
+		mockImage        *mocks.MockImage
+		inspector        pack.ImageInspector
+		outBuf           bytes.Buffer
+	)
+
+	it.Before(func() {
+		mockController = gomock.NewController(t)
+		mockImageFactory = mocks.NewMockImageFactory(mockController)
+		mockImage = mocks.NewMockImage(mockController)
+
+		inspector = pack.ImageInspector{
+			Logger: logging.NewLogger(&outBuf, &outBuf, true, false),
+			Config: &config.Config{
+				DefaultStackID: "some.stack",
+				Stacks: []config.Stack{
+					{ID: "some.stack", BuildImage: "some/build", RunImages: []string{"some/run"}},
+				},
+			},
+			ImageFactory: mockImageFactory,
+		}
+	})
+
+	it.After(func() {
+		mockController.Finish()
+	})
+
+	when("#Inspect", func() {
+		when("the image was built by pack", func() {
+			var mockRunImage *mocks.MockImage
+
+			it.Before(func() {
+				mockImage.EXPECT().Found().Return(true, nil)
+				mockImage.EXPECT().Label("io.buildpacks.lifecycle.metadata").Return(`{
+  "app": {"sha": "sha256:app"},
+  "runImage": {"topLayer": "sha256:run-top", "sha": "sha256:run"},
+  "buildpacks": [{"key": "some.bp", "version": "1.2.3", "layers": {
+    "b-layer": {"sha": "sha256:b", "launch": true},
+    "a-layer": {"sha": "sha256:a", "build": true, "cache": true}
+  }}]
+}`, nil)
+				mockImage.EXPECT().Label("io.buildpacks.stack.id").Return("some.stack", nil)
+				mockRunImage = mocks.NewMockImage(mockController)
+				mockRunImage.EXPECT().Found().Return(true, nil)
+			})
+
+			it("describes the local image", func() {
+				mockImageFactory.EXPECT().NewLocal("some/app", false).Return(mockImage, nil)
+				mockImageFactory.EXPECT().NewLocal("some/run", false).Return(mockRunImage, nil)
+				mockRunImage.EXPECT().TopLayer().Return("sha256:run-top", nil)
+
+				appImage, err := inspector.Inspect("some/app", false)
+				h.AssertNil(t, err)
+				h.AssertEq(t, appImage, &pack.AppImage{
+					Image:   "some/app",
+					StackID: "some.stack",
+					RunImage: pack.AppImageRunImage{
+						TopLayer: "sha256:run-top",
+						SHA:      "sha256:run",
+						Mirror:   "some/run",
+					},
+					Buildpacks: []pack.AppImageBuildpack{{
+						ID:      "some.bp",
+						Version: "1.2.3",
+						Layers: []pack.AppImageLayer{
+							{Name: "a-layer", SHA: "sha256:a", Build: true, Cache: true},
+							{Name: "b-layer", SHA: "sha256:b", Launch: true},
+						},
+					}},
+					AppLayer: "sha256:app",
+				})
+			})
+
+			it("reports an outdated run image of the remote image", func() {
+				mockImageFactory.EXPECT().NewRemote("some/app").Return(mockImage, nil)
+				mockImageFactory.EXPECT().NewRemote("some/run").Return(mockRunImage, nil)
+				mockRunImage.EXPECT().TopLayer().Return("sha256:new-run-top", nil)
+
+				appImage, err := inspector.Inspect("some/app", true)
+				h.AssertNil(t, err)
+				h.AssertEq(t, appImage.RunImage.Mirror, "some/run")
+				h.AssertEq(t, appImage.RunImage.Outdated, true)
+			})
+		})
+
+		it("returns nil when the image does not exist", func() {
+			mockImageFactory.EXPECT().NewLocal("some/app", false).Return(mockImage, nil)
+			mockImage.EXPECT().Found().Return(false, nil)
+
+			appImage, err := inspector.Inspect("some/app", false)
+			h.AssertNil(t, err)
+			h.AssertNil(t, appImage)
+		})
+
+		it("returns an error for images not built by pack", func() {
+			mockImageFactory.EXPECT().NewLocal("some/app", false).Return(mockImage, nil)
+			mockImage.EXPECT().Found().Return(true, nil)
+			mockImage.EXPECT().Label("io.buildpacks.lifecycle.metadata").Return("", nil)
+
+			_, err := inspector.Inspect("some/app", false)
+			h.AssertError(t, err, "invalid app image 'some/app': missing required label 'io.buildpacks.lifecycle.metadata' -- was it built by pack?")
+		})
+	})
+}
@@ -404,6 +404,35 @@ cache = false
 				h.AssertContains(t, outBuf.String(), "BUILD: VAR1 is value1;")
 				h.AssertContains(t, outBuf.String(), "BUILD: VAR2 is value2 with spaces;")
 			})
+
+			when("the builder has a default build environment", func() {
+				it.Before(func() {
+					subject.Builder = "packs/samples-" + h.RandString(8)
+					h.CreateImageOnLocal(t, dockerCli, subject.Builder, fmt.Sprintf(`
+						FROM %s
+						USER root
+						RUN mkdir -p /platform/env && printf 'builder value' > /platform/env/VAR1 && printf 'builder default' > /platform/env/VAR3
+						USER pack
+						LABEL repo_name_for_randomisation=%s
+					`, h.DefaultBuilderImage(t, registryConfig.RunRegistryPort), subject.Builder))
+				})
+
+				it.After(func() {
+					h.AssertNil(t, h.DockerRmi(dockerCli, subject.Builder))
+				})
+
+				it("overrides the variables of the builder with those of the env file", func() {
+					if runtime.GOOS == "windows" {
+						t.Skip("directory buildpacks are not implemented on windows")
+					}
+					subject.EnvFile = map[string]string{"VAR1": "value1"}
+					subject.Buildpacks = []string{"../acceptance/testdata/mock_buildpacks/printenv"}
+					h.AssertNil(t, subject.Detect(ctx))
+					h.AssertNil(t, subject.Build(ctx))
+					h.AssertContains(t, outBuf.String(), "BUILD: VAR1 is value1;")
+					h.AssertContains(t, outBuf.String(), "BUILD: VAR3 is builder default;")
+				})
+			})
 		})
 	}, spec.Sequential())
 
@@ -33,6 +33,7 @@ type Task interface {
 type FS interface {
 	CreateTarFile(tarFile, srcDir, tarDir string, uid, gid int) error
 	CreateTarReader(srcDir, tarDir string, uid, gid int) (io.Reader, chan error)
+	CreateTarReaderFromArchive(r io.Reader, tarDir string, uid, gid int) (io.Reader, chan error)
 	Untar(r io.Reader, dest string) error
 	CreateSingleFileTar(path, txt string) (io.Reader, error)
 }
@@ -47,3 +48,8 @@ type ImageFactory interface {
 	NewLocal(string, bool) (image.Image, error)
 	NewRemote(string) (image.Image, error)
 }
+
+//go:generate mockgen -package mocks -destination mocks/image_reader.go github.com/buildpack/pack ImageReader
+type ImageReader interface {
+	Read(repoName string, fromRegistry bool) (v1.Image, error)
+}
@@ -3,13 +3,126 @@ package pack
 const (
 	StackLabel           = "io.buildpacks.stack.id"
 	BuilderMetadataLabel = "io.buildpacks.builder.metadata"
+	SourceRevisionLabel  = "org.opencontainers.image.revision"
+	// AppMetadataLabel holds the lifecycle.AppImageMetadata of an app image
+	AppMetadataLabel = "io.buildpacks.lifecycle.metadata"
+	// LifecycleVersionLabel holds the version of the lifecycle in a build image or builder. It is a label rather than
+	// only part of BuilderImageMetadata because build images that were not created by pack carry it too.
+	LifecycleVersionLabel = "io.buildpacks.lifecycle.version"
+	// BuildpackageLabel holds the BuildpackageMetadata of an image distributing a single buildpack
+	BuildpackageLabel = "io.buildpacks.buildpackage.metadata"
 )
 
+type BuildpackageMetadata struct {
+	ID      string `json:"id"`
+	Version string `json:"version"`
+}
+
 type BuilderImageMetadata struct {
-	RunImage BuilderRunImageMetadata `json:"runImage"`
+	RunImage   BuilderRunImageMetadata    `json:"runImage"`
+	Buildpacks []BuilderBuildpackMetadata `json:"buildpacks,omitempty"`
+	Groups     []BuilderGroupMetadata     `json:"groups,omitempty"`
+	// Env holds the default build environment of the builder, written to /platform/env
+	Env map[string]string `json:"env,omitempty"`
+	// LifecycleVersion is the version of the lifecycle configured with [lifecycle], empty when the builder keeps the
+	// lifecycle of its build image
+	LifecycleVersion string `json:"lifecycleVersion,omitempty"`
 }
 
 type BuilderRunImageMetadata struct {
 	Image   string   `json:"image"`
 	Mirrors []string `json:"mirrors"`
 }
+
+type BuilderBuildpackMetadata struct {
+	ID      string `json:"id"`
+	Version string `json:"version"`
+	Latest  bool   `json:"latest"`
+	// LayerDiffID is the diffID of the layer holding the buildpack, used to reuse the layer when recreating the builder
+	LayerDiffID string `json:"layerDiffId,omitempty"`
+}
+
+// BuilderGroupMetadata is a detection group of the builder, its buildpack versions are as written in builder.toml
+// and may be "latest"
+type BuilderGroupMetadata struct {
+	Description string                          `json:"description,omitempty"`
+	Buildpacks  []BuilderGroupBuildpackMetadata `json:"buildpacks"`
+}
+
+type BuilderGroupBuildpackMetadata struct {
+	ID       string `json:"id"`
+	Version  string `json:"version"`
+	Optional bool   `json:"optional,omitempty"`
+}
+
+// resolveBuildpack returns the concrete version of the buildpack with the given ID and version (or "latest")
+// listed in the metadata, and whether it was found.
+func (m *BuilderImageMetadata) resolveBuildpack(id, version string) (string, bool) {
+	for _, bp := range m.Buildpacks {
+		if bp.ID == id && (bp.Version == version || (version == "latest" && bp.Latest)) {
+			return bp.Version, true
+		}
+	}
+	return "", false
+}
+
+// suggestBuildpack returns the listed buildpack reference (<id>@<version>) closest to the given ID and version,
+// or an empty string when no listed ID is within a few edits of the given ID.
+func (m *BuilderImageMetadata) suggestBuildpack(id, version string) string {
+	suggestion := ""
+	best := -1
+	maxIDDistance := len([]rune(id)) / 3
+	for _, bp := range m.Buildpacks {
+		idDistance := levenshtein(id, bp.ID)
+		if idDistance > maxIDDistance {
+			continue
+		}
+		distance := idDistance*100 + levenshtein(version, bp.Version)
+		if version == "latest" && bp.Latest {
+			distance = idDistance * 100
+		}
+		if best == -1 || distance < best {
+			best = distance
+			suggestion = bp.ID + "@" + bp.Version
+		}
+	}
+	return suggestion
+}
+
+func (m *BuilderImageMetadata) buildpackVersions(id string) []string {
+	var versions []string
+	for _, bp := range m.Buildpacks {
+		if bp.ID == id {
+			versions = append(versions, bp.Version)
+		}
+	}
+	return versions
+}
+
+// levenshtein returns the edit distance between two strings
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = prev[j-1] + cost
+			if prev[j]+1 < curr[j] {
+				curr[j] = prev[j] + 1
+			}
+			if curr[j-1]+1 < curr[j] {
+				curr[j] = curr[j-1] + 1
+			}
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
@@ -77,6 +77,21 @@ func (mr *MockFSMockRecorder) CreateTarReader(arg0, arg1, arg2, arg3 interface{}
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTarReader", reflect.TypeOf((*MockFS)(nil).CreateTarReader), arg0, arg1, arg2, arg3)
 }
 
+// CreateTarReaderFromArchive mocks base method
+func (m *MockFS) CreateTarReaderFromArchive(arg0 io.Reader, arg1 string, arg2, arg3 int) (io.Reader, chan error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateTarReaderFromArchive", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(io.Reader)
+	ret1, _ := ret[1].(chan error)
+	return ret0, ret1
+}
+
+// CreateTarReaderFromArchive indicates an expected call of CreateTarReaderFromArchive
+func (mr *MockFSMockRecorder) CreateTarReaderFromArchive(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTarReaderFromArchive", reflect.TypeOf((*MockFS)(nil).CreateTarReaderFromArchive), arg0, arg1, arg2, arg3)
+}
+
 // Untar mocks base method
 func (m *MockFS) Untar(arg0 io.Reader, arg1 string) error {
 	m.ctrl.T.Helper()
@@ -65,7 +65,7 @@ func (f *RebaseFactory) RebaseConfigFromFlags(flags RebaseFlags) (RebaseConfig,
 }
 
 func (f *RebaseFactory) Rebase(cfg RebaseConfig) error {
-	label, err := cfg.Image.Label("io.buildpacks.lifecycle.metadata")
+	label, err := cfg.Image.Label(AppMetadataLabel)
 	if err != nil {
 		return err
 	}
@@ -86,7 +86,7 @@ func (f *RebaseFactory) Rebase(cfg RebaseConfig) error {
 		return err
 	}
 	newLabel, err := json.Marshal(metadata)
-	if err := cfg.Image.SetLabel("io.buildpacks.lifecycle.metadata", string(newLabel)); err != nil {
+	if err := cfg.Image.SetLabel(AppMetadataLabel, string(newLabel)); err != nil {
 		return err
 	}
 
@@ -98,7 +98,12 @@ func (f *RebaseFactory) Rebase(cfg RebaseConfig) error {
 }
 
 func (f *RebaseFactory) runImageName(stackID, repoName string) (string, error) {
-	stack, err := f.Config.GetStack(stackID)
+	return runImageName(f.Config, stackID, repoName)
+}
+
+// runImageName returns the run image of the stack closest to the registry of repoName
+func runImageName(cfg *config.Config, stackID, repoName string) (string, error) {
+	stack, err := cfg.GetStack(stackID)
 	if err != nil {
 		return "", err
 	}
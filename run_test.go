@@ -96,6 +96,7 @@ func testRun(t *testing.T, when spec.G, it spec.S) {
 		it("creates a RunConfig derived from a BuildConfig", func() {
 			mockBuilderImage := mocks.NewMockImage(mockController)
 			mockBuilderImage.EXPECT().Label("io.buildpacks.stack.id").Return("some.stack.id", nil)
+			mockBuilderImage.EXPECT().Label("io.buildpacks.lifecycle.version").Return("0.1.0", nil)
 			mockImageFactory.EXPECT().NewLocal("some/builder", true).Return(mockBuilderImage, nil)
 
 			mockRunImage := mocks.NewMockImage(mockController)
@@ -0,0 +1,195 @@
+package pack
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+
+	"github.com/buildpack/pack/config"
+	"github.com/buildpack/pack/logging"
+	"github.com/buildpack/pack/style"
+)
+
+const scaffoldVersion = "0.0.1"
+
+var buildpackIDSegment = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9.-]*$`)
+
+// ValidateBuildpackID checks that id names a buildpack directory once escaped, that is it consists of segments of
+// letters, digits, '.' and '-' separated by '/'. Underscores are rejected, as '/' is escaped to '_'.
+func ValidateBuildpackID(id string) error {
+	if id == "" {
+		return fmt.Errorf("buildpack ID must not be empty")
+	}
+	for _, segment := range strings.Split(id, "/") {
+		if !buildpackIDSegment.MatchString(segment) {
+			return fmt.Errorf("invalid buildpack ID %s: must consist of letters, digits, '.' and '-' separated by '/', and each part must start with a letter or digit", style.Symbol(id))
+		}
+	}
+	return nil
+}
+
+type ScaffoldFactory struct {
+	Logger *logging.Logger
+	Config *config.Config
+}
+
+type ScaffoldBuildpackFlags struct {
+	ID      string
+	Path    string
+	StackID string
+}
+
+type scaffoldData struct {
+	ID        string
+	Version   string
+	Stack     config.Stack
+	PlanEntry string
+}
+
+// Create generates a buildpack named flags.ID for a stack known in config.toml (the default stack unless
+// flags.StackID is set) in flags.Path, which must not exist or be empty
+func (f *ScaffoldFactory) Create(flags ScaffoldBuildpackFlags) (string, error) {
+	if err := ValidateBuildpackID(flags.ID); err != nil {
+		return "", err
+	}
+	stack, err := f.Config.GetStack(flags.StackID)
+	if err != nil {
+		var known []string
+		for _, s := range f.Config.Stacks {
+			known = append(known, s.ID)
+		}
+		return "", fmt.Errorf("%s, known stacks are: %s", err, strings.Join(known, ", "))
+	}
+
+	dir := flags.Path
+	if dir == "" {
+		dir = (&Buildpack{ID: flags.ID}).escapedID()
+	}
+	if files, err := ioutil.ReadDir(dir); err == nil && len(files) > 0 {
+		return "", fmt.Errorf("directory %s already exists and is not empty", dir)
+	} else if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+
+	data := scaffoldData{
+		ID:        flags.ID,
+		Version:   scaffoldVersion,
+		Stack:     *stack,
+		PlanEntry: (&Buildpack{ID: flags.ID}).escapedID(),
+	}
+	files := []struct {
+		path     string
+		template string
+		mode     os.FileMode
+	}{
+		{"buildpack.toml", buildpackTOMLTemplate, 0644},
+		{filepath.Join("bin", "detect"), detectTemplate, 0755},
+		{filepath.Join("bin", "build"), buildTemplate, 0755},
+		{filepath.Join("fixtures", "app", "hello.txt"), appTemplate, 0644},
+	}
+	for _, file := range files {
+		path := filepath.Join(dir, file.path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return "", err
+		}
+		if err := writeTemplate(path, file.template, file.mode, data); err != nil {
+			return "", errors.Wrapf(err, "writing %s", path)
+		}
+		f.Logger.Verbose("Created %s", path)
+	}
+	return dir, nil
+}
+
+func writeTemplate(path, text string, mode os.FileMode, data scaffoldData) error {
+	tmpl, err := template.New(filepath.Base(path)).Parse(text)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := tmpl.Execute(file, data); err != nil {
+		return err
+	}
+	// the mode passed to OpenFile is subject to umask
+	if err := os.Chmod(path, mode); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+const buildpackTOMLTemplate = `[buildpack]
+id = "{{.ID}}"
+version = "{{.Version}}"
+name = "{{.ID}}"
+
+[[stacks]]
+id = "{{.Stack.ID}}"
+`
+
+// The templates follow the buildpack interface of the lifecycle pack uses: bin/detect <platform> <plan> and
+// bin/build <layers> <platform> <plan>, both run in the app directory.
+const detectTemplate = `#!/usr/bin/env bash
+# usage: bin/detect <platform> <plan>
+#
+# Exits with 0 when this buildpack can build the app in the working directory, and 100 when it cannot. The build
+# plan gathered from all buildpacks of the group is available on stdin.
+set -eo pipefail
+
+platform_dir=$1
+plan_path=$2
+
+if [[ ! -f hello.txt ]]; then
+  exit 100
+fi
+
+# entries written to <plan> are added to the build plan, which is passed to bin/build
+cat >> "$plan_path" <<EOF
+["{{.PlanEntry}}"]
+version = "{{.Version}}"
+EOF
+`
+
+const buildTemplate = `#!/usr/bin/env bash
+# usage: bin/build <layers> <platform> <plan>
+#
+# Contributes layers to the app image. Each directory <layers>/<name> is a layer, described by <layers>/<name>.toml.
+# The build plan is available on stdin; entries this buildpack provides can be removed by rewriting <plan>.
+set -eo pipefail
+
+layers_dir=$1
+platform_dir=$2
+plan_path=$3
+
+echo "---> {{.ID}} {{.Version}}"
+
+hello_layer="$layers_dir/hello"
+mkdir -p "$hello_layer/bin"
+cat > "$hello_layer/bin/hello" <<'EOF'
+#!/usr/bin/env bash
+cat hello.txt
+EOF
+chmod +x "$hello_layer/bin/hello"
+
+# launch = true adds the layer to the app image, its bin directory is added to the PATH of processes
+cat > "$layers_dir/hello.toml" <<EOF
+launch = true
+EOF
+
+cat > "$layers_dir/launch.toml" <<EOF
+[[processes]]
+type = "web"
+command = "hello"
+EOF
+`
+
+const appTemplate = `Hello from an app built with {{.ID}}!
+`
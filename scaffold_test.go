@@ -0,0 +1,139 @@
+package pack_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+	"github.com/fatih/color"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpack/pack"
+	"github.com/buildpack/pack/config"
+	"github.com/buildpack/pack/fs"
+	"github.com/buildpack/pack/logging"
+	h "github.com/buildpack/pack/testhelpers"
+)
+
+func TestScaffoldFactory(t *testing.T) {
+	color.NoColor = true
+	if runtime.GOOS == "windows" {
+		t.Skip("buildpack templates are bash scripts")
+	}
+	spec.Run(t, "scaffold_factory", testScaffoldFactory, spec.Parallel(), spec.Report(report.Terminal{}))
+}
+
+func testScaffoldFactory(t *testing.T, when spec.G, it spec.S) {
+	var (
+		factory pack.ScaffoldFactory
+		tmpDir  string
+		outBuf  bytes.Buffer
+	)
+
+	it.Before(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "pack.scaffold.test")
+		h.AssertNil(t, err)
+		cfg, err := config.New(filepath.Join(tmpDir, "home"))
+		h.AssertNil(t, err)
+		factory = pack.ScaffoldFactory{
+			Logger: logging.NewLogger(&outBuf, &outBuf, true, false),
+			Config: cfg,
+		}
+	})
+
+	it.After(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	when("#ValidateBuildpackID", func() {
+		it("accepts ids that can be escaped", func() {
+			for _, id := range []string{"some.bp", "org/some-bp", "io.buildpacks/node/npm"} {
+				h.AssertNil(t, pack.ValidateBuildpackID(id))
+			}
+		})
+
+		it("rejects ids that cannot be escaped", func() {
+			for _, id := range []string{"", "some_bp", "/some.bp", "some/", "some//bp", "../bp", "some bp"} {
+				h.AssertNotNil(t, pack.ValidateBuildpackID(id))
+			}
+		})
+	})
+
+	when("#Create", func() {
+		it("generates a buildpack that detects its sample app", func() {
+			dir, err := factory.Create(pack.ScaffoldBuildpackFlags{
+				ID:   "org/some-bp",
+				Path: filepath.Join(tmpDir, "bp"),
+			})
+			h.AssertNil(t, err)
+			h.AssertEq(t, dir, filepath.Join(tmpDir, "bp"))
+
+			bp, err := (&pack.BuildpackageFactory{FS: &fs.FS{}}).ValidateBuildpack(dir)
+			h.AssertNil(t, err)
+			h.AssertEq(t, bp.ID, "org/some-bp")
+			h.AssertEq(t, bp.Version, "0.0.1")
+			buildpackTOML, err := ioutil.ReadFile(filepath.Join(dir, "buildpack.toml"))
+			h.AssertNil(t, err)
+			h.AssertContains(t, string(buildpackTOML), `id = "io.buildpacks.stacks.bionic"`)
+
+			planPath := filepath.Join(tmpDir, "plan.toml")
+			h.AssertNil(t, ioutil.WriteFile(planPath, nil, 0644))
+			cmd := exec.Command(filepath.Join(dir, "bin", "detect"), tmpDir, planPath)
+			cmd.Dir = filepath.Join(dir, "fixtures", "app")
+			out, err := cmd.CombinedOutput()
+			h.AssertNil(t, err)
+			h.AssertEq(t, string(out), "")
+			h.AssertDirContainsFileWithContents(t, tmpDir, "plan.toml", "[\"org_some-bp\"]\nversion = \"0.0.1\"\n")
+		})
+
+		it("quotes the plan entry of buildpacks with dotted ids", func() {
+			dir, err := factory.Create(pack.ScaffoldBuildpackFlags{
+				ID:   "com.example.hello",
+				Path: filepath.Join(tmpDir, "bp"),
+			})
+			h.AssertNil(t, err)
+
+			planPath := filepath.Join(tmpDir, "plan.toml")
+			h.AssertNil(t, ioutil.WriteFile(planPath, nil, 0644))
+			cmd := exec.Command(filepath.Join(dir, "bin", "detect"), tmpDir, planPath)
+			cmd.Dir = filepath.Join(dir, "fixtures", "app")
+			out, err := cmd.CombinedOutput()
+			h.AssertNil(t, err)
+			h.AssertEq(t, string(out), "")
+			h.AssertDirContainsFileWithContents(t, tmpDir, "plan.toml", "[\"com.example.hello\"]\nversion = \"0.0.1\"\n")
+
+			var plan map[string]interface{}
+			_, err = toml.DecodeFile(planPath, &plan)
+			h.AssertNil(t, err)
+			if _, ok := plan["com.example.hello"]; !ok {
+				t.Fatalf("plan has no entry for com.example.hello: %v", plan)
+			}
+		})
+
+		it("returns an error for stacks that are not in config.toml", func() {
+			_, err := factory.Create(pack.ScaffoldBuildpackFlags{
+				ID:      "some.bp",
+				Path:    filepath.Join(tmpDir, "bp"),
+				StackID: "some.stack",
+			})
+			h.AssertError(t, err, "stack 'some.stack' does not exist, known stacks are: io.buildpacks.stacks.bionic")
+		})
+
+		it("does not overwrite existing buildpacks", func() {
+			h.AssertNil(t, os.MkdirAll(filepath.Join(tmpDir, "bp"), 0755))
+			h.AssertNil(t, ioutil.WriteFile(filepath.Join(tmpDir, "bp", "buildpack.toml"), nil, 0644))
+			_, err := factory.Create(pack.ScaffoldBuildpackFlags{
+				ID:   "some.bp",
+				Path: filepath.Join(tmpDir, "bp"),
+			})
+			h.AssertError(t, err, "directory "+filepath.Join(tmpDir, "bp")+" already exists and is not empty")
+		})
+	})
+}